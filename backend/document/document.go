@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -208,20 +209,10 @@ func (oc *objectContext) outputHorizontalItems(x, y bag.ScaledPoint, hlist *node
 				oc.usedFaces[v.Font.Face] = true
 				oc.currentFont = v.Font
 			}
-			if exp, ok := hlist.Attributes["expand"]; ok {
-				if ex, ok := exp.(int); ok {
-					if ex != oc.currentExpand {
-						oc.gotoTextMode(3)
-						fmt.Fprintf(oc.s, "%d Tz ", 100+ex)
-						oc.currentExpand = ex
-					}
-				}
-			} else {
-				if oc.currentExpand != 0 {
-					oc.gotoTextMode(3)
-					fmt.Fprint(oc.s, "100 Tz ")
-					oc.currentExpand = 0
-				}
+			if v.Expansion != oc.currentExpand {
+				oc.gotoTextMode(3)
+				fmt.Fprintf(oc.s, "%s Tz ", strconv.FormatFloat(float64(1000+v.Expansion)/10, 'f', -1, 64))
+				oc.currentExpand = v.Expansion
 			}
 			if v.YOffset != oc.currentVShift {
 				oc.gotoTextMode(3)
@@ -242,7 +233,7 @@ func (oc *objectContext) outputHorizontalItems(x, y bag.ScaledPoint, hlist *node
 			v.Font.Face.RegisterChar(v.Codepoint)
 			oc.gotoTextMode(1)
 			fmt.Fprintf(oc.s, "%04x", v.Codepoint)
-			sumX = sumX + bag.MultiplyFloat(v.Width, float64(100+oc.currentExpand)/100.0)
+			sumX = sumX + v.Width + v.Width*bag.ScaledPoint(v.Expansion)/1000
 		case *node.Glue:
 			od := &outputDebug{
 				Name: "glue",
@@ -266,12 +257,15 @@ func (oc *objectContext) outputHorizontalItems(x, y bag.ScaledPoint, hlist *node
 					goBackwards = curFont.SpaceChar.Advance
 
 					if oc.currentFont.Size != 0 {
+						// the horizontal scaling of an expanded glyph also
+						// applies to the space and the displacement
+						wd := v.Width * 1000 / bag.ScaledPoint(1000+oc.currentExpand)
 						oc.gotoTextMode(2)
-						fmt.Fprintf(oc.s, " %d ", -1*1000*(v.Width-goBackwards)/oc.currentFont.Size)
+						fmt.Fprintf(oc.s, " %d ", -1*1000*(wd-goBackwards)/oc.currentFont.Size)
 					}
 				}
 			}
-			sumX = sumX + bag.MultiplyFloat(v.Width, float64(100+oc.currentExpand)/100.0)
+			sumX = sumX + v.Width
 		case *node.Rule:
 			od = &outputDebug{
 				Name: "rule",
@@ -446,16 +440,26 @@ func (oc *objectContext) outputHorizontalItems(x, y bag.ScaledPoint, hlist *node
 			}
 
 			if oc.currentFont != nil {
+				kern := v.Kern * 1000 / bag.ScaledPoint(1000+oc.currentExpand)
 				oc.gotoTextMode(2)
-				fmt.Fprintf(oc.s, " %d ", -1000*v.Kern/oc.currentFont.Size)
+				fmt.Fprintf(oc.s, " %d ", -1000*kern/oc.currentFont.Size)
 			}
 			sumX += v.Kern
 		case *node.Lang, *node.Penalty:
 			// ignore
 		case *node.Disc:
-			// ignore
+			// the Replace material of a discretionary which is not broken
+			if v.Replace != nil {
+				hl := node.Hpack(v.Replace)
+				moveY := y
+				if hlist.VAlign == node.VAlignTop {
+					moveY = moveY - hl.Height
+				}
+				oc.outputHorizontalItems(x+sumX, moveY, hl)
+				sumX += hl.Width
+			}
 		case *node.HList:
-			moveY := y
+			moveY := y - v.Shift
 			if hlist.VAlign == node.VAlignTop {
 				moveY = moveY - v.Height
 			}
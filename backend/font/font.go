@@ -27,10 +27,97 @@ type Font struct {
 	SpaceShrink  bag.ScaledPoint
 	Size         bag.ScaledPoint
 	Depth        bag.ScaledPoint
-	Face         *pdf.Face
-	Hyphenchar   Atom
-	SpaceChar    Atom
-	Mag          int
+	// CapHeight is 0 if the font does not contain the cap height.
+	CapHeight  bag.ScaledPoint
+	XHeight    bag.ScaledPoint
+	Face       *pdf.Face
+	Hyphenchar Atom
+	SpaceChar  Atom
+	Mag        int
+	// Protrusion contains the amount the characters protrude into the margin
+	// at the start and at the end of a line. Nil means no protrusion.
+	Protrusion map[rune]Protrusion
+	// Expansion contains the parameters for the font expansion. Nil means
+	// that the expansion of the paragraph applies to all glyphs.
+	Expansion *Expansion
+}
+
+// Expansion contains the parameters for the font expansion (the hz
+// algorithm). The glyphs are stretched or shrunk horizontally to get a more
+// even gray value of the paragraph.
+type Expansion struct {
+	// Stretch is the maximum expansion in thousandths of the glyph width.
+	Stretch int
+	// Shrink is the maximum compression in thousandths of the glyph width.
+	Shrink int
+	// Step is the granularity of the expansion in thousandths. The
+	// expansion of a glyph is always a multiple of Step. 0 allows any value.
+	Step int
+	// Factors contains the expansion factor in thousandths of a character.
+	// The default is 1000, a factor of 0 disables the expansion.
+	Factors map[rune]int
+	// ClassFactors contains the expansion factors for the characters of
+	// Unicode classes. The first class which contains a character applies,
+	// the entries in Factors take precedence.
+	ClassFactors []ClassFactor
+}
+
+// ClassFactor is the expansion factor in thousandths for the characters of a
+// Unicode class such as unicode.P (punctuation).
+type ClassFactor struct {
+	Class  *unicode.RangeTable
+	Factor int
+}
+
+// Factor returns the expansion factor of the character r in thousandths.
+func (e *Expansion) Factor(r rune) int {
+	if f, ok := e.Factors[r]; ok {
+		return f
+	}
+	for _, cf := range e.ClassFactors {
+		if unicode.Is(cf.Class, r) {
+			return cf.Factor
+		}
+	}
+	return 1000
+}
+
+// Protrusion is the amount a character protrudes into the left and into the
+// right margin in thousandths of its width.
+type Protrusion struct {
+	Left  int
+	Right int
+}
+
+// DefaultProtrusion is a protrusion table for latin text, similar to the
+// default settings of the LaTeX package microtype.
+var DefaultProtrusion = map[rune]Protrusion{
+	'!':      {0, 100},
+	'"':      {500, 500},
+	'\'':     {300, 400},
+	'(':      {100, 0},
+	')':      {0, 200},
+	',':      {0, 500},
+	'-':      {0, 500},
+	'.':      {0, 700},
+	':':      {0, 500},
+	';':      {0, 300},
+	'?':      {0, 200},
+	'[':      {100, 0},
+	']':      {0, 100},
+	'\u00AB': {200, 200}, // «
+	'\u00BB': {200, 200}, // »
+	'\u2010': {0, 500},   // hyphen
+	'\u2013': {200, 200}, // en dash
+	'\u2014': {150, 150}, // em dash
+	'\u2018': {300, 400}, // ‘
+	'\u2019': {300, 400}, // ’
+	'\u201A': {400, 400}, // ‚
+	'\u201C': {300, 300}, // “
+	'\u201D': {300, 300}, // ”
+	'\u201E': {300, 300}, // „
+	'\u2039': {400, 400}, // ‹
+	'\u203A': {400, 400}, // ›
 }
 
 // NewFont creates a new font instance.
@@ -48,6 +135,14 @@ func NewFont(face *pdf.Face, size bag.ScaledPoint) *Font {
 		Mag:          int(size) / int(face.UnitsPerEM),
 		Depth:        bag.ScaledPointFromFloat(factor * descend),
 	}
+	if ch := f.CapHeightPDF(); ch > 0 {
+		fnt.CapHeight = size * bag.ScaledPoint(ch) / bag.ScaledPoint(face.UnitsPerEM)
+	}
+	if xh := f.XHeightPDF(); xh > 0 {
+		fnt.XHeight = size * bag.ScaledPoint(xh) / bag.ScaledPoint(face.UnitsPerEM)
+	} else {
+		fnt.XHeight = size / 2
+	}
 	hyphenchar := fnt.Shape("-", []harfbuzz.Feature{})
 	if len(hyphenchar) == 1 {
 		fnt.Hyphenchar = hyphenchar[0]
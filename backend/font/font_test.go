@@ -0,0 +1,31 @@
+package font
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestExpansionFactor(t *testing.T) {
+	e := &Expansion{
+		Factors: map[rune]int{'!': 700},
+		ClassFactors: []ClassFactor{
+			{unicode.Po, 0},
+			{unicode.P, 500},
+		},
+	}
+	testdata := []struct {
+		r    rune
+		want int
+	}{
+		{'a', 1000},
+		{'!', 700},
+		// the first matching class wins
+		{'.', 0},
+		{'(', 500},
+	}
+	for _, td := range testdata {
+		if got := e.Factor(td.r); got != td.want {
+			t.Errorf("Factor(%q) = %d, want %d", td.r, got, td.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package lang
 
 import (
+	"bufio"
 	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/speedata/hyphenation"
 )
@@ -13,6 +16,10 @@ type Lang struct {
 	Righthyphenmin int
 	Name           string
 	lang           *hyphenation.Lang
+	// exceptions contains the hyphenation points (number of characters
+	// before the hyphen) of the lower case words. Words that must not be
+	// hyphenated have an empty entry.
+	exceptions map[string][]int
 }
 
 // LoadPatternFile loads the hyphenation patterns with the given file name
@@ -46,12 +53,91 @@ func NewFromReader(r io.Reader) (*Lang, error) {
 	return l, nil
 }
 
+// AddExceptions adds hyphenation exceptions in TeX syntax, for example
+// "ta-ble". The hyphens mark the allowed hyphenation points. A word without
+// a hyphen is never hyphenated. Exceptions take precedence over the
+// hyphenation patterns.
+func (l *Lang) AddExceptions(words ...string) {
+	if l.exceptions == nil {
+		l.exceptions = make(map[string][]int)
+	}
+	for _, word := range words {
+		positions := []int{}
+		var sb strings.Builder
+		count := 0
+		for _, r := range strings.ToLower(word) {
+			if r == '-' {
+				positions = append(positions, count)
+				continue
+			}
+			sb.WriteRune(r)
+			count++
+		}
+		l.exceptions[sb.String()] = positions
+	}
+}
+
+// AddNeverHyphenate adds words which must not be hyphenated.
+func (l *Lang) AddNeverHyphenate(words ...string) {
+	if l.exceptions == nil {
+		l.exceptions = make(map[string][]int)
+	}
+	for _, word := range words {
+		l.exceptions[strings.ToLower(word)] = []int{}
+	}
+}
+
+// ParseExceptions reads hyphenation exceptions from r. The words are
+// separated by white space and can be wrapped in \hyphenation{...} as in TeX.
+// Comments start with a percent sign and last until the end of the line.
+func (l *Lang) ParseExceptions(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "%")
+		line = strings.ReplaceAll(line, `\hyphenation{`, " ")
+		line = strings.ReplaceAll(line, "}", " ")
+		l.AddExceptions(strings.Fields(line)...)
+	}
+	return s.Err()
+}
+
+// LoadExceptionFile loads the hyphenation exceptions from the file fn. See
+// ParseExceptions for the format.
+func (l *Lang) LoadExceptionFile(fn string) error {
+	r, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return l.ParseExceptions(r)
+}
+
 // Hyphenate returns a slice of hyphenation points
 func (l *Lang) Hyphenate(word string) []int {
-	l.lang.Leftmin = l.Lefthyphenmin
-	l.lang.Rightmin = l.Righthyphenmin
+	return l.HyphenateLimits(word, l.Lefthyphenmin, l.Righthyphenmin)
+}
 
-	hyphenpoints := l.lang.Hyphenate(word)
+// HyphenateLimits returns a slice of hyphenation points like Hyphenate, but
+// with the limits left and right instead of Lefthyphenmin and
+// Righthyphenmin. It does not change l, so it can be called concurrently.
+func (l *Lang) HyphenateLimits(word string, left, right int) []int {
+	var hyphenpoints []int
+	wordlength := utf8.RuneCountInString(word)
+	if positions, ok := l.exceptions[strings.ToLower(word)]; ok {
+		for _, pos := range positions {
+			if pos >= left && pos <= wordlength-right {
+				hyphenpoints = append(hyphenpoints, pos)
+			}
+		}
+	} else {
+		// The patterns are applied without limits, the positions are
+		// filtered with the same limits as the exceptions.
+		for _, pos := range l.lang.Hyphenate(word) {
+			if pos >= left && pos <= wordlength-right {
+				hyphenpoints = append(hyphenpoints, pos)
+			}
+		}
+	}
 	// The slice hyphenpoints contains the valid break points
 	// after a character.
 	// We need the number of characters to move forward,
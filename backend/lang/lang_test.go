@@ -0,0 +1,37 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExceptions(t *testing.T) {
+	l, err := NewFromReader(strings.NewReader("1ba 1le"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.AddExceptions("ca-ble")
+	testdata := []struct {
+		word        string
+		left, right int
+		expected    string
+	}{
+		{"abababa", 0, 0, "[1 2 2]"},
+		{"abababa", 2, 3, "[3]"},
+		{"abababa", 3, 2, "[3 2]"},
+		{"abababa", 5, 1, "[5]"},
+		{"table", 3, 2, "[3]"},
+		{"table", 2, 3, "[]"},
+		{"cable", 2, 3, "[2]"},
+		{"cable", 3, 2, "[]"},
+	}
+	for _, td := range testdata {
+		if got := fmt.Sprint(l.HyphenateLimits(td.word, td.left, td.right)); got != td.expected {
+			t.Errorf("HyphenateLimits(%q, %d, %d) = %s, want %s", td.word, td.left, td.right, got, td.expected)
+		}
+	}
+	if l.Lefthyphenmin != 2 || l.Righthyphenmin != 3 {
+		t.Errorf("HyphenateLimits() changed the limits to %d and %d", l.Lefthyphenmin, l.Righthyphenmin)
+	}
+}
@@ -95,7 +95,20 @@ func debugNode(n Node, enc *xml.Encoder) {
 		case *Disc:
 			err = encodeAttributes(enc, &start, []kv{
 				{"id", v.ID},
+				{"penalty", v.Penalty},
 			}, v.Attributes)
+			for _, part := range []struct {
+				name string
+				list Node
+			}{{"pre", v.Pre}, {"post", v.Post}, {"replace", v.Replace}} {
+				if part.list == nil {
+					continue
+				}
+				partStart := xml.StartElement{Name: xml.Name{Local: part.name}}
+				enc.EncodeToken(partStart)
+				debugNode(part.list, enc)
+				enc.EncodeToken(partStart.End())
+			}
 		case *Glyph:
 			var fontid int
 			if fnt := v.Font; fnt != nil {
@@ -109,6 +122,7 @@ func debugNode(n Node, enc *xml.Encoder) {
 				{"dp", v.Depth},
 				{"codepoint", v.Codepoint},
 				{"face", fontid},
+				{"expansion", v.Expansion},
 			}, v.Attributes)
 		case *Glue:
 			err = encodeAttributes(enc, &start, []kv{
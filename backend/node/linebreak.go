@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/speedata/boxesandglue/backend/bag"
 )
@@ -38,11 +39,12 @@ type Breakpoint struct {
 	Fitness                               int
 	Width                                 bag.ScaledPoint
 	sumW, sumY, sumZ                      bag.ScaledPoint
-	sumExpand                             bag.ScaledPoint
-	calculatedExpand                      bag.ScaledPoint
+	sumExpand                             fontExpansion
 	stretchFil, stretchFill, stretchFilll bag.ScaledPoint
 	R                                     float64
 	Demerits                              int
+	hyphenatedLines                       int    // number of consecutive hyphenated lines ending here
+	startGlyph                            *Glyph // first glyph of the next line, used for margin protrusion
 }
 
 func (bp *Breakpoint) String() string {
@@ -60,13 +62,28 @@ type linebreaker struct {
 	inactiveNodesP   *Breakpoint
 	preva            *Breakpoint
 	sumW, sumY, sumZ bag.ScaledPoint
-	sumExpand        bag.ScaledPoint
+	sumExpand        fontExpansion
 	stretchFil       bag.ScaledPoint
 	stretchFill      bag.ScaledPoint
 	stretchFilll     bag.ScaledPoint
 	settings         *LinebreakSettings
 }
 
+// fontExpansion is the sum of the maximum stretch and the maximum shrink of
+// glyphs.
+type fontExpansion struct {
+	stretch bag.ScaledPoint
+	shrink  bag.ScaledPoint
+}
+
+func (fe fontExpansion) add(other fontExpansion) fontExpansion {
+	return fontExpansion{fe.stretch + other.stretch, fe.shrink + other.shrink}
+}
+
+func (fe fontExpansion) sub(other fontExpansion) fontExpansion {
+	return fontExpansion{fe.stretch - other.stretch, fe.shrink - other.shrink}
+}
+
 func newLinebreaker(hl Node, settings *LinebreakSettings) *linebreaker {
 	lb := &linebreaker{
 		settings: settings,
@@ -100,32 +117,24 @@ sum:
 	return sumwd
 }
 
-func (lb *linebreaker) computeAdjustmentRatio(n Node, a *Breakpoint) (float64, bag.ScaledPoint) {
+func (lb *linebreaker) computeAdjustmentRatio(n Node, a *Breakpoint) float64 {
 	// compute the adjustment ratio r from a to n
 	thisLineWidth := lb.sumW - a.sumW
 	switch t := n.(type) {
 	case *Penalty:
 		thisLineWidth += t.Width
 	case *Disc:
-		if !lb.settings.HangingPunctuationEnd {
-			wd, _, _ := Dimensions(t.Pre, nil, Horizontal)
-			thisLineWidth += wd
-		}
-	case *Glue:
-		if lb.settings.HangingPunctuationEnd {
-			if p := t.Prev(); p.Type() == TypeGlyph {
-				if g := p.(*Glyph); len(g.Components) == 1 && unicode.IsPunct(rune(g.Components[0])) {
-					thisLineWidth -= g.Width
-				}
-			}
-		}
+		wd, _, _ := Dimensions(t.Pre, nil, Horizontal)
+		thisLineWidth += wd
 	}
+	thisLineWidth -= lb.protrusion(a.startGlyph, true, a.Line == 0, isParagraphEnd(n))
+	thisLineWidth -= lb.protrusion(lineEndGlyph(n), false, a.Line == 0, isParagraphEnd(n))
 	// subtract left glue setting
-	maxwd := lb.settings.HSize - lb.getIndent(a.Line)
-	maxExpand := lb.sumExpand - a.sumExpand
+	maxwd := lb.settings.HSize - lb.getIndent(a.Line) - lb.getIndentRight(a.Line)
+	maxExpand := lb.sumExpand.sub(a.sumExpand)
 	r := 0.0
 	if thisLineWidth < maxwd {
-		y := lb.sumY - a.sumY + maxExpand
+		y := lb.sumY - a.sumY + maxExpand.stretch
 		if y > 0 {
 			if lb.stretchFil > 0 || lb.stretchFill > 0 || lb.stretchFilll > 0 {
 				r = 0
@@ -136,18 +145,18 @@ func (lb *linebreaker) computeAdjustmentRatio(n Node, a *Breakpoint) (float64, b
 			r = positiveInf
 		}
 	} else if maxwd < thisLineWidth {
-		z := lb.sumZ - a.sumZ + maxExpand
+		z := lb.sumZ - a.sumZ + maxExpand.shrink
 		if z > 0 {
 			r = float64(maxwd-thisLineWidth) / float64(z)
 		} else {
 			r = positiveInf
 		}
 	}
-	return r, maxExpand
+	return r
 }
 
 // computeSum computes the sum of all glues from n
-func (lb *linebreaker) computeSum(n Node) (bag.ScaledPoint, bag.ScaledPoint, bag.ScaledPoint, bag.ScaledPoint) {
+func (lb *linebreaker) computeSum(n Node) (bag.ScaledPoint, fontExpansion, bag.ScaledPoint, bag.ScaledPoint) {
 	// compute tw=(sum w)after(b), ty=(sum y)after(b), and tz=(sum z)after(b)
 	w, y, z := lb.sumW, lb.sumY, lb.sumZ
 	e := lb.sumExpand
@@ -176,9 +185,138 @@ compute:
 			break compute
 		}
 	}
+	if d, ok := n.(*Disc); ok {
+		// After a break at a discretionary the Replace material is not typeset
+		// but the Post material starts the next line.
+		replaceWd, replaceExpand := lb.listWidth(d.Replace)
+		postWd, postExpand := lb.listWidth(d.Post)
+		w += replaceWd - postWd
+		e = e.add(replaceExpand).sub(postExpand)
+	}
 	return w, e, y, z
 }
 
+// listWidth returns the width of the node list starting at n and the possible
+// font expansion of its glyphs.
+func (lb *linebreaker) listWidth(n Node) (bag.ScaledPoint, fontExpansion) {
+	var wd bag.ScaledPoint
+	var expand fontExpansion
+	for e := n; e != nil; e = e.Next() {
+		wd += getWidth(e, Horizontal)
+		if g, ok := e.(*Glyph); ok {
+			expand = expand.add(lb.glyphExpansion(g))
+		}
+	}
+	return wd, expand
+}
+
+// glyphExpansion returns the maximum stretch and shrink of the glyph g.
+func (lb *linebreaker) glyphExpansion(g *Glyph) fontExpansion {
+	if lb.settings.FontExpansion == 0 {
+		return fontExpansion{}
+	}
+	stretch, shrink := glyphExpansionLimits(g, lb.settings.FontExpansion)
+	return fontExpansion{stretch, shrink}
+}
+
+// protrusion returns the amount the glyph g protrudes into the margin.
+func (lb *linebreaker) protrusion(g *Glyph, start, firstLine, lastLine bool) bag.ScaledPoint {
+	if g == nil {
+		return 0
+	}
+	if lb.settings.Protrusion != nil {
+		return lb.settings.Protrusion(g, start, firstLine, lastLine)
+	}
+	if lb.settings.HangingPunctuationEnd && !start {
+		if r, size := utf8.DecodeRuneInString(g.Components); size > 0 && size == len(g.Components) && unicode.IsPunct(r) {
+			return g.Width
+		}
+	}
+	return 0
+}
+
+// protrusionKern returns a kern which moves the glyph at the start or at the
+// end of a line into the margin.
+func protrusionKern(amount bag.ScaledPoint) *Kern {
+	k := NewKern()
+	k.Kern = -amount
+	k.Attributes = H{"origin": "margin protrusion"}
+	return k
+}
+
+// isParagraphEnd returns true if n is the last node of the paragraph.
+func isParagraphEnd(n Node) bool {
+	return n.Next() == nil
+}
+
+// lineStartGlyph returns the first glyph of the line which starts after a
+// break at n or nil if the line starts with something else.
+func lineStartGlyph(n Node) *Glyph {
+	if d, ok := n.(*Disc); ok && d.Post != nil {
+		return firstGlyph(d.Post)
+	}
+	return firstGlyph(n.Next())
+}
+
+// firstGlyph returns the first glyph of the list starting at n. Nodes which
+// are not visible such as StartStop nodes, kerns and zero width boxes are
+// skipped. If the list starts with something else, firstGlyph returns nil.
+func firstGlyph(n Node) *Glyph {
+	for e := n; e != nil; e = e.Next() {
+		if g, ok := e.(*Glyph); ok {
+			return g
+		}
+		if !skipAtLineEdge(e) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// lineEndGlyph returns the last glyph of the line which ends with a break at
+// n or nil if the line ends with something else.
+func lineEndGlyph(n Node) *Glyph {
+	if d, ok := n.(*Disc); ok && d.Pre != nil {
+		return lastGlyph(Tail(d.Pre))
+	}
+	return lastGlyph(n.Prev())
+}
+
+// lastGlyph returns the last glyph of the list ending at n, skipping the same
+// nodes as firstGlyph and the glue at the end of a paragraph.
+func lastGlyph(n Node) *Glyph {
+	for e := n; e != nil; e = e.Prev() {
+		if g, ok := e.(*Glyph); ok {
+			return g
+		}
+		// the glue at the end of a paragraph has no visible width
+		if g, ok := e.(*Glue); ok && g.StretchOrder != StretchNormal {
+			continue
+		}
+		if !skipAtLineEdge(e) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipAtLineEdge returns true if n is not visible at the start or at the end
+// of a line, so the glyph next to it can protrude into the margin. Kerns
+// (font kerning and letter spacing) are skipped as well.
+func skipAtLineEdge(n Node) bool {
+	switch t := n.(type) {
+	case *StartStop, *Lang, *Penalty, *Kern:
+		return true
+	case *Rule:
+		return t.Width == 0
+	case *HList:
+		return t.Width == 0
+	case *VList:
+		return t.Width == 0
+	}
+	return false
+}
+
 func (lb *linebreaker) removeActiveNode(active *Breakpoint) {
 	if lb.preva == nil {
 		lb.activeNodesA = active.next
@@ -215,7 +353,11 @@ func (lb *linebreaker) calculateDemerits(active *Breakpoint, r float64, n Node)
 	case *Penalty:
 		curpenalty = t.Penalty
 	case *Disc:
-		curpenalty = lb.settings.Hyphenpenalty + t.Penalty
+		if t.Pre != nil {
+			curpenalty = lb.settings.Hyphenpenalty + t.Penalty
+		} else {
+			curpenalty = lb.settings.ExHyphenpenalty + t.Penalty
+		}
 		curflagged = true
 	}
 
@@ -255,23 +397,58 @@ func (lb *linebreaker) calculateDemerits(active *Breakpoint, r float64, n Node)
 }
 
 func (lb *linebreaker) getIndent(row int) bag.ScaledPoint {
+	var shape bag.ScaledPoint
+	if lb.settings.ParagraphShape != nil {
+		shape, _ = lb.settings.ParagraphShape(row)
+	}
 	rows := lb.settings.IndentRows
 	switch {
 	case rows == 0:
-		return lb.settings.Indent
+		return shape + lb.settings.Indent
 	case rows < 0:
 		if row >= -1*rows {
-			return lb.settings.Indent
+			return shape + lb.settings.Indent
 		}
-		return bag.ScaledPoint(0)
+		return shape
 
 	case rows > 0:
 		if rows > row {
-			return lb.settings.Indent
+			return shape + lb.settings.Indent
 		}
-		return bag.ScaledPoint(0)
+		return shape
+	}
+	return shape
+}
+
+// getIndentRight returns the space on the right side of the line row which is
+// not available for the text.
+func (lb *linebreaker) getIndentRight(row int) bag.ScaledPoint {
+	if lb.settings.ParagraphShape == nil {
+		return 0
+	}
+	_, right := lb.settings.ParagraphShape(row)
+	return right
+}
+
+// exceedsHyphenateLimit returns true if a break at n would result in more
+// consecutive hyphenated lines than allowed.
+func (lb *linebreaker) exceedsHyphenateLimit(active *Breakpoint, n Node) bool {
+	if lb.settings.HyphenateLimitLines <= 0 {
+		return false
+	}
+	if _, ok := n.(*Disc); !ok {
+		return false
 	}
-	return bag.ScaledPoint(0)
+	return active.hyphenatedLines >= lb.settings.HyphenateLimitLines
+}
+
+// countHyphenatedLines returns the number of consecutive hyphenated lines if
+// the line from the breakpoint from ends at n.
+func countHyphenatedLines(from *Breakpoint, n Node) int {
+	if _, ok := n.(*Disc); ok {
+		return from.hyphenatedLines + 1
+	}
+	return 0
 }
 
 func (lb *linebreaker) mainLoop(n Node) {
@@ -284,7 +461,6 @@ func (lb *linebreaker) mainLoop(n Node) {
 		dc := [4]int{math.MaxInt, math.MaxInt, math.MaxInt, math.MaxInt}
 		ac := [4]*Breakpoint{}
 		rc := [4]float64{}
-		ec := [4]bag.ScaledPoint{}
 
 		// The inner loop deactivates all unreachable breakpoints and calculates
 		// demerits/dmin.
@@ -294,7 +470,7 @@ func (lb *linebreaker) mainLoop(n Node) {
 			// For each active breakpoint check if the breakpoint is still
 			// active (= reachable from the current position backward). If not,
 			// remove them from the current list of active nodes.
-			r, sumExpand := lb.computeAdjustmentRatio(n, active)
+			r := lb.computeAdjustmentRatio(n, active)
 
 			if p, ok := n.(*Penalty); r < -1 || ok && p.Penalty == -10000 {
 				// If line is too wide or a forced break, we can remove the node
@@ -307,7 +483,7 @@ func (lb *linebreaker) mainLoop(n Node) {
 			// There might be active breakpoints (after cleanup), so all of them
 			// are a candidate for a final breakpoint. For each fitness class,
 			// we chose the best candidate (with the fewest total demerits)
-			if -1 <= r && r < lb.settings.Tolerance {
+			if -1 <= r && r < lb.settings.Tolerance && !lb.exceedsHyphenateLimit(active, n) {
 				// That looks like a good breakpoint.
 				c, demerits := lb.calculateDemerits(active, r, n)
 
@@ -319,7 +495,6 @@ func (lb *linebreaker) mainLoop(n Node) {
 					dc[c] = demerits
 					ac[c] = active
 					rc[c] = r
-					ec[c] = sumExpand
 					if demerits < dmin {
 						dmin = demerits
 					}
@@ -339,7 +514,7 @@ func (lb *linebreaker) mainLoop(n Node) {
 			}
 		}
 		if dmin < math.MaxInt {
-			lb.appendBreakpointHere(n, dmin, dc, ac, rc, ec, active)
+			lb.appendBreakpointHere(n, dmin, dc, ac, rc, active)
 		}
 		if dmin == math.MaxInt && lb.activeNodesA == nil {
 			W, E, Y, Z := lb.computeSum(n)
@@ -350,33 +525,35 @@ func (lb *linebreaker) mainLoop(n Node) {
 			case *Penalty:
 				width += v.Width
 			case *Disc:
-				width += 5 * bag.Factor
+				wd, _ := lb.listWidth(v.Pre)
+				width += wd
 				pre = v.Pre
 			}
 
 			bp := &Breakpoint{
-				id:               <-breakpointIDs,
-				Position:         n,
-				Pre:              pre,
-				Line:             lastInactive.Line + 1,
-				from:             lastInactive,
-				next:             active,
-				Fitness:          3,
-				Width:            lb.sumW - lastInactive.sumW,
-				sumW:             W,
-				sumExpand:        E,
-				sumY:             Y,
-				sumZ:             Z,
-				calculatedExpand: lb.sumExpand - lastInactive.sumExpand,
-				R:                0,
-				Demerits:         lastInactive.Demerits + 1000,
+				id:              <-breakpointIDs,
+				Position:        n,
+				Pre:             pre,
+				Line:            lastInactive.Line + 1,
+				from:            lastInactive,
+				next:            active,
+				Fitness:         3,
+				Width:           lb.sumW - lastInactive.sumW,
+				sumW:            W,
+				sumExpand:       E,
+				sumY:            Y,
+				sumZ:            Z,
+				R:               0,
+				Demerits:        lastInactive.Demerits + 1000,
+				hyphenatedLines: countHyphenatedLines(lastInactive, n),
+				startGlyph:      lineStartGlyph(n),
 			}
 			lb.appendNewBreakpoint(bp)
 		}
 	}
 }
 
-func (lb *linebreaker) appendBreakpointHere(n Node, dmin int, dc [4]int, ac [4]*Breakpoint, rc [4]float64, ec [4]bag.ScaledPoint, active *Breakpoint) {
+func (lb *linebreaker) appendBreakpointHere(n Node, dmin int, dc [4]int, ac [4]*Breakpoint, rc [4]float64, active *Breakpoint) {
 	W, E, Y, Z := lb.computeSum(n)
 
 	width := lb.sumW
@@ -385,28 +562,30 @@ func (lb *linebreaker) appendBreakpointHere(n Node, dmin int, dc [4]int, ac [4]*
 	case *Penalty:
 		width += v.Width
 	case *Disc:
-		width += 5 * bag.Factor
+		wd, _ := lb.listWidth(v.Pre)
+		width += wd
 		pre = v.Pre
 	}
 
 	for c := 0; c < 4; c++ {
 		if dc[c] <= dmin+lb.settings.DemeritsFitness {
 			bp := &Breakpoint{
-				id:               <-breakpointIDs,
-				Position:         n,
-				Pre:              pre,
-				Line:             ac[c].Line + 1,
-				from:             ac[c],
-				next:             active,
-				Fitness:          c,
-				Width:            width - ac[c].Width,
-				sumW:             W,
-				sumExpand:        E,
-				sumY:             Y,
-				sumZ:             Z,
-				calculatedExpand: ec[c],
-				R:                rc[c],
-				Demerits:         dc[c],
+				id:              <-breakpointIDs,
+				Position:        n,
+				Pre:             pre,
+				Line:            ac[c].Line + 1,
+				from:            ac[c],
+				next:            active,
+				Fitness:         c,
+				Width:           width - ac[c].Width,
+				sumW:            W,
+				sumExpand:       E,
+				sumY:            Y,
+				sumZ:            Z,
+				R:               rc[c],
+				Demerits:        dc[c],
+				hyphenatedLines: countHyphenatedLines(ac[c], n),
+				startGlyph:      lineStartGlyph(n),
 			}
 			lb.appendNewBreakpoint(bp)
 		}
@@ -430,7 +609,7 @@ func Linebreak(n Node, settings *LinebreakSettings) (*VList, []*Breakpoint) {
 	}
 	var prevItemBox bool
 	lb := newLinebreaker(n, settings)
-	lb.activeNodesA = &Breakpoint{id: <-breakpointIDs, Fitness: 1, Position: n}
+	lb.activeNodesA = &Breakpoint{id: <-breakpointIDs, Fitness: 1, Position: n, startGlyph: firstGlyph(n)}
 	var endNode Node
 
 	for e := n; e != nil; e = e.Next() {
@@ -462,15 +641,16 @@ func Linebreak(n Node, settings *LinebreakSettings) (*VList, []*Breakpoint) {
 				lb.mainLoop(t)
 			}
 		case *Disc:
-			prevItemBox = false
 			lb.mainLoop(t)
+			// the Replace material is typeset if the line is not broken here
+			wd, expand := lb.listWidth(t.Replace)
+			lb.sumW += wd
+			lb.sumExpand = lb.sumExpand.add(expand)
+			prevItemBox = t.Replace != nil
 		case *Glyph:
 			prevItemBox = true
 			lb.sumW += t.Width
-			if lb.settings.FontExpansion != 0 {
-				extend := bag.MultiplyFloat(t.Width, settings.FontExpansion)
-				lb.sumExpand += extend
-			}
+			lb.sumExpand = lb.sumExpand.add(lb.glyphExpansion(t))
 		default:
 			prevItemBox = true
 			wd := getWidth(e, Horizontal)
@@ -502,26 +682,25 @@ func Linebreak(n Node, settings *LinebreakSettings) (*VList, []*Breakpoint) {
 	var vert Node
 	bps = append(bps, lastNode)
 	for e := lastNode; e != nil; e = e.from {
-		if settings.HangingPunctuationEnd {
-			if e.Position.Type() == TypeDisc {
-				e.Position.(*Disc).Pre.(*Glyph).Width = 0
-			}
-			if glyf, ok := e.Position.Prev().(*Glyph); ok {
-				if len(glyf.Components) == 1 && unicode.IsPunct(rune(glyf.Components[0])) {
-					glyf.Width = 0
-				}
-			}
-		}
+		firstLine, lastLine := e.Line == 0, isParagraphEnd(endNode)
+		protrudeLeft := lb.protrusion(e.startGlyph, true, firstLine, lastLine)
+		protrudeRight := lb.protrusion(lineEndGlyph(endNode), false, firstLine, lastLine)
 		startPos := e.Position
 		// startPos.Prev() is nil at paragraph start
 		if startPos.Prev() != nil {
+			if d, ok := startPos.(*Disc); ok && d.Post != nil {
+				insertListAfter(d, d.Post)
+			}
 			startPos = startPos.Next()
 		}
 		if curPre != nil {
-			InsertAfter(startPos, endNode.Prev(), curPre)
+			insertListAfter(endNode.Prev(), curPre)
 		}
 		curPre = e.Pre
 		if startPos != nil {
+			if protrudeRight != 0 {
+				InsertAfter(startPos, endNode.Prev(), protrusionKern(protrudeRight))
+			}
 			// if PDF/UA is written, the line end should have a space at the end.
 			InsertAfter(startPos, endNode.Prev(), settings.LineEndGlue.Copy())
 
@@ -529,7 +708,10 @@ func Linebreak(n Node, settings *LinebreakSettings) (*VList, []*Breakpoint) {
 			leftskip := settings.LineStartGlue.Copy().(*Glue)
 			leftskip.Width += lb.getIndent(e.Line)
 			startPos = InsertBefore(startPos, startPos, leftskip)
-			hl := HpackToWithEnd(startPos, endNode.Prev(), lb.settings.HSize, FontExpansion(lb.settings.FontExpansion), SqueezeOverfullBoxes(settings.SqueezeOverfullBoxes))
+			if protrudeLeft != 0 {
+				InsertAfter(startPos, leftskip, protrusionKern(protrudeLeft))
+			}
+			hl := HpackToWithEnd(startPos, endNode.Prev(), lb.settings.HSize-lb.getIndentRight(e.Line), FontExpansion(lb.settings.FontExpansion), SqueezeOverfullBoxes(settings.SqueezeOverfullBoxes))
 			if hl.Attributes == nil {
 				hl.Attributes = H{"origin": "line"}
 			} else {
@@ -565,9 +747,72 @@ func Linebreak(n Node, settings *LinebreakSettings) (*VList, []*Breakpoint) {
 	}
 	vl := Vpack(vert)
 	vl.Attributes = H{"origin": "Linebreak"}
+	if settings.GridHeight > 0 {
+		vl = SnapToGrid(vl, settings)
+	}
 	return vl, bps
 }
 
+// gridRoundUp returns the smallest multiple of grid which is not less than
+// pos.
+func gridRoundUp(pos, grid bag.ScaledPoint) bag.ScaledPoint {
+	n := pos / grid
+	if n*grid < pos {
+		n++
+	}
+	return n * grid
+}
+
+// SnapToGrid places the baselines of the lines in the vertical list vl on the
+// baseline grid with the distance settings.GridHeight. The origin of the grid
+// is settings.GridOffset above the top of vl. Two baselines are at least
+// settings.LineHeight apart, so taller lines occupy more than one grid line.
+// The returned list ends at the last baseline, the depth of the last line is
+// the depth of the list.
+func SnapToGrid(vl *VList, settings *LinebreakSettings) *VList {
+	grid := settings.GridHeight
+	if grid <= 0 || vl.List == nil {
+		return vl
+	}
+	head := vl.List
+	// pos is the distance from the grid origin to the bottom of the previous
+	// line.
+	pos := settings.GridOffset
+	var prevBaseline bag.ScaledPoint
+	first := true
+	for e := head; e != nil; {
+		next := e.Next()
+		switch t := e.(type) {
+		case *HList:
+			minBaseline := pos + t.Height
+			if !first {
+				minBaseline = bag.Max(minBaseline, prevBaseline+settings.LineHeight)
+			}
+			baseline := gridRoundUp(minBaseline, grid)
+			skip := baseline - t.Height - pos
+			if g, ok := t.Prev().(*Glue); ok && (g.Attributes["origin"] == "lineskip" || g.Attributes["origin"] == "grid") {
+				g.Width = skip
+			} else {
+				g := NewGlue()
+				g.Width = skip
+				g.Attributes = H{"origin": "grid"}
+				head = InsertBefore(head, t, g)
+			}
+			pos = baseline + t.Depth
+			prevBaseline = baseline
+			first = false
+		case *Glue:
+			if t.Attributes["origin"] == "last lineskip" {
+				head = DeleteFromList(head, t)
+			}
+		}
+		e = next
+	}
+	ret := Vpack(head)
+	ret.Attributes = vl.Attributes
+	return ret
+}
+
 // AppendLineEndAfter adds a penalty 10000, glue 0pt plus 1fil, penalty -10000
 // after n (the node lists starting with head). It returns the new head (if head
 // is nil) and the penalty node (the tail of the list).
@@ -217,8 +217,10 @@ func IsNode(arg any) bool {
 	return false
 }
 
-// A Disc represents a hyphenation point. Currently only the Penalty field is
-// used.
+// A Disc represents a discretionary break such as a hyphenation point. If the
+// line is broken at the Disc, Pre is placed at the end of the line and Post at
+// the beginning of the next line. Otherwise the Replace material is typeset.
+// All three node lists may be empty.
 type Disc struct {
 	basenode
 	Pre     Node
@@ -318,6 +320,10 @@ type Glyph struct {
 	YOffset bag.ScaledPoint
 	// This allows the glyph to be part of word hyphenation.
 	Hyphenate bool
+	// Expansion is the horizontal scaling of the glyph in thousandths of its
+	// width set by the font expansion. The Width does not include the
+	// expansion.
+	Expansion int
 }
 
 func (g *Glyph) String() string {
@@ -370,7 +376,7 @@ func (g *Glyph) Copy() Node {
 	n.Depth = g.Depth
 	n.Hyphenate = g.Hyphenate
 	n.YOffset = g.YOffset
-	n.Hyphenate = g.Hyphenate
+	n.Expansion = g.Expansion
 	return n
 }
 
@@ -502,7 +508,7 @@ type HList struct {
 	GlueSet   float64         // The ratio of the glue. Positive means stretching, negative shrinking.
 	GlueSign  uint8           // 0 = normal, 1 = stretching, 2 = shrinking
 	GlueOrder GlueOrder       // The level of infinity
-	Shift     bag.ScaledPoint // The displacement perpendicular to the progressing direction. Not used.
+	Shift     bag.ScaledPoint // The displacement perpendicular to the progressing direction. In a horizontal list a positive value moves the box down.
 	List      Node            // The list itself.
 	VAlign    VerticalAlignment
 	basenode
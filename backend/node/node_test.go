@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
 )
 
 type gluTestData struct {
@@ -94,6 +95,27 @@ func TestHpack(t *testing.T) {
 	}
 }
 
+func TestHpackShift(t *testing.T) {
+	inner := NewHList()
+	inner.Width = 10 * bag.Factor
+	inner.Height = 8 * bag.Factor
+	inner.Depth = 2 * bag.Factor
+	inner.Shift = 3 * bag.Factor
+	g := NewGlyph()
+	g.Height = 7 * bag.Factor
+	g.Depth = 2 * bag.Factor
+	InsertAfter(g, g, inner)
+
+	for _, hl := range []*HList{Hpack(g), HpackTo(g, 20*bag.Factor)} {
+		if want := 7 * bag.Factor; hl.Height != want {
+			t.Errorf("hl.Height = %s, want %s", hl.Height, want)
+		}
+		if want := 5 * bag.Factor; hl.Depth != want {
+			t.Errorf("hl.Depth = %s, want %s", hl.Depth, want)
+		}
+	}
+}
+
 func TestLinebreak(t *testing.T) {
 	str := `In olden times when wish|ing still helped one, there lived a king whose daugh|ters
 were all beau|ti|ful; and the young|est was so beau|ti|ful that the sun it|self, which
@@ -250,3 +272,461 @@ func TestLinebreakOneWord(t *testing.T) {
 		}
 	}
 }
+
+func TestLinebreakHyphenateLimitLines(t *testing.T) {
+	// 40 words with six glyphs each and a hyphenation point in the middle
+	mkList := func() Node {
+		var head, cur Node
+		for w := 0; w < 40; w++ {
+			if w > 0 {
+				g := NewGlue()
+				g.Width = 6 * bag.Factor
+				g.Stretch = 3 * bag.Factor
+				g.Shrink = 2 * bag.Factor
+				head = InsertAfter(head, cur, g)
+				cur = g
+			}
+			for i := 0; i < 6; i++ {
+				if i == 3 {
+					d := NewDisc()
+					hyphen := NewGlyph()
+					hyphen.Width = 5 * bag.Factor
+					d.Pre = hyphen
+					head = InsertAfter(head, cur, d)
+					cur = d
+				}
+				g := NewGlyph()
+				g.Width = 10 * bag.Factor
+				head = InsertAfter(head, cur, g)
+				cur = g
+			}
+		}
+		AppendLineEndAfter(head, cur)
+		return head
+	}
+
+	maxConsecutive := func(bps []*Breakpoint) int {
+		maxHyphenated, hyphenated := 0, 0
+		for _, bp := range bps {
+			if _, ok := bp.Position.(*Disc); ok {
+				hyphenated++
+				if hyphenated > maxHyphenated {
+					maxHyphenated = hyphenated
+				}
+			} else {
+				hyphenated = 0
+			}
+		}
+		return maxHyphenated
+	}
+
+	for _, limit := range []int{0, 1} {
+		settings := NewLinebreakSettings()
+		settings.HSize = 95 * bag.Factor
+		settings.DoublehyphenDemerits = 0
+		settings.HyphenateLimitLines = limit
+		_, bps := Linebreak(mkList(), settings)
+		got := maxConsecutive(bps)
+		if limit == 0 && got < 2 {
+			t.Errorf("consecutive hyphenated lines = %d, want more than 1 without a limit", got)
+		}
+		if limit > 0 && got > limit {
+			t.Errorf("consecutive hyphenated lines = %d, want at most %d", got, limit)
+		}
+	}
+}
+
+func TestLinebreakDisc(t *testing.T) {
+	mkGlyph := func(c string, wd int) *Glyph {
+		g := NewGlyph()
+		g.Components = c
+		g.Width = bag.ScaledPoint(wd) * bag.Factor
+		return g
+	}
+	mkList := func() Node {
+		var head, cur Node
+		head = mkGlyph("A", 10)
+		cur = InsertAfter(head, head, mkGlyph("B", 10)).Next()
+		d := NewDisc()
+		d.Pre = mkGlyph("P", 10)
+		InsertAfter(d.Pre, d.Pre, mkGlyph("Q", 5))
+		d.Post = mkGlyph("R", 7)
+		d.Replace = mkGlyph("C", 10)
+		InsertAfter(head, cur, d)
+		cur = d
+		for _, c := range []string{"D", "E"} {
+			g := mkGlyph(c, 10)
+			InsertAfter(head, cur, g)
+			cur = g
+		}
+		AppendLineEndAfter(head, cur)
+		return head
+	}
+	lines := func(vl *VList) []string {
+		var ret []string
+		for e := vl.List; e != nil; e = e.Next() {
+			if hl, ok := e.(*HList); ok {
+				var sb strings.Builder
+				for c := hl.List; c != nil; c = c.Next() {
+					switch v := c.(type) {
+					case *Glyph:
+						sb.WriteString(v.Components)
+					case *Disc:
+						sb.WriteString("|")
+					}
+				}
+				ret = append(ret, sb.String())
+			}
+		}
+		return ret
+	}
+
+	testdata := []struct {
+		hsize    bag.ScaledPoint
+		expected string
+	}{
+		{100 * bag.Factor, "[AB|DE]"},
+		{35 * bag.Factor, "[ABPQ RDE]"},
+	}
+	for _, td := range testdata {
+		settings := NewLinebreakSettings()
+		settings.HSize = td.hsize
+		vl, _ := Linebreak(mkList(), settings)
+		if got := fmt.Sprint(lines(vl)); got != td.expected {
+			t.Errorf("lines = %s, want %s", got, td.expected)
+		}
+	}
+
+	head := mkList()
+	if wd, _, _ := Dimensions(head, nil, Horizontal); wd != 50*bag.Factor {
+		t.Errorf("width of the unbroken list = %s, want 50pt", wd)
+	}
+}
+
+func TestLinebreakProtrusion(t *testing.T) {
+	// "(ab. cd." with an opening bracket hanging at the start of the first
+	// line and a full stop hanging at the end of each line.
+	var head, cur Node
+	for _, c := range "(ab. cd." {
+		if c == ' ' {
+			g := NewGlue()
+			g.Width = 5 * bag.Factor
+			g.Stretch = 2 * bag.Factor
+			head = InsertAfter(head, cur, g)
+			cur = g
+			continue
+		}
+		g := NewGlyph()
+		g.Components = string(c)
+		g.Width = 10 * bag.Factor
+		head = InsertAfter(head, cur, g)
+		cur = g
+	}
+	AppendLineEndAfter(head, cur)
+
+	settings := NewLinebreakSettings()
+	// the first line "(ab." fits only if both glyphs hang into the margin
+	settings.HSize = 20 * bag.Factor
+	settings.Protrusion = func(g *Glyph, start, firstLine, lastLine bool) bag.ScaledPoint {
+		if start && firstLine && g.Components == "(" || !start && g.Components == "." {
+			return g.Width
+		}
+		return 0
+	}
+	vl, bps := Linebreak(head, settings)
+	if len(bps) != 2 {
+		t.Fatalf("len(bps) = %d, want 2", len(bps))
+	}
+	var kerns []string
+	for e := vl.List; e != nil; e = e.Next() {
+		hl, ok := e.(*HList)
+		if !ok {
+			continue
+		}
+		var line []string
+		for c := hl.List; c != nil; c = c.Next() {
+			if k, ok := c.(*Kern); ok && k.Attributes["origin"] == "margin protrusion" {
+				line = append(line, k.Kern.String())
+			}
+		}
+		kerns = append(kerns, strings.Join(line, ","))
+	}
+	if got, want := fmt.Sprint(kerns), "[-10,-10 -10]"; got != want {
+		t.Errorf("protrusion kerns = %s, want %s", got, want)
+	}
+	if r := bps[0].R; r != 0 {
+		t.Errorf("bps[0].R = %f, want 0", r)
+	}
+}
+
+func TestLinebreakGrid(t *testing.T) {
+	// "ab cd" in two lines, the c is taller than the line height.
+	var head, cur Node
+	for _, c := range "ab cd" {
+		if c == ' ' {
+			g := NewGlue()
+			g.Width = 5 * bag.Factor
+			g.Stretch = 2 * bag.Factor
+			head = InsertAfter(head, cur, g)
+			cur = g
+			continue
+		}
+		g := NewGlyph()
+		g.Components = string(c)
+		g.Width = 10 * bag.Factor
+		g.Height = 8 * bag.Factor
+		g.Depth = 2 * bag.Factor
+		if c == 'c' {
+			g.Height = 20 * bag.Factor
+		}
+		head = InsertAfter(head, cur, g)
+		cur = g
+	}
+	AppendLineEndAfter(head, cur)
+
+	settings := NewLinebreakSettings()
+	settings.HSize = 20 * bag.Factor
+	settings.LineHeight = 12 * bag.Factor
+	settings.GridHeight = 12 * bag.Factor
+	settings.GridOffset = 5 * bag.Factor
+	vl, _ := Linebreak(head, settings)
+	var skips []string
+	for e := vl.List; e != nil; e = e.Next() {
+		if g, ok := e.(*Glue); ok {
+			skips = append(skips, fmt.Sprintf("%s:%s", g.Attributes["origin"], g.Width))
+		}
+	}
+	// baselines at 24pt and 48pt from the grid origin
+	if got, want := strings.Join(skips, " "), "grid:11 lineskip:2"; got != want {
+		t.Errorf("glue = %s, want %s", got, want)
+	}
+	if got, want := vl.Height, 43*bag.Factor; got != want {
+		t.Errorf("vl.Height = %s, want %s", got, want)
+	}
+	if got, want := vl.Depth, 2*bag.Factor; got != want {
+		t.Errorf("vl.Depth = %s, want %s", got, want)
+	}
+}
+
+func TestLinebreakParagraphShape(t *testing.T) {
+	// four words, 10pt wide, separated by 5pt glue
+	var head, cur Node
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			g := NewGlue()
+			g.Width = 5 * bag.Factor
+			g.Stretch = 2 * bag.Factor
+			head = InsertAfter(head, cur, g)
+			cur = g
+		}
+		g := NewGlyph()
+		g.Components = "a"
+		g.Width = 10 * bag.Factor
+		g.Height = 8 * bag.Factor
+		head = InsertAfter(head, cur, g)
+		cur = g
+	}
+	AppendLineEndAfter(head, cur)
+
+	settings := NewLinebreakSettings()
+	settings.HSize = 40 * bag.Factor
+	settings.LineHeight = 12 * bag.Factor
+	settings.ParagraphShape = func(row int) (bag.ScaledPoint, bag.ScaledPoint) {
+		switch row {
+		case 0:
+			return 0, 30 * bag.Factor
+		case 1:
+			return 15 * bag.Factor, 0
+		}
+		return 0, 0
+	}
+	vl, _ := Linebreak(head, settings)
+	var widths, glyphs []string
+	for e := vl.List; e != nil; e = e.Next() {
+		if hl, ok := e.(*HList); ok {
+			widths = append(widths, hl.Width.String())
+			n := 0
+			for f := hl.List; f != nil; f = f.Next() {
+				if _, ok := f.(*Glyph); ok {
+					n++
+				}
+			}
+			glyphs = append(glyphs, fmt.Sprint(n))
+		}
+	}
+	if got, want := strings.Join(glyphs, " "), "1 2 1"; got != want {
+		t.Errorf("glyphs per line = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(widths, " "), "10 40 40"; got != want {
+		t.Errorf("line widths = %s, want %s", got, want)
+	}
+}
+
+// testLines returns a vertical list with n lines (8pt high, 2pt deep)
+// separated by 2pt glue.
+func testLines(n int) *VList {
+	var head, cur Node
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			g := NewGlue()
+			g.Width = 2 * bag.Factor
+			head = InsertAfter(head, cur, g)
+			cur = g
+		}
+		hl := NewHList()
+		hl.Height = 8 * bag.Factor
+		hl.Depth = 2 * bag.Factor
+		head = InsertAfter(head, cur, hl)
+		cur = hl
+	}
+	return Vpack(head)
+}
+
+func countLines(vl *VList) int {
+	if vl == nil {
+		return 0
+	}
+	lines := 0
+	for e := vl.List; e != nil; e = e.Next() {
+		if _, ok := e.(*HList); ok {
+			lines++
+		}
+	}
+	return lines
+}
+
+func TestVsplit(t *testing.T) {
+	first, rest := Vsplit(testLines(10), 35*bag.Factor)
+	if got, want := countLines(first), 3; got != want {
+		t.Errorf("countLines(first) = %d, want %d", got, want)
+	}
+	if got, want := first.Height+first.Depth, 34*bag.Factor; got != want {
+		t.Errorf("first height = %s, want %s", got, want)
+	}
+	if got, want := countLines(rest), 7; got != want {
+		t.Errorf("countLines(rest) = %d, want %d", got, want)
+	}
+	if _, ok := rest.List.(*HList); !ok {
+		t.Errorf("rest starts with %T, want *HList", rest.List)
+	}
+
+	// a penalty of 10000 prohibits the break after the third line
+	vl := testLines(10)
+	p := NewPenalty()
+	p.Penalty = 10000
+	third := vl.List.Next().Next().Next().Next()
+	InsertBefore(vl.List, third.Next(), p)
+	first, _ = Vsplit(vl, 35*bag.Factor)
+	if got, want := countLines(first), 2; got != want {
+		t.Errorf("countLines(first) = %d, want %d", got, want)
+	}
+
+	first, rest = Vsplit(testLines(2), 35*bag.Factor)
+	if countLines(first) != 2 || rest != nil {
+		t.Errorf("Vsplit of a fitting list: got %d lines and rest %v", countLines(first), rest)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	vl := testLines(10)
+	cols, rest := Columns(vl, 3, 100*bag.Factor, true)
+	if rest != nil {
+		t.Fatalf("rest = %v, want nil", rest)
+	}
+	var lines []int
+	for _, col := range cols {
+		lines = append(lines, countLines(col))
+	}
+	if got, want := fmt.Sprint(lines), "[4 4 2]"; got != want {
+		t.Errorf("lines per column = %s, want %s", got, want)
+	}
+	if got := countLines(vl); got != 10 {
+		t.Errorf("Columns changed the list, %d lines left", got)
+	}
+
+	cols, rest = Columns(vl, 2, 22*bag.Factor, true)
+	if len(cols) != 2 || countLines(rest) != 6 {
+		t.Errorf("got %d columns and %d lines left, want 2 and 6", len(cols), countLines(rest))
+	}
+}
+
+func TestHpackFontExpansion(t *testing.T) {
+	fnt := &font.Font{Expansion: &font.Expansion{
+		Stretch: 20,
+		Shrink:  20,
+		Step:    5,
+		Factors: map[rune]int{'.': 0},
+	}}
+	var head, cur Node
+	var glyphs []*Glyph
+	for _, c := range "abc." {
+		g := NewGlyph()
+		g.Font = fnt
+		g.Components = string(c)
+		g.Width = 100 * bag.Factor
+		head = InsertAfter(head, cur, g)
+		cur = g
+		glyphs = append(glyphs, g)
+	}
+	testdata := []struct {
+		width    bag.ScaledPoint
+		expected string
+	}{
+		// ratio 5/6 of 20 is rounded to 15
+		{405 * bag.Factor, "[15 15 15 0]"},
+		{396 * bag.Factor, "[-15 -15 -15 0]"},
+		// at most 20
+		{450 * bag.Factor, "[20 20 20 0]"},
+		{400 * bag.Factor, "[0 0 0 0]"},
+	}
+	for _, td := range testdata {
+		HpackToWithEnd(head, cur, td.width, FontExpansion(0.05))
+		var got []int
+		for _, g := range glyphs {
+			got = append(got, g.Expansion)
+		}
+		if fmt.Sprint(got) != td.expected {
+			t.Errorf("expansion = %v, want %s", got, td.expected)
+		}
+	}
+}
+
+func TestLineEdgeGlyphs(t *testing.T) {
+	// a. b with kerns between the glyphs and the break
+	var head, cur Node
+	add := func(n Node) Node {
+		head = InsertAfter(head, cur, n)
+		cur = n
+		return n
+	}
+	kern := func() {
+		k := NewKern()
+		k.Kern = bag.Factor
+		add(k)
+	}
+	glyph := func(c string) Node {
+		g := NewGlyph()
+		g.Components = c
+		g.Width = 10 * bag.Factor
+		return add(g)
+	}
+	glyph("a")
+	stop := glyph(".")
+	kern()
+	brk := add(NewGlue())
+	kern()
+	start := glyph("b")
+
+	if g := lineEndGlyph(brk); g != stop {
+		t.Errorf("lineEndGlyph() = %v, want the full stop", g)
+	}
+	if g := lineStartGlyph(brk); g != start {
+		t.Errorf("lineStartGlyph() = %v, want b", g)
+	}
+	r := NewRule()
+	r.Width = bag.Factor
+	InsertBefore(head, brk, r)
+	if g := lineEndGlyph(brk); g != nil {
+		t.Errorf("lineEndGlyph() with a rule = %v, want nil", g)
+	}
+}
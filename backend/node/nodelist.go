@@ -3,8 +3,10 @@ package node
 import (
 	"fmt"
 	"math"
+	"unicode/utf8"
 
 	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
 	"github.com/speedata/boxesandglue/frontend/pdfdraw"
 )
 
@@ -25,15 +27,71 @@ type LinebreakSettings struct {
 	DoublehyphenDemerits  int
 	HangingPunctuationEnd bool
 	FontExpansion         float64
-	HSize                 bag.ScaledPoint
-	Hyphenpenalty         int
-	Indent                bag.ScaledPoint
-	IndentRows            int
-	LineEndGlue           *Glue
-	LineHeight            bag.ScaledPoint
-	LineStartGlue         *Glue
-	OmitLastLeading       bool
-	Tolerance             float64
+	// GridHeight is the distance of the lines of a baseline grid. If it is
+	// greater than 0, the baselines of the lines are placed on multiples of
+	// GridHeight (see SnapToGrid).
+	GridHeight bag.ScaledPoint
+	// GridOffset is the distance from the origin of the baseline grid to the
+	// top of the paragraph.
+	GridOffset          bag.ScaledPoint
+	HSize               bag.ScaledPoint
+	ExHyphenpenalty     int // penalty for a break at a Disc without Pre material
+	Hyphenpenalty       int
+	HyphenateLimitLines int // maximum number of consecutive hyphenated lines, 0 = no limit
+	Indent              bag.ScaledPoint
+	IndentRows          int
+	LineEndGlue         *Glue
+	LineHeight          bag.ScaledPoint
+	LineStartGlue       *Glue
+	OmitLastLeading     bool
+	// ParagraphShape returns additional indentations for the lines of the
+	// paragraph. Nil means no additional indentation.
+	ParagraphShape ParagraphShapeFunc
+	// Protrusion returns the amount the glyphs at the start and at the end
+	// of the lines protrude into the margin. Nil means no protrusion.
+	Protrusion ProtrusionFunc
+	Tolerance  float64
+}
+
+// A ParagraphShapeFunc returns the space which is not available for the line
+// row (starting at 0) on the left and on the right side. The line is set in
+// the remaining width.
+type ParagraphShapeFunc func(row int) (left, right bag.ScaledPoint)
+
+// A ProtrusionFunc returns the amount the glyph g protrudes into the margin at
+// the start of a line (start is true) or at the end of a line. firstLine and
+// lastLine are true for the first and the last line of the paragraph.
+type ProtrusionFunc func(g *Glyph, start, firstLine, lastLine bool) bag.ScaledPoint
+
+// FontProtrusion is a ProtrusionFunc which uses the protrusion table of the
+// glyph's font.
+func FontProtrusion(g *Glyph, start, firstLine, lastLine bool) bag.ScaledPoint {
+	if g.Font == nil {
+		return 0
+	}
+	return ProtrusionFromTable(g, g.Font.Protrusion, start)
+}
+
+// ProtrusionFromTable returns the amount the glyph g protrudes into the margin
+// at the start or at the end of a line according to the protrusion table.
+func ProtrusionFromTable(g *Glyph, table map[rune]font.Protrusion, start bool) bag.ScaledPoint {
+	if len(table) == 0 || g.Components == "" {
+		return 0
+	}
+	var r rune
+	if start {
+		r, _ = utf8.DecodeRuneInString(g.Components)
+	} else {
+		r, _ = utf8.DecodeLastRuneInString(g.Components)
+	}
+	p, ok := table[r]
+	if !ok {
+		return 0
+	}
+	if start {
+		return g.Width * bag.ScaledPoint(p.Left) / 1000
+	}
+	return g.Width * bag.ScaledPoint(p.Right) / 1000
 }
 
 // NewLinebreakSettings returns a settings struct with defaults initialized.
@@ -41,6 +99,7 @@ func NewLinebreakSettings() *LinebreakSettings {
 	ls := &LinebreakSettings{
 		DoublehyphenDemerits: 3000,
 		DemeritsFitness:      100,
+		ExHyphenpenalty:      50,
 		Hyphenpenalty:        50,
 		Tolerance:            positiveInf,
 		LineStartGlue:        NewGlue(),
@@ -111,6 +170,19 @@ func InsertBefore(head, cur, insert Node) Node {
 	return head
 }
 
+// insertListAfter inserts the node list starting with list after cur. It
+// returns the last node of the inserted list.
+func insertListAfter(cur, list Node) Node {
+	tail := Tail(list)
+	if curNext := cur.Next(); curNext != nil {
+		tail.SetNext(curNext)
+		curNext.SetPrev(tail)
+	}
+	cur.SetNext(list)
+	list.SetPrev(cur)
+	return tail
+}
+
 // Tail returns the last node of a node list.
 func Tail(nl Node) Node {
 	if nl == nil {
@@ -209,14 +281,19 @@ func Hpack(firstNode Node) *HList {
 			sumwd = sumwd + v.Width
 		case *HList:
 			sumwd = sumwd + v.Width
-			if v.Height > maxht {
-				maxht = v.Height
+			if ht := v.Height - v.Shift; ht > maxht {
+				maxht = ht
 			}
-			if v.Depth > maxdp {
-				maxdp = v.Depth
+			if dp := v.Depth + v.Shift; dp > maxdp {
+				maxdp = dp
 			}
 		case *Kern:
 			sumwd += v.Kern
+		case *Disc:
+			wd, ht, dp := Dimensions(v.Replace, nil, Horizontal)
+			sumwd += wd
+			maxht = bag.Max(maxht, ht)
+			maxdp = bag.Max(maxdp, dp)
 		case *Lang:
 		case *Penalty:
 			sumwd += v.Width
@@ -267,15 +344,15 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 		opt(hs)
 	}
 	glues := []*Glue{}
+	glyphs := []*Glyph{}
 
 	sumwd := bag.ScaledPoint(0)
-	sumGlyph := bag.ScaledPoint(0)
 	maxht := bag.ScaledPoint(0)
 	maxdp := bag.ScaledPoint(0)
 
 	totalStretchability := [4]bag.ScaledPoint{0, 0, 0, 0}
 	totalShrinkability := [4]bag.ScaledPoint{0, 0, 0, 0}
-	totalExtend := bag.ScaledPoint(0)
+	var fontStretch, fontShrink bag.ScaledPoint
 
 	for e := firstNode; e != nil; e = e.Next() {
 		switch v := e.(type) {
@@ -292,11 +369,13 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 			if v.Depth > maxdp {
 				maxdp = v.Depth
 			}
+			v.Expansion = 0
 			if hs.fontexpansion != 0 {
-				extend := bag.MultiplyFloat(v.Width, hs.fontexpansion)
-				totalExtend += extend
+				stretch, shrink := glyphExpansionLimits(v, hs.fontexpansion)
+				fontStretch += stretch
+				fontShrink += shrink
+				glyphs = append(glyphs, v)
 			}
-			sumGlyph += v.Width
 		case *Rule:
 			sumwd += v.Width
 			if v.Height > maxht {
@@ -317,7 +396,7 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 
 		default:
 			sumwd += getWidth(v, Horizontal)
-			ht, dp := getHeight(v, Vertical)
+			ht, dp := getHeight(v, Horizontal)
 			if ht > maxht {
 				maxht = ht
 			}
@@ -348,6 +427,17 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 			shrinkability = totalShrinkability[i]
 		}
 	}
+	// The glyphs are expanded in the same ratio as the glue is stretched or
+	// shrunk. Glue with infinite stretchability or shrinkability takes all the
+	// adjustment.
+	if sumwd < width && highestOrderStretch == StretchNormal && fontStretch > 0 {
+		ratio := math.Min(1, float64(width-sumwd)/float64(stretchability+fontStretch))
+		sumwd += expandGlyphs(glyphs, ratio, hs.fontexpansion)
+	} else if sumwd > width && highestOrderShrink == StretchNormal && fontShrink > 0 {
+		ratio := math.Max(-1, float64(width-sumwd)/float64(shrinkability+fontShrink))
+		sumwd += expandGlyphs(glyphs, ratio, hs.fontexpansion)
+	}
+
 	var r float64
 	if width == sumwd {
 		r = 1
@@ -368,13 +458,6 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 			badness = 10000
 		}
 	}
-	useExpand := false
-	if hs.fontexpansion != 0 {
-		if r < -1 {
-			r = -1
-			useExpand = true
-		}
-	}
 	for _, g := range glues {
 		if r >= 0 && highestOrderStretch == g.StretchOrder {
 			g.Width += bag.ScaledPoint(r * float64(g.Stretch))
@@ -393,13 +476,54 @@ func HpackToWithEnd(firstNode Node, lastNode Node, width bag.ScaledPoint, opts .
 	hl.Height = maxht
 	hl.GlueSet = r
 	hl.Badness = badness
-	if useExpand {
-		a := (sumwd - width - shrinkability).ToPT() / sumGlyph.ToPT()
-		hl.Attributes = H{"expand": int(-1 * a * 100)}
-	}
 	return hl
 }
 
+// glyphExpansion returns the maximum stretch and shrink of the glyph g in
+// thousandths of its width and the step size of the expansion. amount (0-1)
+// is the expansion of the paragraph which applies if the font has no
+// expansion settings.
+func glyphExpansion(g *Glyph, amount float64) (int, int, int) {
+	if g.Font == nil || g.Font.Expansion == nil {
+		limit := int(math.Round(amount * 1000))
+		return limit, limit, 0
+	}
+	e := g.Font.Expansion
+	r, _ := utf8.DecodeRuneInString(g.Components)
+	factor := e.Factor(r)
+	return e.Stretch * factor / 1000, e.Shrink * factor / 1000, e.Step
+}
+
+// glyphExpansionLimits returns the maximum amount the glyph g can be
+// stretched and shrunk.
+func glyphExpansionLimits(g *Glyph, amount float64) (bag.ScaledPoint, bag.ScaledPoint) {
+	stretch, shrink, _ := glyphExpansion(g, amount)
+	return g.Width * bag.ScaledPoint(stretch) / 1000, g.Width * bag.ScaledPoint(shrink) / 1000
+}
+
+// expandGlyphs sets the expansion of the glyphs to ratio (-1 to 1) of their
+// maximum shrink or stretch and returns the sum of the additional widths.
+func expandGlyphs(glyphs []*Glyph, ratio float64, amount float64) bag.ScaledPoint {
+	var sum bag.ScaledPoint
+	for _, g := range glyphs {
+		stretch, shrink, step := glyphExpansion(g, amount)
+		limit := stretch
+		if ratio < 0 {
+			limit = shrink
+		}
+		exp := ratio * float64(limit)
+		if step > 0 {
+			exp = math.Round(exp/float64(step)) * float64(step)
+			if math.Abs(exp) > float64(limit) {
+				exp -= math.Copysign(float64(step), exp)
+			}
+		}
+		g.Expansion = int(math.Round(exp))
+		sum += g.Width * bag.ScaledPoint(g.Expansion) / 1000
+	}
+	return sum
+}
+
 // Vpack creates a list
 func Vpack(firstNode Node) *VList {
 	sumht := bag.ScaledPoint(0)
@@ -460,7 +584,10 @@ func getWidth(n Node, dir Direction) bag.ScaledPoint {
 		return t.Kern
 	case *VList:
 		return t.Width
-	case *StartStop, *Disc, *Lang:
+	case *Disc:
+		wd, _, _ := Dimensions(t.Replace, nil, dir)
+		return wd
+	case *StartStop, *Lang:
 		return 0
 	default:
 		bag.Logger.Error(fmt.Sprintf("getWidth: unknown node type %T", n))
@@ -474,6 +601,9 @@ func getWidth(n Node, dir Direction) bag.ScaledPoint {
 func getHeight(n Node, dir Direction) (bag.ScaledPoint, bag.ScaledPoint) {
 	switch t := n.(type) {
 	case *HList:
+		if dir == Horizontal {
+			return t.Height - t.Shift, t.Depth + t.Shift
+		}
 		return t.Height, t.Depth
 	case *Glyph:
 		return t.Height, t.Depth
@@ -488,10 +618,13 @@ func getHeight(n Node, dir Direction) (bag.ScaledPoint, bag.ScaledPoint) {
 			return t.Width, 0
 		}
 		return 0, 0
-	case *StartStop, *Disc, *Lang, *Penalty, *Kern:
+	case *Disc:
+		_, ht, dp := Dimensions(t.Replace, nil, dir)
+		return ht, dp
+	case *StartStop, *Lang, *Penalty, *Kern:
 		return 0, 0
 	default:
-		bag.Logger.Error("getHeight: unknown node type %T", n)
+		bag.Logger.Error(fmt.Sprintf("getHeight: unknown node type %T", n))
 	}
 	return 0, 0
 }
@@ -504,7 +637,10 @@ func getDepth(n Node) bag.ScaledPoint {
 		return t.Depth
 	case *Rule:
 		return t.Depth
-	case *StartStop, *Disc, *Lang, *Glue, *Penalty, *Kern:
+	case *Disc:
+		_, _, dp := Dimensions(t.Replace, nil, Horizontal)
+		return dp
+	case *StartStop, *Lang, *Glue, *Penalty, *Kern:
 		return 0
 	case *VList:
 		return t.Depth
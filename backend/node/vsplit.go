@@ -0,0 +1,127 @@
+package node
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+// isBox returns true if n is visible material which cannot be discarded at a
+// break.
+func isBox(n Node) bool {
+	switch n.(type) {
+	case *HList, *VList, *Rule, *Image, *Glyph:
+		return true
+	}
+	return false
+}
+
+// Vsplit breaks the vertical list vl into two parts, so that the first part is
+// not higher than height. Legal breakpoints are glue nodes which follow a box
+// and penalties below 10000. Vsplit breaks at the last legal breakpoint which
+// fits or at the first penalty of -10000 or less. If not even the first
+// breakpoint fits, the list is broken there. The glue, kerns and penalties at
+// the start of the second part are discarded. The second part is nil if the
+// whole list fits or if it cannot be broken. The list of vl is changed.
+func Vsplit(vl *VList, height bag.ScaledPoint) (*VList, *VList) {
+	if vl == nil || vl.List == nil {
+		return vl, nil
+	}
+	var best Node
+	var sumHeight bag.ScaledPoint
+	prevBox := false
+	fits := true
+	for e := vl.List; e != nil; e = e.Next() {
+		breakable := false
+		forced := false
+		switch t := e.(type) {
+		case *Glue:
+			breakable = prevBox
+		case *Penalty:
+			breakable = t.Penalty < 10000
+			forced = t.Penalty <= -10000
+		}
+		if breakable && e != vl.List {
+			if sumHeight > height {
+				if best == nil {
+					best = e
+				}
+				fits = false
+				break
+			}
+			best = e
+			if forced {
+				fits = false
+				break
+			}
+		}
+		ht, dp := getHeight(e, Vertical)
+		sumHeight += ht + dp
+		prevBox = isBox(e)
+	}
+	if fits && sumHeight <= height || best == nil {
+		return vl, nil
+	}
+	rest := best
+	for rest != nil {
+		switch rest.(type) {
+		case *Glue, *Kern, *Penalty:
+			rest = rest.Next()
+			continue
+		}
+		break
+	}
+	if p := best.Prev(); p != nil {
+		p.SetNext(nil)
+	}
+	if rest != nil {
+		rest.SetPrev(nil)
+	}
+	first := Vpack(vl.List)
+	first.Attributes = vl.Attributes
+	if rest == nil {
+		return first, nil
+	}
+	second := Vpack(rest)
+	second.Attributes = vl.Attributes
+	return first, second
+}
+
+// fillColumns breaks a copy of vl into at most n columns of the given height.
+// It returns the columns and the material which does not fit.
+func fillColumns(vl *VList, n int, height bag.ScaledPoint) ([]*VList, *VList) {
+	var cols []*VList
+	rest := Vpack(CopyList(vl.List))
+	rest.Attributes = vl.Attributes
+	for i := 0; i < n && rest != nil; i++ {
+		var col *VList
+		col, rest = Vsplit(rest, height)
+		cols = append(cols, col)
+	}
+	return cols, rest
+}
+
+// Columns distributes the vertical list vl into at most n columns which are
+// not higher than height. It returns the columns and the material which does
+// not fit into the columns (nil if everything fits). If everything fits and
+// balance is true, the columns are made as short as possible, so that the
+// material is distributed evenly. vl is not changed.
+func Columns(vl *VList, n int, height bag.ScaledPoint, balance bool) ([]*VList, *VList) {
+	if vl == nil || vl.List == nil || n < 1 {
+		return nil, nil
+	}
+	cols, rest := fillColumns(vl, n, height)
+	if rest != nil || !balance || n == 1 {
+		return cols, rest
+	}
+	// the smallest height which keeps all material in n columns
+	lo := (vl.Height+vl.Depth)/bag.ScaledPoint(n) - 1
+	hi := height
+	for hi-lo > bag.Factor/10 {
+		mid := (lo + hi) / 2
+		if _, r := fillColumns(vl, n, mid); r == nil {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return fillColumns(vl, n, hi)
+}
@@ -121,6 +121,8 @@ h6              { font-size: .75em; margin: 1.67em 0 }
 h1, h2, h3, h4,
 h5, h6, b,
 strong          { font-weight: bold }
+h1, h2, h3, h4,
+h5, h6          { break-after: avoid }
 blockquote      { margin-left: 40px; margin-right: 40px }
 i, cite, em,
 var, address    { font-style: italic }
@@ -414,8 +416,25 @@ func (c *CSS) doFontFace(ff []qrule) error {
 	return nil
 }
 
+// pageSelector returns the selector of an @page rule without white space,
+// such as "chapter:first" or ":nth(2n+1)".
+func pageSelector(toks tokenstream) string {
+	var b strings.Builder
+	for _, tok := range toks {
+		switch tok.Type {
+		case scanner.S:
+			// ignore
+		case scanner.Function:
+			b.WriteString(tok.Value + "(")
+		default:
+			b.WriteString(tok.Value)
+		}
+	}
+	return b.String()
+}
+
 func (c *CSS) doPage(block *sBlock) {
-	selector := strings.Trim(block.ComponentValues.String(), " ")
+	selector := pageSelector(block.ComponentValues)
 	pg := c.Pages[selector]
 	if pg.pageareaRules == nil {
 		pg.pageareaRules = make(map[string][]qrule)
@@ -36,3 +36,22 @@ func TestNestedAtrule(t *testing.T) {
 		t.Errorf("want 3 child @ rules, got %d", len(bl.ChildAtRules[0].ChildAtRules))
 	}
 }
+
+func TestPageSelector(t *testing.T) {
+	testdata := []struct {
+		css  string
+		want string
+	}{
+		{`@page { size: a5; }`, ""},
+		{`@page :first { size: a5; }`, ":first"},
+		{`@page chapter:first { size: a5; }`, "chapter:first"},
+		{`@page :nth( 2n + 1 ) { size: a5; }`, ":nth(2n+1)"},
+		{`@page chapter:blank:left { size: a5; }`, "chapter:blank:left"},
+	}
+	for _, td := range testdata {
+		bl := consumeBlock(tokenizeCSSString(td.css), false)
+		if got := pageSelector(bl.ChildAtRules[0].ComponentValues); got != td.want {
+			t.Errorf("pageSelector(%s) = %q, want %q", td.css, got, td.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -190,6 +191,89 @@ func parseBorderAttribute(input string) (width string, style string, color strin
 	return
 }
 
+// parseTextDecorationAttribute splits "underline overline wavy 2pt red" into
+// the line(s), the style, the thickness and the color. Missing parts are
+// returned as empty strings.
+func parseTextDecorationAttribute(input string) (line string, style string, thickness string, color string) {
+	s := bufio.NewScanner(strings.NewReader(input))
+	s.Split(bufio.ScanWords)
+	var lines []string
+	for s.Scan() {
+		t := s.Text()
+		switch t {
+		case "none", "underline", "overline", "line-through":
+			lines = append(lines, t)
+			continue
+		case "solid", "double", "dotted", "dashed", "wavy":
+			style = t
+			continue
+		case "auto", "from-font":
+			thickness = t
+			continue
+		}
+		if dimen.MatchString(t) || strings.HasSuffix(t, "%") {
+			thickness = t
+			continue
+		}
+		if colorMatcher.MatchString(t) {
+			color = t
+			for s.Scan() {
+				color += " " + s.Text()
+			}
+			break
+		}
+		color = t
+	}
+	line = strings.Join(lines, " ")
+	return
+}
+
+// parseFlexAttribute returns flex-grow, flex-shrink and flex-basis of the
+// flex shorthand.
+func parseFlexAttribute(val string) (grow, shrink, basis string) {
+	switch val {
+	case "none":
+		return "0", "0", "auto"
+	case "auto":
+		return "1", "1", "auto"
+	case "initial":
+		return "0", "1", "auto"
+	}
+	grow, shrink, basis = "0", "1", "0"
+	var numbers int
+	for _, part := range strings.Fields(val) {
+		if _, err := strconv.ParseFloat(part, 64); err == nil && numbers < 2 {
+			if numbers == 0 {
+				grow = part
+			} else {
+				shrink = part
+			}
+			numbers++
+		} else {
+			basis = part
+		}
+	}
+	if numbers == 0 {
+		// flex: <basis>
+		grow = "1"
+	}
+	return
+}
+
+// parseGridPlacement splits the value of grid-row, grid-column or grid-area
+// at the slashes and returns n values. Missing values are auto.
+func parseGridPlacement(val string, n int) []string {
+	ret := make([]string, n)
+	parts := strings.Split(val, "/")
+	for i := range ret {
+		ret[i] = "auto"
+		if i < len(parts) {
+			ret[i] = strings.TrimSpace(parts[i])
+		}
+	}
+	return ret
+}
+
 // ResolveAttributes returns the resolved styles and the attributes of the node.
 // It changes "margin: 1cm;" into "margin-left: 1cm; margin-right: 1cm; ...".
 func ResolveAttributes(attrs []html.Attribute) (resolved map[string]string, attributes map[string]string, newAttributes []html.Attribute) {
@@ -268,6 +352,64 @@ func ResolveAttributes(attrs []html.Attribute) (resolved map[string]string, attr
 				html.Attribute{Key: "!" + key + "-color", Val: col},
 			)
 
+		case "column-rule":
+			wd, sty, col := parseBorderAttribute(attr.Val)
+			resolved[key+"-width"], resolved[key+"-style"], resolved[key+"-color"] = wd, sty, col
+			newAttributes = append(newAttributes,
+				html.Attribute{Key: "!" + key + "-width", Val: wd},
+				html.Attribute{Key: "!" + key + "-style", Val: sty},
+				html.Attribute{Key: "!" + key + "-color", Val: col},
+			)
+		case "columns":
+			// column-width is not supported, only the number of columns
+			for _, part := range strings.Fields(attr.Val) {
+				if _, err := strconv.Atoi(part); err == nil {
+					resolved["column-count"] = part
+					newAttributes = append(newAttributes, html.Attribute{Key: "!column-count", Val: part})
+				}
+			}
+		case "flex":
+			grow, shrink, basis := parseFlexAttribute(attr.Val)
+			resolved["flex-grow"], resolved["flex-shrink"], resolved["flex-basis"] = grow, shrink, basis
+			newAttributes = append(newAttributes,
+				html.Attribute{Key: "!flex-grow", Val: grow},
+				html.Attribute{Key: "!flex-shrink", Val: shrink},
+				html.Attribute{Key: "!flex-basis", Val: basis},
+			)
+		case "flex-flow":
+			for _, part := range strings.Fields(attr.Val) {
+				switch part {
+				case "row", "row-reverse", "column", "column-reverse":
+					resolved["flex-direction"] = part
+					newAttributes = append(newAttributes, html.Attribute{Key: "!flex-direction", Val: part})
+				default:
+					resolved["flex-wrap"] = part
+					newAttributes = append(newAttributes, html.Attribute{Key: "!flex-wrap", Val: part})
+				}
+			}
+		case "grid-area":
+			values := parseGridPlacement(attr.Val, 4)
+			for i, key := range []string{"grid-row-start", "grid-column-start", "grid-row-end", "grid-column-end"} {
+				resolved[key] = values[i]
+				newAttributes = append(newAttributes, html.Attribute{Key: "!" + key, Val: values[i]})
+			}
+		case "grid-column", "grid-row":
+			values := parseGridPlacement(attr.Val, 2)
+			resolved[attr.Key+"-start"], resolved[attr.Key+"-end"] = values[0], values[1]
+			newAttributes = append(newAttributes,
+				html.Attribute{Key: "!" + attr.Key + "-start", Val: values[0]},
+				html.Attribute{Key: "!" + attr.Key + "-end", Val: values[1]},
+			)
+		case "gap", "grid-gap":
+			rowGap, columnGap := attr.Val, attr.Val
+			if fields := strings.Fields(attr.Val); len(fields) == 2 {
+				rowGap, columnGap = fields[0], fields[1]
+			}
+			resolved["row-gap"], resolved["column-gap"] = rowGap, columnGap
+			newAttributes = append(newAttributes,
+				html.Attribute{Key: "!row-gap", Val: rowGap},
+				html.Attribute{Key: "!column-gap", Val: columnGap},
+			)
 		case "border-color":
 			values := getFourValues(attr.Val)
 			for _, loc := range toprightbottomleft {
@@ -344,19 +486,15 @@ func ResolveAttributes(attrs []html.Attribute) (resolved map[string]string, attr
 		// semi-condensed; normal; semi-expanded; expanded; extra-expanded;
 		// ultra-expanded;
 		case "text-decoration":
-			for _, part := range strings.Split(attr.Val, " ") {
-				if part == "none" || part == "underline" || part == "overline" || part == "line-through" {
-					resolved["text-decoration-line"] = part
-					newAttributes = append(newAttributes,
-						html.Attribute{Key: "!text-decoration-line", Val: part},
-					)
-
-				} else if part == "solid" || part == "double" || part == "dotted" || part == "dashed" || part == "wavy" {
-					resolved["text-decoration-style"] = part
-					newAttributes = append(newAttributes,
-						html.Attribute{Key: "!text-decoration-style", Val: part},
-					)
+			line, style, thickness, color := parseTextDecorationAttribute(attr.Val)
+			for _, kv := range [][2]string{{"text-decoration-line", line}, {"text-decoration-style", style}, {"text-decoration-thickness", thickness}, {"text-decoration-color", color}} {
+				if kv[1] == "" {
+					continue
 				}
+				resolved[kv[0]] = kv[1]
+				newAttributes = append(newAttributes,
+					html.Attribute{Key: "!" + kv[0], Val: kv[1]},
+				)
 			}
 
 		case "background":
@@ -376,7 +514,7 @@ func ResolveAttributes(attrs []html.Attribute) (resolved map[string]string, attr
 		}
 	}
 
-	if str, ok := resolved["text-decoration-line"]; ok && str != "none" {
+	if str, ok := resolved["text-decoration-line"]; ok && str != "none" && resolved["text-decoration-style"] == "" {
 		resolved["text-decoration-style"] = "solid"
 		newAttributes = append(newAttributes,
 			html.Attribute{Key: "!text-decoration-style", Val: "solid"},
@@ -37,3 +37,52 @@ func TestParseBorder(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTextDecoration(t *testing.T) {
+	testCases := []struct {
+		input     string
+		line      string
+		style     string
+		thickness string
+		color     string
+	}{
+		{"underline", "underline", "", "", ""},
+		{"underline overline", "underline overline", "", "", ""},
+		{"line-through wavy red", "line-through", "wavy", "", "red"},
+		{"underline dotted 2pt", "underline", "dotted", "2pt", ""},
+		{"overline from-font #ff0000", "overline", "", "from-font", "#ff0000"},
+		{"wavy underline rgb(0, 0, 255)", "underline", "wavy", "", "rgb(0, 0, 255)"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.input, func(t *testing.T) {
+			line, sty, thickness, col := parseTextDecorationAttribute(tC.input)
+			if line != tC.line || sty != tC.style || thickness != tC.thickness || col != tC.color {
+				t.Errorf(`parseTextDecorationAttribute(%s) got "%s|%s|%s|%s" want "%s|%s|%s|%s"`, tC.input, line, sty, thickness, col, tC.line, tC.style, tC.thickness, tC.color)
+			}
+		})
+	}
+}
+
+func TestParseFlex(t *testing.T) {
+	testCases := []struct {
+		input  string
+		grow   string
+		shrink string
+		basis  string
+	}{
+		{"none", "0", "0", "auto"},
+		{"auto", "1", "1", "auto"},
+		{"2", "2", "1", "0"},
+		{"200px", "1", "1", "200px"},
+		{"1 0 30%", "1", "0", "30%"},
+		{"3 auto", "3", "1", "auto"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.input, func(t *testing.T) {
+			grow, shrink, basis := parseFlexAttribute(tC.input)
+			if grow != tC.grow || shrink != tC.shrink || basis != tC.basis {
+				t.Errorf(`parseFlexAttribute(%s) got "%s|%s|%s" want "%s|%s|%s"`, tC.input, grow, shrink, basis, tC.grow, tC.shrink, tC.basis)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package frontend
 import (
 	"fmt"
 
+	"github.com/speedata/boxesandglue/backend/bag"
 	"github.com/speedata/boxesandglue/backend/node"
 )
 
@@ -12,12 +13,20 @@ const (
 	// CallbackPostLinebreak gets called right after the line break algorithm
 	// finishes.
 	CallbackPostLinebreak callbackType = iota
+	// CallbackPageArea gets called to get the page area of a named page
+	// master.
+	CallbackPageArea
 )
 
 // PostLinebreakCallbackFunc gets a vertical list and returns a vertical list
 // that replaces the line break list. If nil is returned the list is discarded.
 type PostLinebreakCallbackFunc func(*node.VList) *node.VList
 
+// PageAreaCallbackFunc returns the horizontal position and the width of the
+// page area of the page master name (CSS page). Blocks on another page master
+// than their parent are formatted with the width of their page area.
+type PageAreaCallbackFunc func(name string) (x, width bag.ScaledPoint)
+
 // RegisterCallback adds the callback fn to the cb slice.
 func (fe *Document) RegisterCallback(cb callbackType, fn any) error {
 	var ok bool
@@ -29,6 +38,13 @@ func (fe *Document) RegisterCallback(cb callbackType, fn any) error {
 		}
 		fe.postLinebreakCallback = append(fe.postLinebreakCallback, c)
 		return nil
+	case CallbackPageArea:
+		var c PageAreaCallbackFunc
+		if c, ok = fn.(PageAreaCallbackFunc); !ok {
+			return fmt.Errorf("incorrect callback type %T, want PageAreaCallbackFunc", fn)
+		}
+		fe.pageAreaCallback = c
+		return nil
 	}
 	return fmt.Errorf("unknown callback type %T", cb)
 }
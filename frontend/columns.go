@@ -0,0 +1,239 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/color"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/frontend/pdfdraw"
+)
+
+// ColumnRule is the value of SettingColumnRule, the line drawn between two
+// columns.
+type ColumnRule struct {
+	Width bag.ScaledPoint
+	Style BorderStyle
+	Color *color.Color
+}
+
+// ColumnSet is material of a multi-column element which is distributed into
+// columns when the pages are built. In the page box of BuildVlistInternal it
+// is the "columns" attribute of a StartStop node.
+type ColumnSet struct {
+	// List contains the material which is not placed yet.
+	List *node.VList
+	// Count is the number of columns.
+	Count int
+	// Width is the width of a column.
+	Width bag.ScaledPoint
+	// Gap is the distance between two columns.
+	Gap bag.ScaledPoint
+	// Rule is drawn in the middle of the gap.
+	Rule ColumnRule
+	// X is the horizontal position of the first column.
+	X bag.ScaledPoint
+}
+
+// Distribute fills the columns up to the given height and returns a vertical
+// list with the columns side by side. The material which does not fit stays in
+// cs.List. Distribute returns true if all material is placed, in this case
+// the columns are balanced.
+func (cs *ColumnSet) Distribute(height bag.ScaledPoint) (*node.VList, bool) {
+	cols, rest := node.Columns(cs.List, cs.Count, height, true)
+	cs.List = rest
+	var maxHeight bag.ScaledPoint
+	for _, col := range cols {
+		// the columns are aligned at the top of the hlist
+		col.Height += col.Depth
+		col.Depth = 0
+		col.Width = cs.Width
+		maxHeight = bag.Max(maxHeight, col.Height)
+	}
+	var head, cur node.Node
+	for i, col := range cols {
+		if i > 0 {
+			head, cur = cs.appendGap(head, cur, maxHeight)
+		}
+		head = node.InsertAfter(head, cur, col)
+		cur = col
+	}
+	hl := node.Hpack(head)
+	hl.Attributes = node.H{"origin": "columns"}
+	vl := node.Vpack(hl)
+	vl.Attributes = node.H{"origin": "columns"}
+	return vl, rest == nil
+}
+
+// appendGap appends the gap between two columns (and the column rule) after
+// cur.
+func (cs *ColumnSet) appendGap(head, cur node.Node, height bag.ScaledPoint) (node.Node, node.Node) {
+	ruleWidth := cs.Rule.Width
+	if !cs.Rule.Style.visible() || ruleWidth > cs.Gap {
+		ruleWidth = 0
+	}
+	k := node.NewKern()
+	k.Kern = (cs.Gap - ruleWidth) / 2
+	head = node.InsertAfter(head, cur, k)
+	cur = k
+	if ruleWidth > 0 {
+		r := node.NewRule()
+		r.Width = ruleWidth
+		r.Height = height
+		r.Hide = true
+		col := color.Color{Space: color.ColorNone}
+		if cs.Rule.Color != nil {
+			col = *cs.Rule.Color
+		}
+		r.Pre = pdfdraw.NewStandalone().ColorNonstroking(col).Rect(0, 0, ruleWidth, height).Fill().String()
+		r.Attributes = node.H{"origin": "column rule"}
+		head = node.InsertAfter(head, cur, r)
+		cur = r
+	}
+	k = node.NewKern()
+	k.Kern = cs.Gap - ruleWidth - (cs.Gap-ruleWidth)/2
+	head = node.InsertAfter(head, cur, k)
+	return head, k
+}
+
+// columnBuilder collects the items of a multi-column element which are set in
+// columns.
+type columnBuilder struct {
+	count   int
+	gap     bag.ScaledPoint
+	width   bag.ScaledPoint
+	rule    ColumnRule
+	pagebox []node.Node
+	height  bag.ScaledPoint
+}
+
+// newColumnBuilder returns a column builder if te is a multi-column element
+// or nil otherwise.
+func newColumnBuilder(te *Text, hsize bag.ScaledPoint) *columnBuilder {
+	count, _ := te.Settings[SettingColumnCount].(int)
+	if count < 2 {
+		return nil
+	}
+	cb := &columnBuilder{count: count}
+	cb.gap, _ = te.Settings[SettingColumnGap].(bag.ScaledPoint)
+	cb.rule, _ = te.Settings[SettingColumnRule].(ColumnRule)
+	cb.width = (hsize - cb.gap*bag.ScaledPoint(count-1)) / bag.ScaledPoint(count)
+	return cb
+}
+
+// isColumnSpan returns true if te spans all columns of a multi-column
+// element.
+func isColumnSpan(te *Text) bool {
+	span, _ := te.Settings[SettingColumnSpan].(bool)
+	return span
+}
+
+// flush returns a StartStop node with the collected items as a ColumnSet and
+// the height of the balanced columns. The column builder is empty afterwards.
+func (cb *columnBuilder) flush(fe *Document, x bag.ScaledPoint) (*node.StartStop, bag.ScaledPoint) {
+	vl := fe.pageboxToVList(cb.pagebox)
+	cs := &ColumnSet{
+		List:  vl,
+		Count: cb.count,
+		Width: cb.width,
+		Gap:   cb.gap,
+		Rule:  cb.rule,
+		X:     x,
+	}
+	var height bag.ScaledPoint
+	cols, _ := node.Columns(vl, cb.count, vl.Height+vl.Depth, true)
+	for _, col := range cols {
+		height = bag.Max(height, col.Height+col.Depth)
+	}
+	start := node.NewStartStop()
+	start.Attributes = node.H{"columns": cs}
+	cb.pagebox = nil
+	cb.height = 0
+	return start, height
+}
+
+// pageboxToVList converts the page box of BuildVlistInternal into a single
+// vertical list. The paragraphs are split into lines, so the list can be
+// broken between two lines.
+func (fe *Document) pageboxToVList(pagebox []node.Node) *node.VList {
+	var head, tail node.Node
+	add := func(n node.Node) {
+		head = node.InsertAfter(head, tail, n)
+		tail = n
+	}
+	skip := func(wd bag.ScaledPoint) {
+		if wd != 0 {
+			g := node.NewGlue()
+			g.Width = wd
+			add(g)
+		}
+	}
+	for _, n := range pagebox {
+		switch t := n.(type) {
+		case *node.StartStop:
+			if sd, ok := t.Attributes["shiftDown"].(bag.ScaledPoint); ok {
+				skip(sd)
+			}
+			hv, ok := t.Attributes["hv"].(HTMLValues)
+			if !ok {
+				continue
+			}
+			if t.StartNode != nil {
+				skip(hv.PaddingBottom + hv.BorderBottomWidth)
+				continue
+			}
+			// the border does not take vertical space, the contents are
+			// placed on top of it.
+			bvl := node.NewVList()
+			bvl.Width, _ = t.Attributes["hsize"].(bag.ScaledPoint)
+			bvl.Height, _ = t.Attributes["height"].(bag.ScaledPoint)
+			bvl = fe.HTMLBorder(bvl, hv)
+			wrap := node.Vpack(bvl)
+			wrap.ShiftX, _ = t.Attributes["x"].(bag.ScaledPoint)
+			wrap.Height = 0
+			wrap.Depth = 0
+			add(wrap)
+			skip(hv.PaddingTop + hv.BorderTopWidth)
+		case *node.VList:
+			x, _ := t.Attributes["x"].(bag.ScaledPoint)
+			height, ok := t.Attributes["height"].(bag.ScaledPoint)
+			if !ok {
+				height = t.Height + t.Depth
+			}
+			if isLineList(t) {
+				for e := t.List; e != nil; {
+					next := e.Next()
+					e.SetPrev(nil)
+					e.SetNext(nil)
+					if hl, ok := e.(*node.HList); ok {
+						line := node.Vpack(hl)
+						line.ShiftX = x
+						add(line)
+					} else {
+						add(e)
+					}
+					e = next
+				}
+			} else {
+				t.ShiftX = x
+				add(t)
+			}
+			skip(height - t.Height - t.Depth)
+		}
+	}
+	vl := node.Vpack(head)
+	vl.Attributes = node.H{"origin": "columns"}
+	return vl
+}
+
+// isLineList returns true if the vertical list contains only lines and glue
+// (a formatted paragraph).
+func isLineList(vl *node.VList) bool {
+	for e := vl.List; e != nil; e = e.Next() {
+		switch e.(type) {
+		case *node.HList, *node.Glue:
+			// ok
+		default:
+			return false
+		}
+	}
+	return vl.List != nil
+}
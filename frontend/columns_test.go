@@ -0,0 +1,66 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+func TestColumnSetDistribute(t *testing.T) {
+	// a paragraph with six lines (10pt high) and 2pt lineskip
+	var head, cur node.Node
+	for i := 0; i < 6; i++ {
+		if i > 0 {
+			g := node.NewGlue()
+			g.Width = 2 * bag.Factor
+			head = node.InsertAfter(head, cur, g)
+			cur = g
+		}
+		hl := node.NewHList()
+		hl.Width = 50 * bag.Factor
+		hl.Height = 10 * bag.Factor
+		head = node.InsertAfter(head, cur, hl)
+		cur = hl
+	}
+	paragraph := node.Vpack(head)
+	paragraph.Attributes = node.H{"x": bag.ScaledPoint(0)}
+
+	fe := &Document{}
+	cs := &ColumnSet{
+		List:  fe.pageboxToVList([]node.Node{paragraph}),
+		Count: 2,
+		Width: 50 * bag.Factor,
+		Gap:   10 * bag.Factor,
+		Rule:  ColumnRule{Width: 2 * bag.Factor, Style: BorderStyleSolid},
+	}
+	// two lines per column fit on the first page
+	vl, done := cs.Distribute(25 * bag.Factor)
+	if done {
+		t.Fatal("Distribute() = done, want material left")
+	}
+	hl := vl.List.(*node.HList)
+	if got, want := hl.Width, 110*bag.Factor; got != want {
+		t.Errorf("hl.Width = %s, want %s", got, want)
+	}
+	if got, want := vl.Height+vl.Depth, 22*bag.Factor; got != want {
+		t.Errorf("height = %s, want %s", got, want)
+	}
+	var rules int
+	for e := hl.List; e != nil; e = e.Next() {
+		if r, ok := e.(*node.Rule); ok && r.Attributes["origin"] == "column rule" {
+			rules++
+		}
+	}
+	if rules != 1 {
+		t.Errorf("rules = %d, want 1", rules)
+	}
+	// the last two lines are balanced (one line per column)
+	vl, done = cs.Distribute(100 * bag.Factor)
+	if !done {
+		t.Fatal("Distribute() = not done, want all material placed")
+	}
+	if got, want := vl.Height+vl.Depth, 10*bag.Factor; got != want {
+		t.Errorf("balanced height = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package cssbuilder
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/frontend"
+)
+
+// lineLimits returns the minimum number of lines of the paragraph vl at the
+// bottom (orphans) and at the top of a page (widows). The default is 2.
+func lineLimits(vl *node.VList) (int, int) {
+	orphans, widows := 2, 2
+	if n, ok := vl.Attributes["orphans"].(int); ok {
+		orphans = n
+	}
+	if n, ok := vl.Attributes["widows"].(int); ok {
+		widows = n
+	}
+	return orphans, widows
+}
+
+// splitVList breaks the paragraph or the table vl so that the first part is
+// not higher than height. On an empty page the paragraph is split even if
+// there are not enough lines for orphans and widows. It returns nil and vl
+// if vl cannot be split.
+func splitVList(vl *node.VList, height bag.ScaledPoint, emptyPage bool) (*node.VList, *node.VList) {
+	if _, ok := vl.Attributes["table"]; ok {
+		return frontend.SplitTable(vl, height)
+	}
+	orphans, widows := lineLimits(vl)
+	first, rest := frontend.SplitParagraph(vl, height, orphans, widows)
+	if first == nil && emptyPage {
+		return frontend.SplitParagraph(vl, height, 1, 1)
+	}
+	return first, rest
+}
+
+// advance returns the vertical space of the pagebox node n when it is placed
+// on a page.
+func advance(n node.Node) bag.ScaledPoint {
+	switch t := n.(type) {
+	case *node.StartStop:
+		sd, _ := t.Attributes["shiftDown"].(bag.ScaledPoint)
+		hv, ok := t.Attributes["hv"].(frontend.HTMLValues)
+		switch {
+		case !ok:
+			return sd
+		case t.StartNode == nil:
+			return sd + hv.PaddingTop + hv.BorderTopWidth
+		default:
+			return sd + hv.PaddingBottom + hv.BorderBottomWidth
+		}
+	case *node.VList:
+		ht, _ := t.Attributes["height"].(bag.ScaledPoint)
+		return ht
+	}
+	return 0
+}
+
+// blockExtent returns the index of the stop node of the block which starts at
+// the pagebox index i and the height of the block.
+func (cb *CSSBuilder) blockExtent(i int) (int, bag.ScaledPoint) {
+	start := cb.pagebox[i]
+	var sum bag.ScaledPoint
+	for j := i; j < len(cb.pagebox); j++ {
+		sum += advance(cb.pagebox[j])
+		if stop, ok := cb.pagebox[j].(*node.StartStop); ok && stop.StartNode == start {
+			return j, sum
+		}
+	}
+	return len(cb.pagebox) - 1, sum
+}
+
+// leadingHeight returns the height from the pagebox index i up to the end of
+// the orphans of the first paragraph (or the first box) and the first start
+// node on the way.
+func (cb *CSSBuilder) leadingHeight(i int) (*node.StartStop, bag.ScaledPoint) {
+	var first *node.StartStop
+	var sum bag.ScaledPoint
+	for _, n := range cb.pagebox[i:] {
+		switch t := n.(type) {
+		case *node.StartStop:
+			if _, ok := t.Attributes["columns"]; ok {
+				return first, sum
+			}
+			if first == nil && t.StartNode == nil {
+				if _, ok := t.Attributes["shiftDown"]; ok {
+					first = t
+				}
+			}
+			sum += advance(t)
+		case *node.VList:
+			orphans, _ := lineLimits(t)
+			return first, sum + bag.Max(advance(t)-t.Height-t.Depth, 0) + frontend.LinesHeight(t, orphans)
+		}
+	}
+	return first, sum
+}
+
+// keepHeight returns the height which must fit on the page for the block
+// which starts at the pagebox index i: the block and the beginning of the
+// next block if the page break between them is avoided (break-after: avoid or
+// break-before: avoid) or the block if it must not be broken (break-inside:
+// avoid). keepHeight returns 0 if the block can be broken anywhere.
+func (cb *CSSBuilder) keepHeight(i int) bag.ScaledPoint {
+	start := cb.pagebox[i].(*node.StartStop)
+	end, height := cb.blockExtent(i)
+	avoidAfter := false
+	if stop, ok := cb.pagebox[end].(*node.StartStop); ok {
+		br, _ := stop.Attributes["breakafter"].(frontend.Break)
+		avoidAfter = br == frontend.BreakAvoid
+	}
+	next, nextHeight := cb.leadingHeight(end + 1)
+	if next != nil {
+		if br, _ := next.Attributes["pagebreak"].(frontend.Break); br == frontend.BreakAvoid {
+			avoidAfter = true
+		}
+	}
+	if avoidAfter {
+		return height + nextHeight
+	}
+	if br, _ := start.Attributes["breakinside"].(frontend.Break); br == frontend.BreakAvoid {
+		return height
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package cssbuilder
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/frontend"
+)
+
+func TestKeepHeight(t *testing.T) {
+	// block returns the start node, a box of the given height and the stop
+	// node of a block with a 5pt top margin.
+	block := func(height bag.ScaledPoint, start, stop node.H) []node.Node {
+		s := node.NewStartStop()
+		s.Attributes = node.H{"shiftDown": 5 * bag.Factor}
+		for k, v := range start {
+			s.Attributes[k] = v
+		}
+		vl := node.NewVList()
+		vl.Height = height
+		vl.Attributes = node.H{"height": height}
+		e := node.NewStartStop()
+		e.Attributes = node.H{"shiftDown": bag.ScaledPoint(0)}
+		for k, v := range stop {
+			e.Attributes[k] = v
+		}
+		e.StartNode = s
+		return []node.Node{s, vl, e}
+	}
+	avoid := node.H{"breakafter": frontend.BreakAvoid}
+	testdata := []struct {
+		name    string
+		pagebox [][]node.Node
+		want    bag.ScaledPoint
+	}{
+		{"auto", [][]node.Node{block(20*bag.Factor, nil, nil), block(30*bag.Factor, nil, nil)}, 0},
+		{"inside", [][]node.Node{block(20*bag.Factor, node.H{"breakinside": frontend.BreakAvoid}, nil)}, 25 * bag.Factor},
+		{"after", [][]node.Node{block(20*bag.Factor, nil, avoid), block(30*bag.Factor, nil, nil)}, 60 * bag.Factor},
+		{"before", [][]node.Node{block(20*bag.Factor, nil, nil), block(30*bag.Factor, node.H{"pagebreak": frontend.BreakAvoid}, nil)}, 60 * bag.Factor},
+	}
+	for _, td := range testdata {
+		cb := &CSSBuilder{}
+		for _, b := range td.pagebox {
+			cb.pagebox = append(cb.pagebox, b...)
+		}
+		if got := cb.keepHeight(0); got != td.want {
+			t.Errorf("%s: keepHeight() = %s, want %s", td.name, got, td.want)
+		}
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/document"
 	"github.com/speedata/boxesandglue/backend/node"
 	"github.com/speedata/boxesandglue/csshtml"
 	"github.com/speedata/boxesandglue/frontend"
@@ -25,6 +29,41 @@ type CSSBuilder struct {
 	frontend              *frontend.Document
 	css                   *csshtml.CSS
 	stylesStack           htmlstyle.StylesStack
+	// counters contains the CSS counters which continue from one HTML chunk
+	// to the next.
+	counters htmlstyle.Counters
+	// fixed contains the boxes with position: fixed which are placed on
+	// every page.
+	fixed []*frontend.PositionedBox
+	// pageNumber is the value of the page counter of the current page.
+	pageNumber int
+	// deferred contains the pages which wait for the total number of pages
+	// to place their margin boxes.
+	deferred []deferredPage
+	// namedStrings and runningElements contain the values for the page
+	// margin boxes assigned on the current page.
+	namedStrings    pageValues
+	runningElements pageValues
+	// pageStarted is true if contents have been placed on the current page.
+	pageStarted bool
+	// pageName is the page master name (CSS page) of the current page.
+	pageName string
+	// groupNumber is the number of the current page within the pages with
+	// the same page name, blank pages are not counted.
+	groupNumber int
+	// blank is true if the current page is a blank page inserted by a left
+	// or right page break.
+	blank bool
+	// pendingBreak is the forced page break after the last block which is
+	// inserted before the next contents.
+	pendingBreak frontend.Break
+}
+
+// deferredPage is a page whose margin boxes are placed in Finish.
+type deferredPage struct {
+	page       *document.Page
+	dimensions PageDimensions
+	margins    marginContext
 }
 
 // New creates an instance of the CSSBuilder.
@@ -33,9 +72,14 @@ func New(fd *frontend.Document, c *csshtml.CSS) *CSSBuilder {
 		css:         c,
 		frontend:    fd,
 		stylesStack: make(htmlstyle.StylesStack, 0),
+		counters:    make(htmlstyle.Counters),
 		pagebox:     []node.Node{},
+
+		namedStrings:    newPageValues(),
+		runningElements: newPageValues(),
 	}
 	cb.css.FrontendDocument = fd
+	fd.RegisterCallback(frontend.CallbackPageArea, frontend.PageAreaCallbackFunc(cb.pageArea))
 
 	return &cb
 }
@@ -55,149 +99,187 @@ type PageDimensions struct {
 	masterpage    *csshtml.Page
 }
 
-func (cb *CSSBuilder) getPageType() *csshtml.Page {
-	if first, ok := cb.css.Pages[":first"]; ok && len(cb.frontend.Doc.Pages) == 0 {
-		return &first
-	}
-	isRight := len(cb.frontend.Doc.Pages)%2 == 0
-	if right, ok := cb.css.Pages[":right"]; ok && isRight {
-		return &right
-	}
-	if left, ok := cb.css.Pages[":left"]; ok && !isRight {
-		return &left
-	}
-	if allPages, ok := cb.css.Pages[""]; ok {
-		return &allPages
-	}
-	return nil
-}
-
 // InitPage makes sure that there is a valid page in the frontend.
 func (cb *CSSBuilder) InitPage() error {
 	if cb.frontend.Doc.CurrentPage != nil {
 		return nil
 	}
+	return cb.initPage()
+}
+
+// pageDimensions returns the dimensions of a page with the page master, the
+// resolved attributes of the page master and its styles. Without a page
+// master the page is A4 with 1cm margins and the attributes and the styles
+// are nil.
+func (cb *CSSBuilder) pageDimensions(master *csshtml.Page) (PageDimensions, map[string]string, *htmlstyle.FormattingStyles, error) {
+	if master == nil {
+		wd, ht := bag.MustSp("210mm"), bag.MustSp("297mm")
+		return PageDimensions{
+			Width:         wd,
+			Height:        ht,
+			ContentWidth:  wd - 2*onecm,
+			ContentHeight: ht - 2*onecm,
+			PageAreaLeft:  onecm,
+			PageAreaTop:   onecm,
+			MarginTop:     onecm,
+			MarginBottom:  onecm,
+			MarginLeft:    onecm,
+			MarginRight:   onecm,
+		}, nil, nil, nil
+	}
 	var err error
-	if defaultPage := cb.getPageType(); defaultPage != nil {
-		wdStr, htStr := csshtml.PapersizeWidthHeight(defaultPage.Papersize)
-		var wd, ht, mt, mb, ml, mr bag.ScaledPoint
-		if wd, err = bag.Sp(wdStr); err != nil {
-			return err
-		}
-		if ht, err = bag.Sp(htStr); err != nil {
-			return err
-		}
-		if str := defaultPage.MarginTop; str == "" {
-			mt = onecm
-		} else {
-			if mt, err = bag.Sp(str); err != nil {
-				return err
-			}
+	wdStr, htStr := csshtml.PapersizeWidthHeight(master.Papersize)
+	var wd, ht, mt, mb, ml, mr bag.ScaledPoint
+	if wd, err = bag.Sp(wdStr); err != nil {
+		return PageDimensions{}, nil, nil, err
+	}
+	if ht, err = bag.Sp(htStr); err != nil {
+		return PageDimensions{}, nil, nil, err
+	}
+	if str := master.MarginTop; str == "" {
+		mt = onecm
+	} else {
+		if mt, err = bag.Sp(str); err != nil {
+			return PageDimensions{}, nil, nil, err
 		}
-		if str := defaultPage.MarginBottom; str == "" {
-			mb = onecm
-		} else {
-			if mb, err = bag.Sp(str); err != nil {
-				return err
-			}
+	}
+	if str := master.MarginBottom; str == "" {
+		mb = onecm
+	} else {
+		if mb, err = bag.Sp(str); err != nil {
+			return PageDimensions{}, nil, nil, err
 		}
-		if str := defaultPage.MarginLeft; str == "" {
-			ml = onecm
-		} else {
-			if ml, err = bag.Sp(str); err != nil {
-				return err
-			}
+	}
+	if str := master.MarginLeft; str == "" {
+		ml = onecm
+	} else {
+		if ml, err = bag.Sp(str); err != nil {
+			return PageDimensions{}, nil, nil, err
 		}
-		if str := defaultPage.MarginRight; str == "" {
-			mr = onecm
-		} else {
-			if mr, err = bag.Sp(str); err != nil {
-				return err
-			}
+	}
+	if str := master.MarginRight; str == "" {
+		mr = onecm
+	} else {
+		if mr, err = bag.Sp(str); err != nil {
+			return PageDimensions{}, nil, nil, err
 		}
-		var res map[string]string
-		res, _, defaultPage.Attributes = csshtml.ResolveAttributes(defaultPage.Attributes)
+	}
+	var res map[string]string
+	res, _, master.Attributes = csshtml.ResolveAttributes(master.Attributes)
 
-		styles := cb.stylesStack.PushStyles()
-		if err = htmlstyle.StylesToStyles(styles, res, cb.frontend, cb.stylesStack.CurrentStyle().Fontsize); err != nil {
-			return err
-		}
-		vl := node.NewVList()
-		vl.Width = wd - ml - mr - styles.BorderLeftWidth - styles.BorderRightWidth - styles.PaddingLeft - styles.PaddingRight
-		vl.Height = ht - mt - mb - styles.PaddingTop - styles.PaddingBottom - styles.BorderTopWidth - styles.BorderBottomWidth
-		hv := frontend.HTMLValues{
-			BorderLeftWidth:         styles.BorderLeftWidth,
-			BorderRightWidth:        styles.BorderRightWidth,
-			BorderTopWidth:          styles.BorderTopWidth,
-			BorderBottomWidth:       styles.BorderBottomWidth,
-			BorderTopStyle:          styles.BorderTopStyle,
-			BorderLeftStyle:         styles.BorderLeftStyle,
-			BorderRightStyle:        styles.BorderRightStyle,
-			BorderBottomStyle:       styles.BorderBottomStyle,
-			BorderTopColor:          styles.BorderTopColor,
-			BorderLeftColor:         styles.BorderLeftColor,
-			BorderRightColor:        styles.BorderRightColor,
-			BorderBottomColor:       styles.BorderBottomColor,
-			PaddingLeft:             styles.PaddingLeft,
-			PaddingRight:            styles.PaddingRight,
-			PaddingBottom:           styles.PaddingBottom,
-			PaddingTop:              styles.PaddingTop,
-			BorderTopLeftRadius:     styles.BorderTopLeftRadius,
-			BorderTopRightRadius:    styles.BorderTopRightRadius,
-			BorderBottomLeftRadius:  styles.BorderBottomLeftRadius,
-			BorderBottomRightRadius: styles.BorderBottomRightRadius,
-		}
-		vl = cb.frontend.HTMLBorder(vl, hv)
-		cb.stylesStack.PopStyles()
+	styles := cb.stylesStack.PushStyles()
+	defer cb.stylesStack.PopStyles()
+	if err = htmlstyle.StylesToStyles(styles, res, cb.frontend, cb.stylesStack.CurrentStyle().Fontsize); err != nil {
+		return PageDimensions{}, nil, nil, err
+	}
+	return PageDimensions{
+		Width:         wd,
+		Height:        ht,
+		PageAreaLeft:  ml + styles.BorderLeftWidth + styles.PaddingLeft,
+		PageAreaTop:   mt - styles.BorderTopWidth - styles.PaddingTop,
+		ContentWidth:  wd - styles.BorderRightWidth - styles.PaddingRight - ml - mr - styles.BorderLeftWidth - styles.PaddingLeft,
+		ContentHeight: ht - styles.BorderBottomWidth - styles.PaddingBottom - mt - mb - styles.BorderTopWidth - styles.PaddingTop,
+		MarginTop:     mt,
+		MarginBottom:  mb,
+		MarginLeft:    ml,
+		MarginRight:   mr,
+		masterpage:    master,
+	}, res, styles, nil
+}
 
-		// set page width / height
-		cb.frontend.Doc.DefaultPageWidth = wd
-		cb.frontend.Doc.DefaultPageHeight = ht
-		cb.currentPageDimensions = PageDimensions{
-			Width:         wd,
-			Height:        ht,
-			PageAreaLeft:  ml + styles.BorderLeftWidth + styles.PaddingLeft,
-			PageAreaTop:   mt - styles.BorderTopWidth - styles.PaddingTop,
-			ContentWidth:  wd - styles.BorderRightWidth - styles.PaddingRight - ml - mr - styles.BorderLeftWidth - styles.PaddingLeft,
-			ContentHeight: ht - styles.BorderBottomWidth - styles.PaddingBottom - mt - mb - styles.BorderTopWidth - styles.PaddingTop,
-			MarginTop:     mt,
-			MarginBottom:  mb,
-			MarginLeft:    ml,
-			MarginRight:   mr,
-			masterpage:    defaultPage,
+// initPage starts a new page with the page master of the current page name
+// and draws the page background and the page border.
+func (cb *CSSBuilder) initPage() error {
+	if !cb.blank {
+		cb.groupNumber++
+	}
+	dim, res, styles, err := cb.pageDimensions(cb.pageMaster(cb.pageInfo()))
+	if err != nil {
+		return err
+	}
+	cb.countPage(res["counter-reset"])
+	// set page width / height
+	cb.frontend.Doc.DefaultPageWidth = dim.Width
+	cb.frontend.Doc.DefaultPageHeight = dim.Height
+	cb.currentPageDimensions = dim
+	cb.frontend.Doc.NewPage()
+	if styles == nil {
+		// no page master found
+		return nil
+	}
+	wd, ht := dim.Width, dim.Height
+	ml, mr, mt, mb := dim.MarginLeft, dim.MarginRight, dim.MarginTop, dim.MarginBottom
+	vl := node.NewVList()
+	vl.Width = wd - ml - mr - styles.BorderLeftWidth - styles.BorderRightWidth - styles.PaddingLeft - styles.PaddingRight
+	vl.Height = ht - mt - mb - styles.PaddingTop - styles.PaddingBottom - styles.BorderTopWidth - styles.BorderBottomWidth
+	hv := frontend.HTMLValues{
+		BorderLeftWidth:         styles.BorderLeftWidth,
+		BorderRightWidth:        styles.BorderRightWidth,
+		BorderTopWidth:          styles.BorderTopWidth,
+		BorderBottomWidth:       styles.BorderBottomWidth,
+		BorderTopStyle:          styles.BorderTopStyle,
+		BorderLeftStyle:         styles.BorderLeftStyle,
+		BorderRightStyle:        styles.BorderRightStyle,
+		BorderBottomStyle:       styles.BorderBottomStyle,
+		BorderTopColor:          styles.BorderTopColor,
+		BorderLeftColor:         styles.BorderLeftColor,
+		BorderRightColor:        styles.BorderRightColor,
+		BorderBottomColor:       styles.BorderBottomColor,
+		PaddingLeft:             styles.PaddingLeft,
+		PaddingRight:            styles.PaddingRight,
+		PaddingBottom:           styles.PaddingBottom,
+		PaddingTop:              styles.PaddingTop,
+		BorderTopLeftRadius:     styles.BorderTopLeftRadius,
+		BorderTopRightRadius:    styles.BorderTopRightRadius,
+		BorderBottomLeftRadius:  styles.BorderBottomLeftRadius,
+		BorderBottomRightRadius: styles.BorderBottomRightRadius,
+	}
+	vl = cb.frontend.HTMLBorder(vl, hv)
+	if styles.BackgroundColor != nil {
+		r := node.NewRule()
+		x := pdfdraw.NewStandalone().ColorNonstroking(*styles.BackgroundColor).Rect(0, 0, wd, -ht).Fill()
+		r.Pre = x.String()
+		rvl := node.Vpack(r)
+		rvl.Attributes = node.H{"origin": "page background color"}
+		cb.frontend.Doc.CurrentPage.OutputAt(0, ht, rvl)
+	}
+	cb.frontend.Doc.CurrentPage.OutputAt(ml, ht-mt, vl)
+	return nil
+}
+
+// countPage increments the page counter for a new page. reset is the value of
+// counter-reset of the page master, "page 5" starts the pages with number 5.
+func (cb *CSSBuilder) countPage(reset string) {
+	cb.pageNumber++
+	fields := strings.Fields(reset)
+	for i, name := range fields {
+		if name != "page" {
+			continue
 		}
-		cb.frontend.Doc.NewPage()
-		if styles.BackgroundColor != nil {
-			r := node.NewRule()
-			x := pdfdraw.NewStandalone().ColorNonstroking(*styles.BackgroundColor).Rect(0, 0, wd, -ht).Fill()
-			r.Pre = x.String()
-			rvl := node.Vpack(r)
-			rvl.Attributes = node.H{"origin": "page background color"}
-			cb.frontend.Doc.CurrentPage.OutputAt(0, ht, rvl)
+		cb.pageNumber = 0
+		if i+1 < len(fields) {
+			if n, err := strconv.Atoi(fields[i+1]); err == nil {
+				cb.pageNumber = n
+			}
 		}
-		cb.frontend.Doc.CurrentPage.OutputAt(ml, ht-mt, vl)
-		return nil
 	}
-	// no page master found
-	cb.frontend.Doc.DefaultPageWidth = bag.MustSp("210mm")
-	cb.frontend.Doc.DefaultPageHeight = bag.MustSp("297mm")
+}
 
-	cb.currentPageDimensions = PageDimensions{
-		Width:         cb.frontend.Doc.DefaultPageWidth,
-		Height:        cb.frontend.Doc.DefaultPageHeight,
-		ContentWidth:  cb.frontend.Doc.DefaultPageWidth - 2*onecm,
-		ContentHeight: cb.frontend.Doc.DefaultPageHeight - 2*onecm,
-		PageAreaLeft:  onecm,
-		PageAreaTop:   onecm,
-		MarginTop:     onecm,
-		MarginBottom:  onecm,
-		MarginLeft:    onecm,
-		MarginRight:   onecm,
+// usesPageTotal returns true if a page margin box shows the total number of
+// pages (counter(pages)).
+func (cb *CSSBuilder) usesPageTotal() bool {
+	for _, pg := range cb.css.Pages {
+		for _, area := range pg.PageArea {
+			if pagesCounter.MatchString(area["content"]) {
+				return true
+			}
+		}
 	}
-	cb.frontend.Doc.NewPage()
-	return nil
+	return false
 }
 
+var pagesCounter = regexp.MustCompile(`counters?\(\s*pages\b`)
+
 // PageSize returns a struct with the dimensions of the current page.
 func (cb *CSSBuilder) PageSize() (PageDimensions, error) {
 	err := cb.InitPage()
@@ -218,15 +300,40 @@ func (cb *CSSBuilder) ParseCSSString(css string) error {
 
 // NewPage puts the current page into the PDF document and starts with a new page.
 func (cb *CSSBuilder) NewPage() error {
-	if err := cb.InitPage(); err != nil {
-		return err
+	return cb.nextPage(cb.pageName)
+}
+
+// Finish adds the page margin boxes to the current page and the pages which
+// wait for the total number of pages, puts them into the PDF document and
+// finishes the document. Call Finish instead of BeforeShipout and Shipout for
+// the last page. If a page margin box shows the total number of pages
+// (counter(pages)), the pages are shipped out in Finish only and Finish must
+// be called instead of the Finish method of the frontend document. Finish
+// returns an error if such a page has been shipped out before.
+func (cb *CSSBuilder) Finish() error {
+	if cur := cb.frontend.Doc.CurrentPage; cur != nil && !cur.Finished {
+		if err := cb.BeforeShipout(); err != nil {
+			return err
+		}
+		if len(cb.deferred) == 0 {
+			cur.Shipout()
+		}
 	}
-	if err := cb.BeforeShipout(); err != nil {
-		return err
+	pages := len(cb.frontend.Doc.Pages)
+	for _, dp := range cb.deferred {
+		if dp.page.Finished {
+			return fmt.Errorf("page %d has been shipped out before Finish placed its page margin boxes", dp.margins.number)
+		}
 	}
-	cb.frontend.Doc.CurrentPage.Shipout()
-	cb.frontend.Doc.NewPage()
-	return nil
+	for _, dp := range cb.deferred {
+		dp.margins.pages = pages
+		if err := cb.outputMarginBoxes(dp.page, dp.dimensions, dp.margins); err != nil {
+			return err
+		}
+		dp.page.Shipout()
+	}
+	cb.deferred = nil
+	return cb.frontend.Finish()
 }
 
 // ParseHTMLFromNode interprets the HTML structure and applies all previously read CSS data.
@@ -238,7 +345,7 @@ func (cb *CSSBuilder) ParseHTMLFromNode(input *html.Node) (*frontend.Text, error
 	}
 	var te *frontend.Text
 	n := gq.Nodes[0]
-	if te, err = htmlstyle.HTMLNodeToText(n, cb.stylesStack, cb.frontend); err != nil {
+	if te, err = htmlstyle.HTMLNodeToTextWithCounters(n, cb.stylesStack, cb.frontend, cb.counters); err != nil {
 		return nil, err
 	}
 
@@ -258,7 +365,7 @@ func (cb *CSSBuilder) HTMLToText(html string) (*frontend.Text, error) {
 	n := gq.Nodes[0]
 
 	var te *frontend.Text
-	if te, err = htmlstyle.HTMLNodeToText(n, cb.stylesStack, cb.frontend); err != nil {
+	if te, err = htmlstyle.HTMLNodeToTextWithCounters(n, cb.stylesStack, cb.frontend, cb.counters); err != nil {
 		return nil, err
 	}
 
@@ -296,23 +403,6 @@ func (inf *info) String() string {
 	return fmt.Sprintf("mt: %s mb: %s len(pb): %d vl: %v", inf.marginTop, inf.marginBottom, len(inf.pagebox), inf.vl)
 }
 
-func hasContents(areaAttributes map[string]string) bool {
-	return areaAttributes["content"] != "none" && areaAttributes["content"] != "normal"
-}
-
-type pageMarginBox struct {
-	minWidth    bag.ScaledPoint
-	maxWidth    bag.ScaledPoint
-	areaWidth   bag.ScaledPoint
-	hasContents bool
-	widthAuto   bool
-	halign      frontend.HorizontalAlignment
-	x           bag.ScaledPoint
-	y           bag.ScaledPoint
-	wd          bag.ScaledPoint
-	ht          bag.ScaledPoint
-}
-
 // ReadCSSFile reads the given file name and tries to parse the CSS contents
 // from the file.
 func (cb *CSSBuilder) ReadCSSFile(filename string) error {
@@ -0,0 +1,110 @@
+package cssbuilder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/speedata/boxesandglue/csshtml"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
+	"github.com/speedata/boxesandglue/frontend"
+)
+
+func TestCountPage(t *testing.T) {
+	testdata := []struct {
+		number int
+		reset  string
+		want   int
+	}{
+		{0, "", 1},
+		{4, "", 5},
+		{4, "page", 0},
+		{4, "page 10", 10},
+		{4, "chapter page 3", 3},
+		{4, "chapter 2", 5},
+	}
+	for _, td := range testdata {
+		cb := &CSSBuilder{pageNumber: td.number}
+		cb.countPage(td.reset)
+		if cb.pageNumber != td.want {
+			t.Errorf("countPage(%q) after page %d = %d, want %d", td.reset, td.number, cb.pageNumber, td.want)
+		}
+	}
+}
+
+// newTestBuilder returns a CSSBuilder with a serif font family and the page
+// margin box bottom-center with the contents.
+func newTestBuilder(t *testing.T, content string) *CSSBuilder {
+	t.Helper()
+	var buf bytes.Buffer
+	fe, err := frontend.NewForWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fe.Doc.DefaultLanguage, err = frontend.GetLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	ff := fe.NewFontFamily("serif")
+	if err = ff.AddMember(&frontend.FontSource{Name: "crimson", Data: crimsonproregular.TTF}, frontend.FontWeight400, frontend.FontStyleNormal); err != nil {
+		t.Fatal(err)
+	}
+	cb := New(fe, csshtml.NewCSSParserWithDefaults())
+	if err = cb.ParseCSSString(`@page { size: 105mm 148mm; margin: 1cm; @bottom-center { font-family: serif; content: ` + content + ` } }`); err != nil {
+		t.Fatal(err)
+	}
+	// the page dimensions need the styles of the document
+	if _, err = cb.HTMLToText(`<html><body></body></html>`); err != nil {
+		t.Fatal(err)
+	}
+	return cb
+}
+
+func TestDeferredPages(t *testing.T) {
+	cb := newTestBuilder(t, `counter(page) " of " counter(pages)`)
+	for i := 0; i < 2; i++ {
+		if err := cb.NewPage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc := cb.frontend.Doc
+	if len(cb.deferred) != 2 {
+		t.Fatalf("got %d deferred pages, want 2", len(cb.deferred))
+	}
+	objects := make([]int, len(doc.Pages))
+	for i, pg := range doc.Pages {
+		if pg.Finished {
+			t.Errorf("page %d is finished before Finish", i+1)
+		}
+		objects[i] = len(pg.Objects)
+	}
+	if err := cb.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Pages) != 3 || len(cb.deferred) != 0 {
+		t.Fatalf("got %d pages and %d deferred pages, want 3 and 0", len(doc.Pages), len(cb.deferred))
+	}
+	for i, pg := range doc.Pages {
+		if !pg.Finished || len(pg.Objects) != objects[i]+1 {
+			t.Errorf("page %d is not finished or has no margin box after Finish", i+1)
+		}
+	}
+
+	// without counter(pages) the pages are shipped out immediately
+	cb = newTestBuilder(t, `counter(page)`)
+	if err := cb.NewPage(); err != nil {
+		t.Fatal(err)
+	}
+	if pg := cb.frontend.Doc.Pages[0]; !pg.Finished || len(pg.Objects) == 0 || len(cb.deferred) != 0 {
+		t.Error("page 1 is not shipped out with its margin box")
+	}
+}
+
+func TestDeferredPageShippedOut(t *testing.T) {
+	cb := newTestBuilder(t, `counter(pages)`)
+	if err := cb.NewPage(); err != nil {
+		t.Fatal(err)
+	}
+	cb.frontend.Doc.Pages[0].Shipout()
+	if err := cb.Finish(); err == nil {
+		t.Error("Finish() after the shipout of a deferred page = nil, want error")
+	}
+}
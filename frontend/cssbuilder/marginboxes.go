@@ -0,0 +1,411 @@
+package cssbuilder
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/document"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/frontend"
+	"github.com/speedata/boxesandglue/htmlstyle"
+)
+
+// marginBoxOrder is the painting order of the page margin boxes.
+var marginBoxOrder = []string{"top-left-corner", "top-left", "top-center", "top-right", "top-right-corner", "right-top", "right-middle", "right-bottom", "bottom-right-corner", "bottom-right", "bottom-center", "bottom-left", "bottom-left-corner", "left-bottom", "left-middle", "left-top"}
+
+// marginEdges contains the page margin boxes at the top, bottom, left and
+// right edge of the page, each in the order start, center, end.
+var marginEdges = [4][3]string{
+	{"top-left", "top-center", "top-right"},
+	{"bottom-left", "bottom-center", "bottom-right"},
+	{"left-top", "left-middle", "left-bottom"},
+	{"right-top", "right-middle", "right-bottom"},
+}
+
+// pageMarginBox is a generated page margin box. x and y are the top left
+// corner of the box on the page, wd and ht are the outer size.
+type pageMarginBox struct {
+	area    map[string]string
+	styles  *htmlstyle.FormattingStyles
+	text    string
+	element *frontend.Text
+	halign  frontend.HorizontalAlignment
+	valign  frontend.VerticalAlignment
+	x       bag.ScaledPoint
+	y       bag.ScaledPoint
+	wd      bag.ScaledPoint
+	ht      bag.ScaledPoint
+}
+
+// extra returns the horizontal and the vertical space taken by the borders
+// and the padding of the box.
+func (pmb *pageMarginBox) extra() (bag.ScaledPoint, bag.ScaledPoint) {
+	s := pmb.styles
+	return s.BorderLeftWidth + s.BorderRightWidth + s.PaddingLeft + s.PaddingRight,
+		s.BorderTopWidth + s.BorderBottomWidth + s.PaddingTop + s.PaddingBottom
+}
+
+// paragraph returns the text of the box for the paragraph builder.
+func (pmb *pageMarginBox) paragraph() *frontend.Text {
+	txt := frontend.NewText()
+	htmlstyle.ApplySettings(txt.Settings, pmb.styles)
+	txt.Settings[frontend.SettingSize] = pmb.styles.DefaultFontSize
+	if _, ok := txt.Settings[frontend.SettingFontFamily]; !ok {
+		txt.Settings[frontend.SettingFontFamily] = pmb.styles.DefaultFontFamily
+	}
+	txt.Items = append(txt.Items, pmb.text)
+	return txt
+}
+
+// marginBoxAlignment returns the initial text-align and vertical-align values
+// of the page margin box name.
+func marginBoxAlignment(name string) (frontend.HorizontalAlignment, frontend.VerticalAlignment) {
+	switch name {
+	case "top-left", "bottom-left", "top-right-corner", "bottom-right-corner":
+		return frontend.HAlignLeft, frontend.VAlignMiddle
+	case "top-right", "bottom-right", "top-left-corner", "bottom-left-corner":
+		return frontend.HAlignRight, frontend.VAlignMiddle
+	case "left-top", "right-top":
+		return frontend.HAlignCenter, frontend.VAlignTop
+	case "left-bottom", "right-bottom":
+		return frontend.HAlignCenter, frontend.VAlignBottom
+	}
+	return frontend.HAlignCenter, frontend.VAlignMiddle
+}
+
+// newPageMarginBox returns the page margin box name with the styles area.
+func (cb *CSSBuilder) newPageMarginBox(name string, area map[string]string, mc marginContext) (*pageMarginBox, error) {
+	styles := cb.stylesStack.PushStyles()
+	defer cb.stylesStack.PopStyles()
+	if err := htmlstyle.StylesToStyles(styles, area, cb.frontend, cb.stylesStack.CurrentStyle().Fontsize); err != nil {
+		return nil, err
+	}
+	pmb := &pageMarginBox{area: area, styles: styles}
+	pmb.text, pmb.element = cb.parseContent(area["content"], mc)
+	pmb.halign, pmb.valign = marginBoxAlignment(name)
+	if v, ok := area["text-align"]; ok {
+		pmb.halign = htmlstyle.ParseHorizontalAlign(v, styles)
+	}
+	if v, ok := area["vertical-align"]; ok {
+		pmb.valign = htmlstyle.ParseVerticalAlign(v, styles)
+	}
+	return pmb, nil
+}
+
+// formatMarginBox returns the contents of the page margin box formatted to
+// the width wd.
+func (cb *CSSBuilder) formatMarginBox(pmb *pageMarginBox, wd bag.ScaledPoint) (*node.VList, error) {
+	switch {
+	case pmb.element != nil:
+		return cb.frontend.FormatRunningElement(pmb.element, wd)
+	case pmb.text != "":
+		vl, _, err := cb.frontend.FormatParagraph(pmb.paragraph(), wd, frontend.Family(pmb.styles.DefaultFontFamily), frontend.HorizontalAlign(pmb.halign))
+		return vl, err
+	}
+	return node.NewVList(), nil
+}
+
+// marginBoxWidths returns the outer min-content and max-content width of the
+// page margin box.
+func (cb *CSSBuilder) marginBoxWidths(pmb *pageMarginBox) (bag.ScaledPoint, bag.ScaledPoint, error) {
+	extra, _ := pmb.extra()
+	var te *frontend.Text
+	switch {
+	case pmb.element != nil:
+		te = pmb.element
+	case pmb.text != "":
+		te = pmb.paragraph()
+	default:
+		return extra, extra, nil
+	}
+	minWd, maxWd, err := cb.frontend.IntrinsicWidths(te)
+	return minWd + extra, maxWd + extra, err
+}
+
+// marginBoxLength returns the value of the width or the height property v of
+// a page margin box. Percentages refer to the length of the edge avail. It
+// returns false if the value is auto.
+func marginBoxLength(v string, avail bag.ScaledPoint, styles *htmlstyle.FormattingStyles) (bag.ScaledPoint, bool) {
+	switch v {
+	case "", "auto":
+		return 0, false
+	}
+	if v[len(v)-1] == '%' {
+		return htmlstyle.ParseRelativeSize(v, avail, styles.DefaultFontSize), true
+	}
+	return htmlstyle.ParseRelativeSize(v, styles.Fontsize, styles.DefaultFontSize), true
+}
+
+// marginBoxSize is the outer size of a page margin box along the edge of the
+// page: the width of the boxes at the top and at the bottom and the height of
+// the boxes at the left and at the right edge.
+type marginBoxSize struct {
+	generated bool
+	// auto is true if the size is not specified.
+	auto bool
+	// size is the specified size and after resolveMarginBoxes the used
+	// size.
+	size bag.ScaledPoint
+	// min and max are the min-content and the max-content size.
+	min bag.ScaledPoint
+	max bag.ScaledPoint
+}
+
+// outer returns the specified size or the max-content size.
+func (mbs marginBoxSize) outer() bag.ScaledPoint {
+	if mbs.auto {
+		return mbs.max
+	}
+	return mbs.size
+}
+
+// share returns the part of space in the ratio part / total.
+func share(space, part, total bag.ScaledPoint) bag.ScaledPoint {
+	if total == 0 {
+		return 0
+	}
+	return bag.MultiplyFloat(space, float64(part)/float64(total))
+}
+
+// resolveTwoMarginBoxes distributes the length avail among the boxes a and c.
+// The auto sizes grow or shrink in proportion to their contents.
+func resolveTwoMarginBoxes(avail bag.ScaledPoint, a, c *marginBoxSize) {
+	switch {
+	case !a.auto && !c.auto:
+		return
+	case !a.auto:
+		c.size = avail - a.size
+		return
+	case !c.auto:
+		a.size = avail - c.size
+		return
+	}
+	sumMin, sumMax := a.min+c.min, a.max+c.max
+	switch {
+	case sumMax == 0:
+		// no contents, the generated boxes share the space
+		switch {
+		case a.generated && c.generated:
+			a.size = avail / 2
+		case a.generated:
+			a.size = avail
+		default:
+			a.size = 0
+		}
+	case sumMax < avail:
+		a.size = a.max + share(avail-sumMax, a.max, sumMax)
+	case sumMin < avail:
+		a.size = a.min + share(avail-sumMin, a.max-a.min, sumMax-sumMin)
+	default:
+		a.size = a.min + share(avail-sumMin, a.min, sumMin)
+	}
+	c.size = avail - a.size
+}
+
+// resolveMarginBoxes sets the used sizes of the start, the center and the end
+// box along an edge of the length avail (CSS Paged Media 3, section 5.3.2).
+// The center box is centered on the edge, the boxes which are not generated
+// get the size 0.
+func resolveMarginBoxes(avail bag.ScaledPoint, boxes *[3]marginBoxSize) {
+	for i := range boxes {
+		if !boxes[i].generated {
+			boxes[i] = marginBoxSize{auto: true}
+		}
+	}
+	a, b, c := &boxes[0], &boxes[1], &boxes[2]
+	if !b.generated {
+		resolveTwoMarginBoxes(avail, a, c)
+		return
+	}
+	if b.auto {
+		// the start and the end box are treated as one box of twice the
+		// size of the larger one
+		ac := marginBoxSize{generated: a.generated || c.generated, auto: a.auto && c.auto}
+		if ac.auto {
+			ac.min = 2 * bag.Max(a.min, c.min)
+			ac.max = 2 * bag.Max(a.max, c.max)
+		} else {
+			ac.size = 2 * bag.Max(a.outer(), c.outer())
+		}
+		resolveTwoMarginBoxes(avail, b, &ac)
+	}
+	side := (avail - b.size) / 2
+	for _, box := range []*marginBoxSize{a, c} {
+		switch {
+		case !box.generated:
+			box.size = 0
+		case box.auto:
+			box.size = side
+		}
+	}
+}
+
+// layoutMarginBoxes sets the position and the size of the page margin boxes.
+func (cb *CSSBuilder) layoutMarginBoxes(boxes map[string]*pageMarginBox, dim PageDimensions) error {
+	corners := map[string][4]bag.ScaledPoint{
+		"top-left-corner":     {0, dim.Height, dim.MarginLeft, dim.MarginTop},
+		"top-right-corner":    {dim.Width - dim.MarginRight, dim.Height, dim.MarginRight, dim.MarginTop},
+		"bottom-left-corner":  {0, dim.MarginBottom, dim.MarginLeft, dim.MarginBottom},
+		"bottom-right-corner": {dim.Width - dim.MarginRight, dim.MarginBottom, dim.MarginRight, dim.MarginBottom},
+	}
+	for name, c := range corners {
+		if pmb := boxes[name]; pmb != nil {
+			pmb.x, pmb.y, pmb.wd, pmb.ht = c[0], c[1], c[2], c[3]
+		}
+	}
+	for i, edge := range marginEdges {
+		horizontal := i < 2
+		avail := dim.Width - dim.MarginLeft - dim.MarginRight
+		prop := "width"
+		var thickness bag.ScaledPoint
+		if !horizontal {
+			avail = dim.Height - dim.MarginTop - dim.MarginBottom
+			prop = "height"
+			thickness = dim.MarginLeft
+			if i == 3 {
+				thickness = dim.MarginRight
+			}
+		}
+		var sizes [3]marginBoxSize
+		for j, name := range edge {
+			pmb := boxes[name]
+			if pmb == nil {
+				continue
+			}
+			mbs := &sizes[j]
+			mbs.generated = true
+			if size, ok := marginBoxLength(pmb.area[prop], avail, pmb.styles); ok {
+				mbs.size = size
+				continue
+			}
+			mbs.auto = true
+			var err error
+			if horizontal {
+				if mbs.min, mbs.max, err = cb.marginBoxWidths(pmb); err != nil {
+					return err
+				}
+				continue
+			}
+			// the height of the contents at the width of the margin
+			extraWd, extraHt := pmb.extra()
+			vl, err := cb.formatMarginBox(pmb, thickness-extraWd)
+			if err != nil {
+				return err
+			}
+			mbs.min = vl.Height + vl.Depth + extraHt
+			mbs.max = mbs.min
+		}
+		resolveMarginBoxes(avail, &sizes)
+		offsets := [3]bag.ScaledPoint{0, (avail - sizes[1].size) / 2, avail - sizes[2].size}
+		for j, name := range edge {
+			pmb := boxes[name]
+			if pmb == nil {
+				continue
+			}
+			switch i {
+			case 0:
+				pmb.x, pmb.y, pmb.wd, pmb.ht = dim.MarginLeft+offsets[j], dim.Height, sizes[j].size, dim.MarginTop
+			case 1:
+				pmb.x, pmb.y, pmb.wd, pmb.ht = dim.MarginLeft+offsets[j], dim.MarginBottom, sizes[j].size, dim.MarginBottom
+			case 2:
+				pmb.x, pmb.y, pmb.wd, pmb.ht = 0, dim.Height-dim.MarginTop-offsets[j], dim.MarginLeft, sizes[j].size
+			case 3:
+				pmb.x, pmb.y, pmb.wd, pmb.ht = dim.Width-dim.MarginRight, dim.Height-dim.MarginTop-offsets[j], dim.MarginRight, sizes[j].size
+			}
+		}
+	}
+	return nil
+}
+
+// alignVertically returns vl in a vertical list with the height ht. The
+// contents are at the top, in the middle or at the bottom.
+func alignVertically(vl *node.VList, ht bag.ScaledPoint, valign frontend.VerticalAlignment) *node.VList {
+	more := ht - vl.Height - vl.Depth
+	var top bag.ScaledPoint
+	switch valign {
+	case frontend.VAlignTop:
+	case frontend.VAlignBottom:
+		top = more
+	default:
+		top = more / 2
+	}
+	var head node.Node
+	if top != 0 {
+		g := node.NewGlue()
+		g.Width = top
+		head = g
+	}
+	head = node.InsertAfter(head, head, vl)
+	if bottom := more - top; bottom != 0 {
+		g := node.NewGlue()
+		g.Width = bottom
+		head = node.InsertAfter(head, vl, g)
+	}
+	return node.Vpack(head)
+}
+
+// marginBoxValues returns the borders, the padding and the background of the
+// styles.
+func marginBoxValues(styles *htmlstyle.FormattingStyles) frontend.HTMLValues {
+	return frontend.HTMLValues{
+		BorderLeftWidth:         styles.BorderLeftWidth,
+		BorderRightWidth:        styles.BorderRightWidth,
+		BorderTopWidth:          styles.BorderTopWidth,
+		BorderBottomWidth:       styles.BorderBottomWidth,
+		BorderTopStyle:          styles.BorderTopStyle,
+		BorderLeftStyle:         styles.BorderLeftStyle,
+		BorderRightStyle:        styles.BorderRightStyle,
+		BorderBottomStyle:       styles.BorderBottomStyle,
+		BorderTopColor:          styles.BorderTopColor,
+		BorderLeftColor:         styles.BorderLeftColor,
+		BorderRightColor:        styles.BorderRightColor,
+		BorderBottomColor:       styles.BorderBottomColor,
+		PaddingLeft:             styles.PaddingLeft,
+		PaddingRight:            styles.PaddingRight,
+		PaddingBottom:           styles.PaddingBottom,
+		PaddingTop:              styles.PaddingTop,
+		BorderTopLeftRadius:     styles.BorderTopLeftRadius,
+		BorderTopRightRadius:    styles.BorderTopRightRadius,
+		BorderBottomLeftRadius:  styles.BorderBottomLeftRadius,
+		BorderBottomRightRadius: styles.BorderBottomRightRadius,
+		BackgroundColor:         styles.BackgroundColor,
+	}
+}
+
+// outputMarginBoxes places the page margin boxes of the page master in
+// dimensions on the page. mc contains the page number, the named strings and
+// the running elements of the page.
+func (cb *CSSBuilder) outputMarginBoxes(page *document.Page, dimensions PageDimensions, mc marginContext) error {
+	mp := dimensions.masterpage
+	if mp == nil {
+		return nil
+	}
+	boxes := make(map[string]*pageMarginBox)
+	for name, area := range mp.PageArea {
+		// a page margin box is only generated if content is not none
+		if c, ok := area["content"]; !ok || c == "none" || c == "normal" {
+			continue
+		}
+		pmb, err := cb.newPageMarginBox(name, area, mc)
+		if err != nil {
+			return err
+		}
+		boxes[name] = pmb
+	}
+	if err := cb.layoutMarginBoxes(boxes, dimensions); err != nil {
+		return err
+	}
+	for _, name := range marginBoxOrder {
+		pmb := boxes[name]
+		if pmb == nil {
+			continue
+		}
+		extraWd, extraHt := pmb.extra()
+		vl, err := cb.formatMarginBox(pmb, pmb.wd-extraWd)
+		if err != nil {
+			return err
+		}
+		vl = alignVertically(vl, pmb.ht-extraHt, pmb.valign)
+		vl.Width = pmb.wd - extraWd
+		page.OutputAt(pmb.x, pmb.y, cb.frontend.HTMLBorder(vl, marginBoxValues(pmb.styles)))
+	}
+	return nil
+}
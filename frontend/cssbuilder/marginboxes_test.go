@@ -0,0 +1,34 @@
+package cssbuilder
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+func TestResolveMarginBoxes(t *testing.T) {
+	pt := func(f float64) bag.ScaledPoint { return bag.ScaledPointFromFloat(f) }
+	auto := func(minSize, maxSize float64) marginBoxSize {
+		return marginBoxSize{generated: true, auto: true, min: pt(minSize), max: pt(maxSize)}
+	}
+	testdata := []struct {
+		name  string
+		boxes [3]marginBoxSize
+		want  [3]float64
+	}{
+		{"grow start and end", [3]marginBoxSize{auto(10, 50), {}, auto(10, 30)}, [3]float64{125, 0, 75}},
+		{"shrink start and end", [3]marginBoxSize{auto(20, 150), {}, auto(30, 150)}, [3]float64{98, 0, 102}},
+		{"center only", [3]marginBoxSize{{}, auto(10, 40), {}}, [3]float64{0, 200, 0}},
+		{"all three", [3]marginBoxSize{auto(10, 60), auto(10, 40), auto(10, 20)}, [3]float64{75, 50, 75}},
+		{"fixed center", [3]marginBoxSize{auto(10, 60), {generated: true, size: pt(100)}, {}}, [3]float64{50, 100, 0}},
+	}
+	for _, td := range testdata {
+		boxes := td.boxes
+		resolveMarginBoxes(pt(200), &boxes)
+		for i, want := range td.want {
+			if d := boxes[i].size - pt(want); d < -10 || d > 10 {
+				t.Errorf("%s: box %d = %s, want %gpt", td.name, i, boxes[i].size, want)
+			}
+		}
+	}
+}
@@ -20,7 +20,7 @@ func (cb *CSSBuilder) OutputPage(html string) error {
 	var te *frontend.Text
 	n := gq.Nodes[0]
 
-	if te, err = htmlstyle.HTMLNodeToText(n, cb.stylesStack, cb.frontend); err != nil {
+	if te, err = htmlstyle.HTMLNodeToTextWithCounters(n, cb.stylesStack, cb.frontend, cb.counters); err != nil {
 		return err
 	}
 	err = cb.outputOnPage(te)
@@ -50,169 +50,66 @@ func (cb *CSSBuilder) outputOnPage(te *frontend.Text) error {
 	return nil
 }
 
-// turn content: `"page " counter(page) " of " counter(pages)` into a meaningful
-// string.
-func (cb *CSSBuilder) parseContent(in string) string {
-	var result []rune
-	inString := false
-	for _, r := range in {
-		switch r {
-		case '"':
-			inString = !inString
-		default:
-			if inString {
-				result = append(result, r)
-			}
+// parseContent returns the text of the content property of a page margin box
+// or the running element if the content is element(name).
+func (cb *CSSBuilder) parseContent(in string, mc marginContext) (string, *frontend.Text) {
+	c := make(htmlstyle.Counters, len(cb.counters)+2)
+	for name, values := range cb.counters {
+		c[name] = values
+	}
+	c["page"] = []int{mc.number}
+	c["pages"] = []int{mc.pages}
+	keyword := func(args []string) string {
+		if len(args) > 1 {
+			return args[1]
 		}
+		return "first"
+	}
+	var elt *frontend.Text
+	funcs := map[string]htmlstyle.ContentFunc{
+		"string": func(args []string) string {
+			if len(args) == 0 {
+				return ""
+			}
+			str, _ := mc.namedStrings.value(args[0], keyword(args)).(string)
+			return str
+		},
+		"element": func(args []string) string {
+			if len(args) > 0 {
+				elt, _ = mc.runningElements.value(args[0], keyword(args)).(*frontend.Text)
+			}
+			return ""
+		},
 	}
-	return string(result)
+	return c.ContentString(in, nil, funcs), elt
 }
 
 // BeforeShipout should be called when placing a CSS page in the PDF. It adds
-// page margin boxes to the current page.
+// page margin boxes to the current page. If the margin boxes show the total
+// number of pages (counter(pages)), they are added in Finish: the page must
+// not be shipped out then, Finish places the margin boxes and ships out the
+// page.
 func (cb *CSSBuilder) BeforeShipout() error {
-	var err error
-	df := cb.frontend
-	dimensions := cb.currentPageDimensions
-	mp := dimensions.masterpage
-	if mp != nil {
-		pageMarginBoxes := make(map[string]*pageMarginBox)
-		for areaName, attr := range mp.PageArea {
-			pmb := &pageMarginBox{
-				widthAuto: true,
-			}
-			pmb.hasContents = hasContents(attr)
-			if wd, ok := attr["width"]; ok {
-				if wd != "auto" {
-					pmb.areaWidth = htmlstyle.ParseRelativeSize(wd, dimensions.Width, dimensions.Width)
-				}
-			}
-
-			pageMarginBoxes[areaName] = pmb
-		}
-		for areaName := range mp.PageArea {
-			pmb := pageMarginBoxes[areaName]
-			switch areaName {
-			case "top-left-corner":
-				pmb.x = 0
-				pmb.y = df.Doc.DefaultPageHeight
-				pmb.wd = dimensions.MarginLeft
-				pmb.ht = dimensions.MarginTop
-			case "top-right-corner":
-				pmb.x = dimensions.Width - dimensions.MarginRight
-				pmb.y = df.Doc.DefaultPageHeight
-				pmb.wd = dimensions.MarginRight
-				pmb.ht = dimensions.MarginTop
-			case "bottom-left-corner":
-				pmb.x = 0
-				pmb.y = dimensions.MarginBottom
-				pmb.wd = dimensions.MarginLeft
-				pmb.ht = dimensions.MarginBottom
-			case "bottom-right-corner":
-				pmb.x = dimensions.Width - dimensions.MarginRight
-				pmb.y = dimensions.MarginBottom
-				pmb.wd = dimensions.MarginRight
-				pmb.ht = dimensions.MarginBottom
-			case "top-left", "top-center", "top-right":
-				pmb.x = dimensions.MarginLeft
-				pmb.y = df.Doc.DefaultPageHeight
-				pmb.wd = dimensions.Width - dimensions.MarginLeft - dimensions.MarginRight
-				pmb.ht = dimensions.MarginTop
-				switch areaName {
-				case "top-left":
-					pmb.halign = frontend.HAlignLeft
-				case "top-center":
-					pmb.halign = frontend.HAlignCenter
-				case "top-right":
-					pmb.halign = frontend.HAlignRight
-				}
-			case "bottom-left", "bottom-center", "bottom-right":
-				pmb.x = dimensions.MarginLeft
-				pmb.y = dimensions.MarginTop
-				pmb.wd = dimensions.Width - dimensions.MarginLeft - dimensions.MarginRight
-				pmb.ht = dimensions.MarginTop
-				switch areaName {
-				case "bottom-left":
-					pmb.halign = frontend.HAlignLeft
-				case "bottom-center":
-					pmb.halign = frontend.HAlignCenter
-				case "bottom-right":
-					pmb.halign = frontend.HAlignRight
-				}
-			}
-		}
-		// todo: calculate the area size
-		for _, areaName := range []string{"top-left-corner", "top-left", "top-center", "top-right", "top-right-corner", "right-top", "right-middle", "right-bottom", "bottom-right-corner", "bottom-right", "bottom-center", "bottom-left", "bottom-left-corner", "left-bottom", "left-middle", "left-top"} {
-			if area, ok := mp.PageArea[areaName]; ok {
-				if !hasContents(area) {
-					continue
-				}
-				styles := cb.stylesStack.PushStyles()
-
-				if err = htmlstyle.StylesToStyles(styles, area, cb.frontend, cb.stylesStack.CurrentStyle().Fontsize); err != nil {
-					return err
-				}
-				pmb := pageMarginBoxes[areaName]
-
-				vl := node.NewVList()
-				var err error
-				if c, ok := area["content"]; ok {
-					c = cb.parseContent(c)
-					if c != "" {
-						txt := frontend.NewText()
-						htmlstyle.ApplySettings(txt.Settings, styles)
-						txt.Settings[frontend.SettingSize] = styles.DefaultFontSize
-						txt.Settings[frontend.SettingHeight] = pmb.ht - styles.BorderTopWidth - styles.BorderBottomWidth
-						txt.Settings[frontend.SettingVAlign] = styles.Valign
-
-						txt.Items = append(txt.Items, c)
-						defaultFontFamily := styles.DefaultFontFamily
-						vl, _, err = df.FormatParagraph(txt, pmb.wd-styles.BorderLeftWidth-styles.BorderRightWidth, frontend.Family(defaultFontFamily), frontend.HorizontalAlign(pmb.halign))
-						if err != nil {
-							return err
-						}
-
-					} else {
-						vl = node.NewVList()
-						vl.Width = pmb.wd - styles.BorderLeftWidth - styles.BorderRightWidth
-						vl.Height = pmb.ht - styles.BorderTopWidth - styles.BorderBottomWidth
-					}
-					hv := frontend.HTMLValues{
-						BorderLeftWidth:         styles.BorderLeftWidth,
-						BorderRightWidth:        styles.BorderRightWidth,
-						BorderTopWidth:          styles.BorderTopWidth,
-						BorderBottomWidth:       styles.BorderBottomWidth,
-						BorderTopStyle:          styles.BorderTopStyle,
-						BorderLeftStyle:         styles.BorderLeftStyle,
-						BorderRightStyle:        styles.BorderRightStyle,
-						BorderBottomStyle:       styles.BorderBottomStyle,
-						BorderTopColor:          styles.BorderTopColor,
-						BorderLeftColor:         styles.BorderLeftColor,
-						BorderRightColor:        styles.BorderRightColor,
-						BorderBottomColor:       styles.BorderBottomColor,
-						PaddingLeft:             styles.PaddingLeft,
-						PaddingRight:            styles.PaddingRight,
-						PaddingBottom:           styles.PaddingBottom,
-						PaddingTop:              styles.PaddingTop,
-						BorderTopLeftRadius:     styles.BorderTopLeftRadius,
-						BorderTopRightRadius:    styles.BorderTopRightRadius,
-						BorderBottomLeftRadius:  styles.BorderBottomLeftRadius,
-						BorderBottomRightRadius: styles.BorderBottomRightRadius,
-						BackgroundColor:         styles.BackgroundColor,
-					}
-					vl = df.HTMLBorder(vl, hv)
-					df.Doc.CurrentPage.OutputAt(pmb.x, pmb.y, vl)
-					cb.stylesStack.PopStyles()
-
-				}
-			}
+	page := cb.frontend.Doc.CurrentPage
+	if cb.usesPageTotal() {
+		if n := len(cb.deferred); n == 0 || cb.deferred[n-1].page != page {
+			cb.deferred = append(cb.deferred, deferredPage{
+				page:       page,
+				dimensions: cb.currentPageDimensions,
+				margins:    cb.marginContext(),
+			})
 		}
+		return nil
 	}
-	return nil
+	return cb.outputMarginBoxes(page, cb.currentPageDimensions, cb.marginContext())
 }
 
 // buildPages takes the internal pagebox slice and outputs each item with page
-// breaks in between.
+// breaks in between. A page break is inserted when a paragraph or a table
+// does not fit on the page (it is split between two lines or two rows),
+// before a block with a forced page break or a new page master and before a
+// block which must not be broken (break-inside: avoid) or which must stay
+// with the next block (break-after: avoid) if it does not fit.
 func (cb *CSSBuilder) buildPages() error {
 	/*
 		The pagebox is a slice of nodes that are either a StartStop node or a VList
@@ -226,16 +123,98 @@ func (cb *CSSBuilder) buildPages() error {
 		return err
 	}
 	y := pd.Height - pd.MarginTop
+	// breakPage starts a page with the page master name if necessary and
+	// moves to the top of the new page.
+	breakPage := func(name string, br frontend.Break) error {
+		page := cb.frontend.Doc.CurrentPage
+		if err := cb.breakPage(name, br); err != nil {
+			return err
+		}
+		if cb.frontend.Doc.CurrentPage != page {
+			pd = cb.currentPageDimensions
+			y = pd.Height - pd.MarginTop
+		}
+		return nil
+	}
+	// The running elements and the named strings are assigned when the next
+	// vertical list is placed, so they belong to the page where the element
+	// starts, even if the vertical list is moved to the next page.
+	var pendingRunning []*frontend.RunningElement
+	var pendingStrings []frontend.StringSet
+	assignPending := func() {
+		for _, re := range pendingRunning {
+			cb.runningElements.assign(re.Name, re.Text, !cb.pageStarted)
+		}
+		for _, str := range pendingStrings {
+			cb.namedStrings.assign(str.Name, str.Value, !cb.pageStarted)
+		}
+		pendingRunning, pendingStrings = nil, nil
+	}
 	var height, shiftDown bag.ScaledPoint
-	for _, n := range cb.pagebox {
+	for i, n := range cb.pagebox {
 		switch t := n.(type) {
 		case *node.StartStop:
 			// start node
 			tAttribs := t.Attributes
-			if _, ok := tAttribs["pagebreak"]; ok {
-				if err := cb.NewPage(); err != nil {
+			if cs, ok := tAttribs["columns"].(*frontend.ColumnSet); ok {
+				// fill the columns on each page until all material is placed
+				assignPending()
+				for {
+					vl, done := cs.Distribute(y - pd.MarginBottom)
+					cb.frontend.Doc.CurrentPage.OutputAt(cs.X, y, vl)
+					cb.pageStarted = true
+					y -= vl.Height + vl.Depth
+					if done {
+						break
+					}
+					if err := cb.NewPage(); err != nil {
+						return err
+					}
+					pd = cb.currentPageDimensions
+					y = pd.Height - pd.MarginTop
+				}
+				continue
+			}
+			if re, ok := tAttribs["running"].(*frontend.RunningElement); ok {
+				pendingRunning = append(pendingRunning, re)
+				continue
+			}
+			if ss, ok := tAttribs["stringset"].([]frontend.StringSet); ok {
+				pendingStrings = append(pendingStrings, ss...)
+				continue
+			}
+			if pb, ok := tAttribs["position"].(*frontend.PositionedBox); ok {
+				if err := cb.outputPositioned(pb); err != nil {
 					return err
 				}
+				if pb.Position.Scheme == frontend.PositionFixed {
+					cb.fixed = append(cb.fixed, pb)
+				}
+				continue
+			}
+			if t.StartNode == nil {
+				// a forced page break (also after the preceding block) or a
+				// change of the page master
+				name, ok := tAttribs["page"].(string)
+				if !ok {
+					name = cb.pageName
+				}
+				br, _ := tAttribs["pagebreak"].(frontend.Break)
+				if !br.Forced() {
+					br = cb.pendingBreak
+				}
+				cb.pendingBreak = frontend.BreakAuto
+				if br.Forced() || name != cb.pageName {
+					if err := breakPage(name, br); err != nil {
+						return err
+					}
+				} else if keep := cb.keepHeight(i); cb.pageStarted && keep > y-pd.MarginBottom && keep <= pd.Height-pd.MarginTop-pd.MarginBottom {
+					if err := breakPage(name, frontend.BreakPage); err != nil {
+						return err
+					}
+				}
+			} else if br, ok := tAttribs["breakafter"].(frontend.Break); ok && br.Forced() {
+				cb.pendingBreak = br
 			}
 			var hv frontend.HTMLValues
 			var ok bool
@@ -259,13 +238,67 @@ func (cb *CSSBuilder) buildPages() error {
 			}
 
 		case *node.VList:
-			tAttribs := t.Attributes
-			height = tAttribs["height"].(bag.ScaledPoint)
-			x := tAttribs["x"].(bag.ScaledPoint)
-			cb.frontend.Doc.CurrentPage.OutputAt(x, y, t)
-			y -= height
+			if cb.pendingBreak.Forced() {
+				if err := breakPage(cb.pageName, cb.pendingBreak); err != nil {
+					return err
+				}
+				cb.pendingBreak = frontend.BreakAuto
+			}
+			// a floating box takes no space in the flow but must fit on the
+			// page
+			if fh, ok := t.Attributes["floatHeight"].(bag.ScaledPoint); ok && cb.pageStarted && fh > y-pd.MarginBottom && fh <= pd.Height-pd.MarginTop-pd.MarginBottom {
+				if err := breakPage(cb.pageName, frontend.BreakPage); err != nil {
+					return err
+				}
+			}
+			for vl := t; vl != nil; {
+				var rest *node.VList
+				height = vl.Attributes["height"].(bag.ScaledPoint)
+				if height > y-pd.MarginBottom {
+					var first *node.VList
+					first, rest = splitVList(vl, y-pd.MarginBottom, !cb.pageStarted)
+					switch {
+					case first != nil:
+						vl = first
+						height = first.Attributes["height"].(bag.ScaledPoint)
+					case cb.pageStarted:
+						if err := breakPage(cb.pageName, frontend.BreakPage); err != nil {
+							return err
+						}
+						continue
+					default:
+						rest = nil
+					}
+				}
+				assignPending()
+				x := vl.Attributes["x"].(bag.ScaledPoint)
+				cb.frontend.Doc.CurrentPage.OutputAt(x, y, vl)
+				y -= height
+				cb.pageStarted = true
+				if rest != nil {
+					if err := breakPage(cb.pageName, frontend.BreakPage); err != nil {
+						return err
+					}
+				}
+				vl = rest
+			}
 		}
 	}
+	assignPending()
+	return nil
+}
+
+// outputPositioned places the positioned box relative to the current page.
+func (cb *CSSBuilder) outputPositioned(pb *frontend.PositionedBox) error {
+	pd, err := cb.PageSize()
+	if err != nil {
+		return err
+	}
+	vl, x, y, err := cb.frontend.BuildPositioned(pb, pd.Width, pd.Height)
+	if err != nil {
+		return err
+	}
+	cb.frontend.Doc.CurrentPage.OutputAt(x, pd.Height-y, vl)
 	return nil
 }
 
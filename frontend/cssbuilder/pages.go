@@ -0,0 +1,308 @@
+package cssbuilder
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/csshtml"
+	"github.com/speedata/boxesandglue/frontend"
+)
+
+// pageInfo describes a page for the selection of the @page rules.
+type pageInfo struct {
+	// name is the page master name (CSS page), empty for the unnamed page.
+	name string
+	// number is the page number in the document starting with 1. Right
+	// pages have odd numbers. The number is 0 if the page is not in the
+	// document yet, then only the selectors without pseudo classes match.
+	number int
+	// groupNumber is the page number within the pages with the same name.
+	groupNumber int
+	// blank is true for the pages inserted by left and right page breaks.
+	blank bool
+}
+
+// pageInfo returns the description of the current page.
+func (cb *CSSBuilder) pageInfo() pageInfo {
+	return pageInfo{
+		name:        cb.pageName,
+		number:      len(cb.frontend.Doc.Pages) + 1,
+		groupNumber: cb.groupNumber,
+		blank:       cb.blank,
+	}
+}
+
+// pageSelector is the selector of an @page rule such as chapter:first.
+type pageSelector struct {
+	name   string
+	pseudo []string
+}
+
+func parsePageSelector(sel string) pageSelector {
+	parts := strings.Split(sel, ":")
+	return pageSelector{name: parts[0], pseudo: parts[1:]}
+}
+
+// specificity returns the specificity of the selector, the page name counts
+// more than :first, :blank and :nth() which count more than :left and :right.
+func (ps pageSelector) specificity() int {
+	spec := 0
+	if ps.name != "" {
+		spec += 100
+	}
+	for _, p := range ps.pseudo {
+		if p == "left" || p == "right" {
+			spec++
+		} else {
+			spec += 10
+		}
+	}
+	return spec
+}
+
+// matches returns true if the selector applies to the page. :first and :nth()
+// of a named page count the pages with this name, otherwise the pages of the
+// document.
+func (ps pageSelector) matches(pi pageInfo) bool {
+	if ps.name != "" && ps.name != pi.name {
+		return false
+	}
+	if len(ps.pseudo) > 0 && pi.number == 0 {
+		return false
+	}
+	n := pi.number
+	if ps.name != "" {
+		n = pi.groupNumber
+	}
+	for _, p := range ps.pseudo {
+		switch {
+		case p == "first":
+			if n != 1 {
+				return false
+			}
+		case p == "blank":
+			if !pi.blank {
+				return false
+			}
+		case p == "left":
+			if pi.number%2 == 1 {
+				return false
+			}
+		case p == "right":
+			if pi.number%2 == 0 {
+				return false
+			}
+		case strings.HasPrefix(p, "nth(") && strings.HasSuffix(p, ")"):
+			a, b, ok := parseNth(p[4 : len(p)-1])
+			if !ok || !matchNth(a, b, n) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseNth returns a and b of the argument an+b of :nth() such as 2n+1, odd
+// or 3.
+func parseNth(arg string) (int, int, bool) {
+	switch arg {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	}
+	pos := strings.IndexByte(arg, 'n')
+	if pos < 0 {
+		b, err := strconv.Atoi(arg)
+		return 0, b, err == nil
+	}
+	var a, b int
+	switch aStr := arg[:pos]; aStr {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(aStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	if bStr := strings.TrimPrefix(arg[pos+1:], "+"); bStr != "" {
+		var err error
+		if b, err = strconv.Atoi(bStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
+
+// matchNth returns true if n = a*i+b for an integer i >= 0.
+func matchNth(a, b, n int) bool {
+	if a == 0 {
+		return n == b
+	}
+	return (n-b)%a == 0 && (n-b)/a >= 0
+}
+
+// mergePages returns a page master with the rules of the pages. Later pages
+// override the size, the margins, the properties and the properties of the
+// page margin boxes of the earlier pages.
+func mergePages(pages []csshtml.Page) *csshtml.Page {
+	ret := &csshtml.Page{PageArea: map[string]map[string]string{}}
+	for _, pg := range pages {
+		if pg.Papersize != "" {
+			ret.Papersize = pg.Papersize
+		}
+		if pg.MarginTop != "" {
+			ret.MarginTop = pg.MarginTop
+		}
+		if pg.MarginRight != "" {
+			ret.MarginRight = pg.MarginRight
+		}
+		if pg.MarginBottom != "" {
+			ret.MarginBottom = pg.MarginBottom
+		}
+		if pg.MarginLeft != "" {
+			ret.MarginLeft = pg.MarginLeft
+		}
+		ret.Attributes = append(ret.Attributes, pg.Attributes...)
+		for area, rules := range pg.PageArea {
+			if ret.PageArea[area] == nil {
+				ret.PageArea[area] = map[string]string{}
+			}
+			for k, v := range rules {
+				ret.PageArea[area][k] = v
+			}
+		}
+	}
+	return ret
+}
+
+// pageMaster returns the page master for the page which combines all matching
+// @page rules in the order of their specificity. It returns nil if no @page
+// rule matches.
+func (cb *CSSBuilder) pageMaster(pi pageInfo) *csshtml.Page {
+	var selectors []string
+	for sel := range cb.css.Pages {
+		if parsePageSelector(sel).matches(pi) {
+			selectors = append(selectors, sel)
+		}
+	}
+	if len(selectors) == 0 {
+		return nil
+	}
+	sort.Slice(selectors, func(i, j int) bool {
+		si, sj := parsePageSelector(selectors[i]).specificity(), parsePageSelector(selectors[j]).specificity()
+		if si != sj {
+			return si < sj
+		}
+		return selectors[i] < selectors[j]
+	})
+	pages := make([]csshtml.Page, len(selectors))
+	for i, sel := range selectors {
+		pages[i] = cb.css.Pages[sel]
+	}
+	return mergePages(pages)
+}
+
+// pageArea returns the horizontal position and the width of the page area of
+// the page master name (frontend.CallbackPageArea).
+func (cb *CSSBuilder) pageArea(name string) (bag.ScaledPoint, bag.ScaledPoint) {
+	dim, _, _, err := cb.pageDimensions(cb.pageMaster(pageInfo{name: name}))
+	if err != nil {
+		bag.Logger.Error("cannot get the page area", "page", name, "error", err)
+		return cb.currentPageDimensions.MarginLeft, cb.currentPageDimensions.ContentWidth
+	}
+	return dim.MarginLeft, dim.ContentWidth
+}
+
+// outputFixed places the boxes with position: fixed on the current page.
+func (cb *CSSBuilder) outputFixed() error {
+	for _, pb := range cb.fixed {
+		if err := cb.outputPositioned(pb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage puts the current page into the PDF document and starts a new page
+// with the page master name.
+func (cb *CSSBuilder) nextPage(name string) error {
+	if err := cb.InitPage(); err != nil {
+		return err
+	}
+	if err := cb.BeforeShipout(); err != nil {
+		return err
+	}
+	if len(cb.deferred) == 0 {
+		cb.frontend.Doc.CurrentPage.Shipout()
+	}
+	cb.namedStrings = cb.namedStrings.next()
+	cb.runningElements = cb.runningElements.next()
+	cb.pageStarted = false
+	if name != cb.pageName {
+		cb.pageName = name
+		cb.groupNumber = 0
+	}
+	cb.blank = false
+	cb.frontend.Doc.CurrentPage = nil
+	if err := cb.initPage(); err != nil {
+		return err
+	}
+	return cb.outputFixed()
+}
+
+// replacePage replaces the current page which has no contents yet by a page
+// with the page master name. A blank page is a page inserted by a left or a
+// right page break.
+func (cb *CSSBuilder) replacePage(name string, blank bool) error {
+	doc := cb.frontend.Doc
+	doc.Pages = doc.Pages[:len(doc.Pages)-1]
+	doc.CurrentPage = nil
+	cb.pageNumber--
+	if !cb.blank {
+		cb.groupNumber--
+	}
+	if name != cb.pageName {
+		cb.pageName = name
+		cb.groupNumber = 0
+	}
+	cb.blank = blank
+	if err := cb.initPage(); err != nil {
+		return err
+	}
+	return cb.outputFixed()
+}
+
+// breakPage starts a page with the page master name unless the current page
+// has no contents yet and has the same page master. For left and right page
+// breaks a blank page is inserted if necessary.
+func (cb *CSSBuilder) breakPage(name string, br frontend.Break) error {
+	if err := cb.InitPage(); err != nil {
+		return err
+	}
+	if cb.pageStarted {
+		if err := cb.nextPage(name); err != nil {
+			return err
+		}
+	} else if name != cb.pageName {
+		if err := cb.replacePage(name, false); err != nil {
+			return err
+		}
+	}
+	isRight := len(cb.frontend.Doc.Pages)%2 == 1
+	if br == frontend.BreakLeft && isRight || br == frontend.BreakRight && !isRight {
+		// the empty page becomes a blank page
+		if err := cb.replacePage(name, true); err != nil {
+			return err
+		}
+		return cb.nextPage(name)
+	}
+	return nil
+}
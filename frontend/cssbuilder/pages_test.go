@@ -0,0 +1,82 @@
+package cssbuilder
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/csshtml"
+)
+
+func TestPageSelectorMatches(t *testing.T) {
+	testdata := []struct {
+		selector string
+		pi       pageInfo
+		want     bool
+	}{
+		{"", pageInfo{name: "chapter", number: 4}, true},
+		{":first", pageInfo{number: 1}, true},
+		{":first", pageInfo{name: "chapter", number: 3, groupNumber: 1}, false},
+		{"chapter:first", pageInfo{name: "chapter", number: 3, groupNumber: 1}, true},
+		{"chapter:first", pageInfo{name: "index", number: 3, groupNumber: 1}, false},
+		{":left", pageInfo{number: 2}, true},
+		{":right", pageInfo{number: 2}, false},
+		{":nth(2n+1)", pageInfo{number: 5}, true},
+		{":nth(2n+1)", pageInfo{number: 4}, false},
+		{"chapter:nth(-n+2)", pageInfo{name: "chapter", number: 9, groupNumber: 2}, true},
+		{"chapter:nth(-n+2)", pageInfo{name: "chapter", number: 9, groupNumber: 3}, false},
+		{":blank", pageInfo{number: 2, blank: true}, true},
+		{":blank", pageInfo{number: 2}, false},
+		{"chapter", pageInfo{name: "chapter"}, true},
+		{"chapter:left", pageInfo{name: "chapter"}, false},
+	}
+	for _, td := range testdata {
+		if got := parsePageSelector(td.selector).matches(td.pi); got != td.want {
+			t.Errorf("%q matches %+v = %t, want %t", td.selector, td.pi, got, td.want)
+		}
+	}
+}
+
+func TestParseNth(t *testing.T) {
+	testdata := []struct {
+		arg  string
+		a, b int
+	}{
+		{"2n+1", 2, 1},
+		{"odd", 2, 1},
+		{"even", 2, 0},
+		{"3", 0, 3},
+		{"n", 1, 0},
+		{"-n+3", -1, 3},
+		{"3n-2", 3, -2},
+	}
+	for _, td := range testdata {
+		a, b, ok := parseNth(td.arg)
+		if !ok || a != td.a || b != td.b {
+			t.Errorf("parseNth(%s) = %d, %d, %t, want %d, %d", td.arg, a, b, ok, td.a, td.b)
+		}
+	}
+	if _, _, ok := parseNth("2x"); ok {
+		t.Error("parseNth(2x) is valid, want invalid")
+	}
+}
+
+func TestPageMaster(t *testing.T) {
+	cb := &CSSBuilder{css: &csshtml.CSS{Pages: map[string]csshtml.Page{
+		"":              {Papersize: "a4", MarginTop: "2cm"},
+		":right":        {MarginLeft: "3cm"},
+		"chapter":       {Papersize: "a4 landscape", PageArea: map[string]map[string]string{"top-center": {"content": `"Chapter"`}}},
+		"chapter:first": {PageArea: map[string]map[string]string{"top-center": {"content": "none"}}},
+	}}}
+	pg := cb.pageMaster(pageInfo{name: "chapter", number: 3, groupNumber: 1})
+	if pg.Papersize != "a4 landscape" || pg.MarginTop != "2cm" || pg.MarginLeft != "3cm" {
+		t.Errorf("pageMaster() = %+v, want a4 landscape with margins 2cm and 3cm", pg)
+	}
+	if got := pg.PageArea["top-center"]["content"]; got != "none" {
+		t.Errorf("top-center content = %q, want none", got)
+	}
+	if pg = cb.pageMaster(pageInfo{name: "chapter", number: 4, groupNumber: 2}); pg.MarginLeft != "" || pg.PageArea["top-center"]["content"] != `"Chapter"` {
+		t.Errorf("pageMaster() of the second chapter page = %+v", pg)
+	}
+	if pg = (&CSSBuilder{css: &csshtml.CSS{}}).pageMaster(pageInfo{number: 1}); pg != nil {
+		t.Errorf("pageMaster() without @page rules = %+v, want nil", pg)
+	}
+}
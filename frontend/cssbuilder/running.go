@@ -0,0 +1,88 @@
+package cssbuilder
+
+// pageValue is a named string or a running element which is assigned on a
+// page.
+type pageValue struct {
+	value any
+	// start is true if the value is assigned before the first contents of
+	// the page.
+	start bool
+}
+
+// pageValues contains the named strings (string-set) or the running elements
+// (position: running(name)) of a page.
+type pageValues struct {
+	// entry contains the values at the start of the page.
+	entry map[string]any
+	// assigned contains the values assigned on the page in document order.
+	assigned map[string][]pageValue
+}
+
+func newPageValues() pageValues {
+	return pageValues{
+		entry:    map[string]any{},
+		assigned: map[string][]pageValue{},
+	}
+}
+
+// assign adds the value of name on the current page.
+func (pv pageValues) assign(name string, value any, start bool) {
+	pv.assigned[name] = append(pv.assigned[name], pageValue{value: value, start: start})
+}
+
+// value returns the value of name on the page for the keyword first, start,
+// last or first-except (as in string(name, last)). It returns nil if name has
+// no value on the page.
+func (pv pageValues) value(name, keyword string) any {
+	assigned := pv.assigned[name]
+	if len(assigned) == 0 {
+		return pv.entry[name]
+	}
+	switch keyword {
+	case "start":
+		if assigned[0].start {
+			return assigned[0].value
+		}
+		return pv.entry[name]
+	case "last":
+		return assigned[len(assigned)-1].value
+	case "first-except":
+		return nil
+	default:
+		return assigned[0].value
+	}
+}
+
+// next returns the values for the following page. The last value assigned on
+// this page is the entry value of the next page.
+func (pv pageValues) next() pageValues {
+	ret := newPageValues()
+	for name, value := range pv.entry {
+		ret.entry[name] = value
+	}
+	for name, assigned := range pv.assigned {
+		ret.entry[name] = assigned[len(assigned)-1].value
+	}
+	return ret
+}
+
+// marginContext contains the values which are shown in the page margin boxes
+// of a page.
+type marginContext struct {
+	// number is the value of the page counter.
+	number int
+	// pages is the total number of pages or 0 if it is not known yet.
+	pages           int
+	namedStrings    pageValues
+	runningElements pageValues
+}
+
+// marginContext returns the values for the page margin boxes of the current
+// page.
+func (cb *CSSBuilder) marginContext() marginContext {
+	return marginContext{
+		number:          cb.pageNumber,
+		namedStrings:    cb.namedStrings,
+		runningElements: cb.runningElements,
+	}
+}
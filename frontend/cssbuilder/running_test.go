@@ -0,0 +1,51 @@
+package cssbuilder
+
+import "testing"
+
+func TestPageValues(t *testing.T) {
+	pv := newPageValues()
+	pv.assign("chapter", "Intro", true)
+	pv = pv.next()
+	// the second page starts with text of the first chapter
+	pv.assign("chapter", "Usage", false)
+	pv.assign("chapter", "Reference", false)
+	testdata := []struct {
+		keyword string
+		want    any
+	}{
+		{"first", "Usage"},
+		{"start", "Intro"},
+		{"last", "Reference"},
+		{"first-except", nil},
+	}
+	for _, td := range testdata {
+		if got := pv.value("chapter", td.keyword); got != td.want {
+			t.Errorf("value(chapter, %s) = %v, want %v", td.keyword, got, td.want)
+		}
+	}
+	// no assignment on the third page
+	pv = pv.next()
+	if got := pv.value("chapter", "first-except"); got != "Reference" {
+		t.Errorf("value(chapter, first-except) = %v, want Reference", got)
+	}
+}
+
+func TestParseContent(t *testing.T) {
+	mc := marginContext{number: 2, namedStrings: newPageValues(), runningElements: newPageValues()}
+	mc.namedStrings.assign("chapter", "Intro", true)
+	cb := &CSSBuilder{}
+	testdata := []struct {
+		content string
+		want    string
+	}{
+		{`string( chapter ) " " counter( page )`, "Intro 2"},
+		// functions without arguments
+		{`string "x"`, "x"},
+		{`element`, ""},
+	}
+	for _, td := range testdata {
+		if got, elt := cb.parseContent(td.content, mc); got != td.want || elt != nil {
+			t.Errorf("parseContent(%s) = %q, %v, want %q", td.content, got, elt, td.want)
+		}
+	}
+}
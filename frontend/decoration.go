@@ -1,86 +1,611 @@
 package frontend
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/color"
+	"github.com/speedata/boxesandglue/backend/font"
 	"github.com/speedata/boxesandglue/backend/node"
 	"github.com/speedata/boxesandglue/frontend/pdfdraw"
 )
 
-type styles struct {
-	isUnderline bool
-	ulpos       bag.ScaledPoint
-	linewidth   bag.ScaledPoint
+// textDecoration holds everything needed to draw the lines of a text
+// decoration once the paragraph is broken into lines. It is stored in the
+// start node of the decorated text.
+type textDecoration struct {
+	line      TextDecorationLine
+	style     TextDecorationStyle
+	color     *color.Color
+	linewidth bag.ScaledPoint
+	ulpos     bag.ScaledPoint
+	olpos     bag.ScaledPoint
+	ltpos     bag.ScaledPoint
+	skipInk   bool
+}
+
+// setupTextDecoration fills td with the positions and the line width derived
+// from the font and overrides them with the settings from ts.
+func (fe *Document) setupTextDecoration(td *textDecoration, ts TypesettingSettings, fnt *font.Font, col *color.Color) {
+	fontsize := fnt.Size
+	td.linewidth = fontsize / 20
+	td.ulpos = -fontsize / 6
+	td.olpos = fontsize - fnt.Depth
+	td.ltpos = fontsize / 4
+	td.color = col
+	if v, ok := ts[SettingTextDecorationStyle]; ok {
+		if sty, ok := v.(TextDecorationStyle); ok {
+			td.style = sty
+		}
+	}
+	if v, ok := ts[SettingTextDecorationColor]; ok {
+		switch t := v.(type) {
+		case string:
+			if c := fe.GetColor(t); c != nil {
+				td.color = c
+			}
+		case *color.Color:
+			if t != nil {
+				td.color = t
+			}
+		}
+	}
+	if v, ok := ts[SettingTextDecorationThickness]; ok {
+		if lw, ok := v.(bag.ScaledPoint); ok && lw > 0 {
+			td.linewidth = lw
+		}
+	}
+	if v, ok := ts[SettingTextUnderlineOffset]; ok {
+		if offset, ok := v.(bag.ScaledPoint); ok {
+			td.ulpos = -offset
+		}
+	}
+	if v, ok := ts[SettingTextDecorationSkipInk]; ok {
+		if skip, ok := v.(bool); ok {
+			td.skipInk = skip
+		}
+	}
+}
+
+// decorationSettings are the settings which describe a text decoration.
+var decorationSettings = []SettingType{
+	SettingColor,
+	SettingTextDecorationColor,
+	SettingTextDecorationSkipInk,
+	SettingTextDecorationStyle,
+	SettingTextDecorationThickness,
+	SettingTextUnderlineOffset,
+}
+
+// textDecorations returns the text decorations of the contents of an element
+// with the settings ts inside elements with the decorations outer. The
+// decorations of the ancestors are propagated to the contents and the
+// decoration lines of ts which are not drawn by an ancestor are added.
+func textDecorations(outer []TypesettingSettings, ts TypesettingSettings) []TypesettingSettings {
+	line, _ := ts[SettingTextDecorationLine].(TextDecorationLine)
+	for _, dec := range outer {
+		line &^= dec[SettingTextDecorationLine].(TextDecorationLine)
+	}
+	if line == TextDecorationLineNone {
+		return outer
+	}
+	dec := TypesettingSettings{SettingTextDecorationLine: line}
+	for _, k := range decorationSettings {
+		if v, ok := ts[k]; ok {
+			dec[k] = v
+		}
+	}
+	return append(outer[:len(outer):len(outer)], dec)
+}
+
+// decorate surrounds the node list nl of a text with the settings ts with
+// start and stop nodes for each of the decorations, the outer decorations
+// first. The positions of the lines depend on the font of the text. The new
+// head of the list is returned.
+func (fe *Document) decorate(decorations []TypesettingSettings, ts TypesettingSettings, nl node.Node) (node.Node, error) {
+	if len(decorations) == 0 || nl == nil {
+		return nl, nil
+	}
+	fnt, err := fe.settingsFont(ts)
+	if err != nil {
+		return nil, err
+	}
+	end := node.Tail(nl)
+	for i := len(decorations) - 1; i >= 0; i-- {
+		dec := decorations[i]
+		var col *color.Color
+		switch t := dec[SettingColor].(type) {
+		case string:
+			col = fe.GetColor(t)
+		case *color.Color:
+			col = t
+		}
+		td := &textDecoration{line: dec[SettingTextDecorationLine].(TextDecorationLine)}
+		fe.setupTextDecoration(td, dec, fnt, col)
+		start := node.NewStartStop()
+		start.Action = node.ActionUserSetting
+		node.SetAttribute(start, "textdecoration", td)
+		stop := node.NewStartStop()
+		stop.StartNode = start
+		node.SetAttribute(stop, "textdecoration", false)
+		nl = node.InsertBefore(nl, nl, start)
+		node.InsertAfter(nl, end, stop)
+		end = stop
+	}
+	return nl, nil
+}
+
+// skipInkGaps returns the horizontal ranges (relative to start) where glyphs
+// reach into the underline.
+func skipInkGaps(start, stop node.Node, td *textDecoration) [][2]bag.ScaledPoint {
+	var gaps [][2]bag.ScaledPoint
+	var x bag.ScaledPoint
+	ulTop := td.ulpos + td.linewidth/2
+	for e := start; e != nil; e = e.Next() {
+		wd, _, _ := node.Dimensions(e, e, node.Horizontal)
+		if g, ok := e.(*node.Glyph); ok {
+			if g.YOffset-g.Depth < ulTop {
+				left, right := x-td.linewidth, x+wd+td.linewidth
+				if l := len(gaps); l > 0 && gaps[l-1][1] >= left {
+					gaps[l-1][1] = right
+				} else {
+					gaps = append(gaps, [2]bag.ScaledPoint{left, right})
+				}
+			}
+		}
+		x += wd
+		if e == stop {
+			break
+		}
+	}
+	return gaps
+}
+
+// wavyLine appends a wave from x1 to x2 around the vertical position y.
+func wavyLine(pd *pdfdraw.Object, x1, x2, y, lw bag.ScaledPoint) {
+	amplitude := lw * 3 / 2
+	halfPeriod := lw * 4
+	if halfPeriod <= 0 {
+		pd.Moveto(x1, y).Lineto(x2, y)
+		return
+	}
+	// distribute the half waves evenly between x1 and x2
+	count := int((x2 - x1 + halfPeriod - 1) / halfPeriod)
+	pd.Moveto(x1, y)
+	sign := bag.ScaledPoint(1)
+	for i := 0; i < count; i++ {
+		x := x1 + (x2-x1)*bag.ScaledPoint(i)/bag.ScaledPoint(count)
+		next := x1 + (x2-x1)*bag.ScaledPoint(i+1)/bag.ScaledPoint(count)
+		d := next - x
+		ctrlY := y + sign*amplitude*4/3
+		pd.Curveto(x+d/3, ctrlY, x+d*2/3, ctrlY, next, y)
+		sign = -sign
+	}
+}
+
+// decorationPDF returns the PDF instructions to draw the lines of td from 0
+// to wd. The gaps are left out of the underline.
+func decorationPDF(td *textDecoration, wd bag.ScaledPoint, gaps [][2]bag.ScaledPoint) string {
+	lw := td.linewidth
+	pd := pdfdraw.NewStandalone()
+	if td.color != nil {
+		pd.ColorStroking(*td.color)
+	}
+	pd.LineWidth(lw)
+	switch td.style {
+	case TextDecorationStyleDotted:
+		pd.Literal(fmt.Sprintf("1 J [0 %s] 0 d", 2*lw))
+	case TextDecorationStyleDashed:
+		pd.Literal(fmt.Sprintf("[%s %s] 0 d", 3*lw, 2*lw))
+	}
+	type decorationLine struct {
+		y    bag.ScaledPoint
+		away bag.ScaledPoint
+		gaps [][2]bag.ScaledPoint
+	}
+	var lines []decorationLine
+	if td.line&TextDecorationUnderline != 0 {
+		lines = append(lines, decorationLine{td.ulpos, -2 * lw, gaps})
+	}
+	if td.line&TextDecorationOverline != 0 {
+		lines = append(lines, decorationLine{td.olpos, 2 * lw, nil})
+	}
+	if td.line&TextDecorationLineThrough != 0 {
+		lines = append(lines, decorationLine{td.ltpos + lw, -2 * lw, nil})
+	}
+	for _, l := range lines {
+		ys := []bag.ScaledPoint{l.y}
+		if td.style == TextDecorationStyleDouble {
+			ys = append(ys, l.y+l.away)
+		}
+		// the segments between the gaps
+		var segments [][2]bag.ScaledPoint
+		x := bag.ScaledPoint(0)
+		for _, g := range l.gaps {
+			if g[0] > x {
+				segments = append(segments, [2]bag.ScaledPoint{x, g[0]})
+			}
+			x = g[1]
+		}
+		if x < wd {
+			segments = append(segments, [2]bag.ScaledPoint{x, wd})
+		}
+		for _, y := range ys {
+			for _, seg := range segments {
+				if td.style == TextDecorationStyleWavy {
+					wavyLine(pd, seg[0], seg[1], y, lw)
+				} else {
+					pd.Moveto(seg[0], y).Lineto(seg[1], y)
+				}
+			}
+		}
+	}
+	pd.Stroke()
+	return pd.String()
 }
 
-func doUnderline(head, start, stop node.Node, st *styles) node.Node {
+// doDecoration inserts a rule before start which draws the text decoration
+// from start to stop. Glue at the beginning and the end of the range is not
+// decorated.
+func doDecoration(head, start, stop node.Node, td *textDecoration) node.Node {
+	for start != stop {
+		if _, ok := start.(*node.Glue); !ok {
+			break
+		}
+		start = start.Next()
+	}
+	for stop != start {
+		switch stop.(type) {
+		case *node.Glue, *node.Penalty:
+			stop = stop.Prev()
+			continue
+		}
+		break
+	}
 	wd, _, _ := node.Dimensions(start, stop, node.Horizontal)
-	pdfUL := pdfdraw.NewStandalone().LineWidth(st.linewidth).Moveto(0, st.ulpos).Lineto(wd, st.ulpos).Stroke().String()
+	if wd <= 0 {
+		return head
+	}
+	var gaps [][2]bag.ScaledPoint
+	if td.skipInk && td.line&TextDecorationUnderline != 0 {
+		gaps = skipInkGaps(start, stop, td)
+	}
 	r := node.NewRule()
 	r.Hide = true
-	r.Pre = pdfUL
+	r.Pre = decorationPDF(td, wd, gaps)
 	head = node.InsertBefore(head, start, r)
 	return head
 }
 
+// inlineBox is stored in the start node of an inline element that has a
+// background, a border or padding.
+type inlineBox struct {
+	hv     HTMLValues
+	clone  bool
+	height bag.ScaledPoint
+	depth  bag.ScaledPoint
+}
+
+// wrapInlineBox surrounds the node list from nl to end with start and stop
+// nodes if the settings ts describe an inline box. The left and right padding
+// and border are inserted as kerns. The new head and tail are returned.
+func (fe *Document) wrapInlineBox(ts TypesettingSettings, nl, end node.Node) (node.Node, node.Node) {
+	// SettingsToValues removes the padding from the settings, but the text
+	// might be formatted more than once.
+	tmp := make(TypesettingSettings, len(ts))
+	for k, v := range ts {
+		tmp[k] = v
+	}
+	hv := SettingsToValues(tmp)
+	if !hv.hasBackground() && !hv.hasBorder() && !hv.hasPadding() {
+		return nl, end
+	}
+	hv.setDefaultBorderColors(fe.GetColor("black"))
+	_, ht, dp := node.Dimensions(nl, end, node.Horizontal)
+	ib := &inlineBox{
+		hv:     hv,
+		height: ht,
+		depth:  dp,
+	}
+	if bdb, ok := ts[SettingBoxDecorationBreak]; ok {
+		ib.clone = bdb == BoxDecorationBreakClone
+	}
+	start := node.NewStartStop()
+	start.Action = node.ActionUserSetting
+	node.SetAttribute(start, "inlinebox", ib)
+	nl = node.InsertBefore(nl, nl, start)
+	if wd := hv.PaddingLeft + hv.BorderLeftWidth; wd != 0 {
+		k := node.NewKern()
+		k.Kern = wd
+		k.Attributes = node.H{"origin": "inline padding left + border left"}
+		nl = node.InsertAfter(nl, start, k)
+	}
+	if wd := hv.PaddingRight + hv.BorderRightWidth; wd != 0 {
+		k := node.NewKern()
+		k.Kern = wd
+		k.Attributes = node.H{"origin": "inline padding right + border right"}
+		nl = node.InsertAfter(nl, end, k)
+		end = k
+	}
+	stop := node.NewStartStop()
+	stop.StartNode = start
+	node.SetAttribute(stop, "inlinebox", false)
+	nl = node.InsertAfter(nl, end, stop)
+	return nl, stop
+}
+
+// inlineBoxFragment is the part of an inline box in one line.
+type inlineBoxFragment struct {
+	box         *inlineBox
+	start, stop node.Node
+	first, last bool
+	level       int
+}
+
+// cloneKern returns a kern with the width of the padding and the border on
+// one side of a cloned inline box.
+func cloneKern(ib *inlineBox, wd bag.ScaledPoint, origin string) *node.Kern {
+	k := node.NewKern()
+	k.Kern = wd
+	k.Attributes = node.H{"origin": origin, "clonebox": ib}
+	return k
+}
+
+// reserveClonePadding inserts kerns with the right padding and border before
+// and the left padding and border after each break point inside the inline
+// boxes with box-decoration-break: clone, so the line breaker sees the width
+// of the cloned decoration. The width of an interword glue is reduced by the
+// inserted kerns, so the text keeps its width if the line does not break
+// there. Discretionaries get the kerns in the pre and post lists.
+func reserveClonePadding(head node.Node) {
+	var open []*inlineBox
+	for e := head; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.StartStop:
+			val, ok := node.GetAttribute(t, "inlinebox")
+			if !ok {
+				continue
+			}
+			if ib, ok := val.(*inlineBox); ok {
+				open = append(open, ib)
+			} else if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case *node.Glue:
+			switch t.Prev().(type) {
+			case nil, *node.Glue, *node.Penalty:
+				// not a break point
+				continue
+			}
+			// the kerns of the inner boxes are next to the text
+			for i := len(open) - 1; i >= 0; i-- {
+				ib := open[i]
+				if !ib.clone {
+					continue
+				}
+				if wd := ib.hv.PaddingRight + ib.hv.BorderRightWidth; wd != 0 {
+					node.InsertBefore(head, t, cloneKern(ib, wd, "inline padding right + border right (clone)"))
+					t.Width -= wd
+				}
+				if wd := ib.hv.PaddingLeft + ib.hv.BorderLeftWidth; wd != 0 {
+					node.InsertAfter(head, t, cloneKern(ib, wd, "inline padding left + border left (clone)"))
+					t.Width -= wd
+				}
+			}
+			for e.Next() != nil {
+				if k, ok := e.Next().(*node.Kern); !ok || k.Attributes["clonebox"] == nil {
+					break
+				}
+				e = e.Next()
+			}
+		case *node.Disc:
+			for i := len(open) - 1; i >= 0; i-- {
+				ib := open[i]
+				if !ib.clone {
+					continue
+				}
+				if wd := ib.hv.PaddingRight + ib.hv.BorderRightWidth; wd != 0 {
+					k := cloneKern(ib, wd, "inline padding right + border right (clone)")
+					t.Pre = node.InsertAfter(t.Pre, node.Tail(t.Pre), k)
+				}
+				if wd := ib.hv.PaddingLeft + ib.hv.BorderLeftWidth; wd != 0 {
+					k := cloneKern(ib, wd, "inline padding left + border left (clone)")
+					t.Post = node.InsertBefore(t.Post, t.Post, k)
+				}
+			}
+		}
+	}
+}
+
+// outerCloneKern returns true if n is a kern of a cloned inline box with a
+// level below level.
+func outerCloneKern(n node.Node, level int, levels map[*inlineBox]int) bool {
+	k, ok := n.(*node.Kern)
+	if !ok {
+		return false
+	}
+	owner, ok := k.Attributes["clonebox"].(*inlineBox)
+	return ok && levels[owner] < level
+}
+
+// drawInlineBox inserts a rule with the background and the border of the
+// fragment before the start of the fragment.
+func drawInlineBox(head node.Node, frag *inlineBoxFragment) node.Node {
+	wd, _, _ := node.Dimensions(frag.start, frag.stop, node.Horizontal)
+	hv := frag.box.hv
+	if !frag.box.clone {
+		if !frag.first {
+			hv.BorderLeftWidth, hv.PaddingLeft = 0, 0
+			hv.BorderTopLeftRadius, hv.BorderBottomLeftRadius = 0, 0
+		}
+		if !frag.last {
+			hv.BorderRightWidth, hv.PaddingRight = 0, 0
+			hv.BorderTopRightRadius, hv.BorderBottomRightRadius = 0, 0
+		}
+	}
+	x0, x3 := bag.ScaledPoint(0), wd
+	y0 := frag.box.height + hv.PaddingTop + hv.BorderTopWidth
+	y3 := -frag.box.depth - hv.PaddingBottom - hv.BorderBottomWidth
+	maxTrapezoidThickness := bag.Max(0, bag.Min(x3-x0-hv.BorderLeftWidth-hv.BorderRightWidth, y0-y3-hv.BorderTopWidth-hv.BorderBottomWidth)/2)
+	x1 := x0 + hv.BorderLeftWidth + maxTrapezoidThickness
+	x2 := x3 - hv.BorderRightWidth - maxTrapezoidThickness
+	y1 := y0 - hv.BorderTopWidth - maxTrapezoidThickness
+	y2 := y3 + hv.BorderBottomWidth + maxTrapezoidThickness
+
+	var pdfinstructions []string
+	if hv.hasBackground() {
+		pdfinstructions = append(pdfinstructions, drawBackground(x0, y0, x1, y1, x2, y2, x3, y3, hv))
+	}
+	if hv.hasBorder() {
+		pdfinstructions = append(pdfinstructions, drawBorder(x0, y0, x1, y1, x2, y2, x3, y3, hv))
+	}
+	if len(pdfinstructions) == 0 {
+		return head
+	}
+	r := node.NewRule()
+	r.Hide = true
+	r.Pre = strings.Join(pdfinstructions, " ")
+	r.Attributes = node.H{"origin": "inline box"}
+	return node.InsertBefore(head, frag.start, r)
+}
+
+// postLinebreakInlineBoxes draws the background and the border of the inline
+// boxes in the line hl. Inline boxes that are not closed at the end of the
+// line are continued in the next line.
+func postLinebreakInlineBoxes(hl *node.HList, st *styles) {
+	var open, fragments []*inlineBoxFragment
+	for _, ib := range st.inlineBoxes {
+		open = append(open, &inlineBoxFragment{box: ib, start: hl.List, level: len(open)})
+	}
+	var tail node.Node
+	for e := hl.List; e != nil; e = e.Next() {
+		tail = e
+		ss, ok := e.(*node.StartStop)
+		if !ok {
+			continue
+		}
+		val, ok := node.GetAttribute(ss, "inlinebox")
+		if !ok {
+			continue
+		}
+		if ib, ok := val.(*inlineBox); ok {
+			open = append(open, &inlineBoxFragment{box: ib, start: ss, first: true, level: len(open)})
+		} else if len(open) > 0 {
+			frag := open[len(open)-1]
+			open = open[:len(open)-1]
+			frag.stop = e
+			frag.last = true
+			fragments = append(fragments, frag)
+		}
+	}
+	st.inlineBoxes = st.inlineBoxes[:0]
+	for _, frag := range open {
+		frag.stop = tail
+		fragments = append(fragments, frag)
+		st.inlineBoxes = append(st.inlineBoxes, frag.box)
+	}
+	if len(fragments) == 0 {
+		return
+	}
+	// outer boxes first, so they are drawn below the inner boxes
+	sort.SliceStable(fragments, func(i, j int) bool { return fragments[i].level < fragments[j].level })
+
+	// The kerns of cloned boxes at the line edges are inserted before line
+	// breaking (reserveClonePadding). The kerns of the outer boxes are
+	// outside of the fragments of the inner boxes.
+	levels := make(map[*inlineBox]int, len(fragments))
+	for _, frag := range fragments {
+		levels[frag.box] = frag.level
+	}
+	for _, frag := range fragments {
+		if !frag.first {
+			for frag.start != frag.stop {
+				if _, ok := frag.start.(*node.Glue); !ok && !outerCloneKern(frag.start, frag.level, levels) {
+					break
+				}
+				frag.start = frag.start.Next()
+			}
+		}
+		if !frag.last {
+			for frag.stop != frag.start {
+				switch frag.stop.(type) {
+				case *node.Glue, *node.Penalty:
+					frag.stop = frag.stop.Prev()
+					continue
+				}
+				if outerCloneKern(frag.stop, frag.level, levels) {
+					frag.stop = frag.stop.Prev()
+					continue
+				}
+				break
+			}
+		}
+	}
+	for _, frag := range fragments {
+		hl.List = drawInlineBox(hl.List, frag)
+	}
+}
+
+type styles struct {
+	// decorations are the text decorations which are continued in the next
+	// line, the outer decorations first.
+	decorations []*textDecoration
+	inlineBoxes []*inlineBox
+}
+
+// openDecoration is a text decoration which starts at the node start.
+type openDecoration struct {
+	td    *textDecoration
+	start node.Node
+}
+
+// postLinebreakHL draws the text decorations in the list n. Decorations can
+// be nested, each decoration is drawn from its start node to its stop node.
+// The decorations which are still active at the end of the list are
+// continued in the next call with the same styles.
 func postLinebreakHL(n node.Node, st *styles) node.Node {
-	underlineFromStart := st.isUnderline
-	var underlineStart, underlineStop node.Node
-	var head, tail node.Node
-	head = n
+	var tail node.Node
+	var open []openDecoration
+	head := n
+	for _, td := range st.decorations {
+		// continued from the previous line
+		open = append(open, openDecoration{td: td, start: n})
+	}
 	for e := n; e != nil; e = e.Next() {
 		tail = e
 		if hl, ok := e.(*node.HList); ok {
-			hl.List = postLinebreakHL(hl.List, st)
+			hl.List = postLinebreakHL(hl.List, &styles{})
 		} else if vl, ok := e.(*node.VList); ok {
-			vl.List = postLinebreakHL(vl.List, st)
+			vl.List = postLinebreakHL(vl.List, &styles{})
 		} else if ss, ok := e.(*node.StartStop); ok {
-			if val, ok := node.GetAttribute(ss, "underline"); ok {
-				if val.(bool) {
-					st.isUnderline = true
-					underlineStart = ss
-					if ulpos, ok := node.GetAttribute(ss, "underlinepos"); ok {
-						if ulpos != nil {
-							st.ulpos = ulpos.(bag.ScaledPoint)
-						}
-					}
-					if lw, ok := node.GetAttribute(ss, "underlinelw"); ok {
-						if lw != nil {
-							st.linewidth = lw.(bag.ScaledPoint)
-						}
-					}
-				} else {
-					if underlineFromStart {
-						head = doUnderline(head, head, e, st)
-					}
-					st.isUnderline = false
-					underlineStop = ss
+			if val, ok := node.GetAttribute(ss, "textdecoration"); ok {
+				if td, ok := val.(*textDecoration); ok {
+					open = append(open, openDecoration{td: td, start: ss})
+				} else if len(open) > 0 {
+					od := open[len(open)-1]
+					open = open[:len(open)-1]
+					head = doDecoration(head, od.start, e, od.td)
 				}
 			}
 		}
-		if underlineStart != nil && underlineStop != nil {
-			head = doUnderline(head, underlineStart, underlineStop, st)
-			underlineStart = nil
-			underlineStop = nil
-		}
 	}
-	if st.isUnderline {
-		if underlineStart == nil && underlineStop == nil {
-			// whole line
-			head = doUnderline(head, head, tail, st)
-		}
-		if underlineStart != nil {
-			// up to the end
-			head = doUnderline(head, underlineStart, tail, st)
-		}
+	st.decorations = st.decorations[:0]
+	for _, od := range open {
+		// up to the end of the line
+		head = doDecoration(head, od.start, tail, od.td)
+		st.decorations = append(st.decorations, od.td)
 	}
 	return head
 }
 
 func postLinebreak(vl *node.VList) *node.VList {
-	var e node.Node
-	for e = vl.List; e != nil; e = e.Next() {
+	st := &styles{}
+	for e := vl.List; e != nil; e = e.Next() {
 		if hl, ok := e.(*node.HList); ok {
-			postLinebreakHL(hl, &styles{})
+			postLinebreakInlineBoxes(hl, st)
+			hl.List = postLinebreakHL(hl.List, st)
 		}
 	}
 	return vl
@@ -0,0 +1,245 @@
+package frontend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
+)
+
+// lineEdges returns the first and the last node of the line contents without
+// the glue, the penalties, the start stop nodes and the inline box rules at
+// the start and at the end of the line.
+func lineEdges(hl *node.HList) (node.Node, node.Node) {
+	skip := func(n node.Node) bool {
+		switch t := n.(type) {
+		case *node.Glue, *node.Penalty, *node.StartStop:
+			return true
+		case *node.Rule:
+			return t.Attributes["origin"] == "inline box"
+		}
+		return false
+	}
+	first := hl.List
+	for skip(first) {
+		first = first.Next()
+	}
+	last := node.Tail(hl.List)
+	for skip(last) {
+		last = last.Prev()
+	}
+	return first, last
+}
+
+// minWidth returns the width of the list with all finite glue shrunk.
+func minWidth(head node.Node) bag.ScaledPoint {
+	wd, _, _ := node.Dimensions(head, nil, node.Horizontal)
+	for e := head; e != nil; e = e.Next() {
+		if g, ok := e.(*node.Glue); ok && g.ShrinkOrder == 0 {
+			wd -= g.Shrink
+		}
+	}
+	return wd
+}
+
+func TestInlineBoxFragments(t *testing.T) {
+	hsize := bag.MustSp("110pt")
+	padding := bag.MustSp("6pt")
+	testdata := []struct {
+		decorationBreak BoxDecorationBreak
+		cloned          bool
+	}{
+		{BoxDecorationBreakSlice, false},
+		{BoxDecorationBreakClone, true},
+	}
+	l, err := GetLanguage("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, td := range testdata {
+		var buf bytes.Buffer
+		fe, err := NewForWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ff := fe.NewFontFamily("text")
+		if err = ff.AddMember(&FontSource{Name: "crimson", Data: crimsonproregular.TTF}, FontWeight400, FontStyleNormal); err != nil {
+			t.Fatal(err)
+		}
+		box := &Text{
+			Settings: TypesettingSettings{
+				SettingBackgroundColor:    fe.GetColor("green"),
+				SettingPaddingLeft:        padding,
+				SettingPaddingRight:       padding,
+				SettingBoxDecorationBreak: td.decorationBreak,
+			},
+			Items: []any{"one two three four five six seven eight nine ten eleven twelve"},
+		}
+		te := &Text{Settings: TypesettingSettings{}, Items: []any{box}}
+		vl, _, err := fe.FormatParagraph(te, hsize, Family(ff), FontSize(10*bag.Factor), Language(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []*node.HList
+		for e := vl.List; e != nil; e = e.Next() {
+			if hl, ok := e.(*node.HList); ok {
+				lines = append(lines, hl)
+			}
+		}
+		if len(lines) < 3 {
+			t.Fatalf("got %d lines, want at least 3", len(lines))
+		}
+		for i, hl := range lines {
+			if wd := minWidth(hl.List); wd > hsize {
+				t.Errorf("%d line %d: minimum width %s exceeds %s", td.decorationBreak, i, wd, hsize)
+			}
+			first, last := lineEdges(hl)
+			// the padding of the first line comes from wrapInlineBox
+			startKern, ok := first.(*node.Kern)
+			if i == 0 || td.cloned {
+				if !ok || startKern.Kern != padding {
+					t.Errorf("%d line %d: fragment starts with %v, want padding kern", td.decorationBreak, i, first)
+				}
+			} else if ok {
+				t.Errorf("%d line %d: fragment starts with kern %s, want text", td.decorationBreak, i, startKern.Kern)
+			}
+			endKern, ok := last.(*node.Kern)
+			if i == len(lines)-1 || td.cloned {
+				if !ok || endKern.Kern != padding {
+					t.Errorf("%d line %d: fragment ends with %v, want padding kern", td.decorationBreak, i, last)
+				}
+			} else if ok {
+				t.Errorf("%d line %d: fragment ends with kern %s, want text", td.decorationBreak, i, endKern.Kern)
+			}
+		}
+	}
+}
+
+func TestReserveClonePadding(t *testing.T) {
+	outer := &inlineBox{clone: true, hv: HTMLValues{PaddingLeft: 1 * bag.Factor, PaddingRight: 2 * bag.Factor}}
+	inner := &inlineBox{clone: true, hv: HTMLValues{PaddingLeft: 3 * bag.Factor, BorderRightWidth: 4 * bag.Factor}}
+	var head, cur node.Node
+	add := func(n node.Node) {
+		head = node.InsertAfter(head, cur, n)
+		cur = n
+	}
+	for _, ib := range []*inlineBox{outer, inner} {
+		start := node.NewStartStop()
+		node.SetAttribute(start, "inlinebox", ib)
+		add(start)
+	}
+	add(node.NewGlyph())
+	g := node.NewGlue()
+	g.Width = 20 * bag.Factor
+	add(g)
+	add(node.NewGlyph())
+	for i := 0; i < 2; i++ {
+		stop := node.NewStartStop()
+		node.SetAttribute(stop, "inlinebox", false)
+		add(stop)
+	}
+	wd, _, _ := node.Dimensions(head, nil, node.Horizontal)
+	reserveClonePadding(head)
+	if got, _, _ := node.Dimensions(head, nil, node.Horizontal); got != wd {
+		t.Errorf("width = %s, want %s", got, wd)
+	}
+	// the kerns of the inner box are next to the text
+	want := []bag.ScaledPoint{4 * bag.Factor, 2 * bag.Factor, 10 * bag.Factor, 1 * bag.Factor, 3 * bag.Factor}
+	e := g.Prev().Prev()
+	for i, w := range want {
+		var got bag.ScaledPoint
+		switch n := e.(type) {
+		case *node.Kern:
+			got = n.Kern
+		case *node.Glue:
+			got = n.Width
+		}
+		if got != w {
+			t.Errorf("node %d: width %s, want %s", i, got, w)
+		}
+		e = e.Next()
+	}
+}
+
+func TestTextDecorations(t *testing.T) {
+	outer := textDecorations(nil, TypesettingSettings{
+		SettingTextDecorationLine:  TextDecorationUnderline,
+		SettingTextDecorationColor: "red",
+		SettingSize:                10 * bag.Factor,
+	})
+	if len(outer) != 1 || len(outer[0]) != 2 {
+		t.Fatalf("textDecorations() = %v, want underline with color", outer)
+	}
+	// the propagated underline is not drawn twice
+	if got := textDecorations(outer, TypesettingSettings{SettingTextDecorationLine: TextDecorationUnderline}); len(got) != 1 {
+		t.Errorf("propagated underline: got %d decorations, want 1", len(got))
+	}
+	got := textDecorations(outer, TypesettingSettings{SettingTextDecorationLine: TextDecorationUnderline | TextDecorationLineThrough})
+	if len(got) != 2 || got[1][SettingTextDecorationLine] != TextDecorationLineThrough {
+		t.Errorf("added line-through: got %v, want underline and line-through", got)
+	}
+	if len(outer) != 1 {
+		t.Errorf("outer decorations changed to %v", outer)
+	}
+}
+
+func TestPostLinebreakNestedDecorations(t *testing.T) {
+	underline := &textDecoration{line: TextDecorationUnderline, linewidth: bag.Factor}
+	linethrough := &textDecoration{line: TextDecorationLineThrough, linewidth: bag.Factor}
+	var head, cur node.Node
+	add := func(n node.Node) node.Node {
+		head = node.InsertAfter(head, cur, n)
+		cur = n
+		return n
+	}
+	glyph := func() {
+		g := node.NewGlyph()
+		g.Width = 10 * bag.Factor
+		add(g)
+	}
+	decorationStart := func(td *textDecoration) node.Node {
+		start := node.NewStartStop()
+		node.SetAttribute(start, "textdecoration", td)
+		return add(start)
+	}
+	decorationStop := func() {
+		stop := node.NewStartStop()
+		node.SetAttribute(stop, "textdecoration", false)
+		add(stop)
+	}
+	// underline a <line-through b> c, the underline continues in the next line
+	ulStart := decorationStart(underline)
+	glyph()
+	ltStart := decorationStart(linethrough)
+	glyph()
+	decorationStop()
+	glyph()
+
+	st := &styles{}
+	head = postLinebreakHL(head, st)
+	for _, start := range []node.Node{ulStart, ltStart} {
+		if _, ok := start.Prev().(*node.Rule); !ok {
+			t.Errorf("no rule before %v", start)
+		}
+	}
+	if r, ok := ulStart.Prev().(*node.Rule); ok && !strings.Contains(r.Pre, "30 ") {
+		t.Errorf("underline %q does not reach to 30pt", r.Pre)
+	}
+	if r, ok := ltStart.Prev().(*node.Rule); ok && strings.Contains(r.Pre, "20 ") {
+		t.Errorf("line-through %q reaches to 20pt, want 10pt", r.Pre)
+	}
+	if len(st.decorations) != 1 || st.decorations[0] != underline {
+		t.Fatalf("continued decorations = %v, want the underline", st.decorations)
+	}
+
+	head, cur = nil, nil
+	glyph()
+	decorationStop()
+	head = postLinebreakHL(head, st)
+	if _, ok := head.(*node.Rule); !ok || len(st.decorations) != 0 {
+		t.Errorf("continued underline not drawn at the start of the line")
+	}
+}
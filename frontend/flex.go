@@ -0,0 +1,454 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// FlexDirection is the direction of the main axis of a flex container.
+type FlexDirection uint
+
+const (
+	// FlexDirectionRow places the items from left to right.
+	FlexDirectionRow FlexDirection = iota
+	// FlexDirectionRowReverse places the items from right to left.
+	FlexDirectionRowReverse
+	// FlexDirectionColumn places the items from top to bottom.
+	FlexDirectionColumn
+	// FlexDirectionColumnReverse places the items from top to bottom in
+	// reverse order.
+	FlexDirectionColumnReverse
+)
+
+// FlexJustify distributes the free space on the main axis.
+type FlexJustify uint
+
+const (
+	// FlexJustifyStart packs the items at the start of the line.
+	FlexJustifyStart FlexJustify = iota
+	// FlexJustifyEnd packs the items at the end of the line.
+	FlexJustifyEnd
+	// FlexJustifyCenter packs the items in the middle of the line.
+	FlexJustifyCenter
+	// FlexJustifySpaceBetween puts the free space between the items.
+	FlexJustifySpaceBetween
+	// FlexJustifySpaceAround puts half of the space between two items before
+	// the first and after the last item.
+	FlexJustifySpaceAround
+	// FlexJustifySpaceEvenly puts the same space between the items and at the
+	// start and the end of the line.
+	FlexJustifySpaceEvenly
+)
+
+// FlexAlign aligns the items on the cross axis.
+type FlexAlign uint
+
+const (
+	// FlexAlignAuto is the alignment of the container (align-self) or
+	// FlexAlignStretch (align-items).
+	FlexAlignAuto FlexAlign = iota
+	// FlexAlignStretch makes the items as high as the line.
+	FlexAlignStretch
+	// FlexAlignStart places the items at the start of the cross axis.
+	FlexAlignStart
+	// FlexAlignEnd places the items at the end of the cross axis.
+	FlexAlignEnd
+	// FlexAlignCenter centers the items on the cross axis.
+	FlexAlignCenter
+)
+
+// FlexContainer is the value of SettingFlex.
+type FlexContainer struct {
+	Direction  FlexDirection
+	Wrap       bool
+	Justify    FlexJustify
+	AlignItems FlexAlign
+	RowGap     bag.ScaledPoint
+	ColumnGap  bag.ScaledPoint
+}
+
+// FlexItem is the value of SettingFlexItem. Items without this setting do
+// not grow, shrink with factor 1 and have the basis auto.
+type FlexItem struct {
+	Grow   float64
+	Shrink float64
+	// Basis is nil (auto), a bag.ScaledPoint or a percentage string of the
+	// container width.
+	Basis     any
+	AlignSelf FlexAlign
+}
+
+// flexItem is an item of a flex container during the layout. The widths are
+// the widths of the content box, max is bag.MaxSP for an item without a
+// maximum width.
+type flexItem struct {
+	te     *Text
+	extra  bag.ScaledPoint
+	min    bag.ScaledPoint
+	max    bag.ScaledPoint
+	basis  bag.ScaledPoint
+	size   bag.ScaledPoint
+	grow   float64
+	shrink float64
+	align  FlexAlign
+}
+
+// outerWidth returns the width of the item's margin box.
+func (it *flexItem) outerWidth() bag.ScaledPoint {
+	return it.size + it.extra
+}
+
+// contentsText returns a Text with the items and the settings of te but
+// without the settings of the box (margins, borders, ...).
+func contentsText(te *Text) *Text {
+	contents := NewText()
+	for k, v := range te.Settings {
+		if !isBoxSetting(k) && k != SettingWidth {
+			contents.Settings[k] = v
+		}
+	}
+	if bx, ok := te.Settings[SettingBox]; ok {
+		contents.Settings[SettingBox] = bx
+	}
+	contents.Items = te.Items
+	return contents
+}
+
+// IntrinsicWidths returns the smallest width of the contents of te without
+// overfull lines (the longest word) and the width without line breaks.
+func (fe *Document) IntrinsicWidths(te *Text) (bag.ScaledPoint, bag.ScaledPoint, error) {
+	contents := contentsText(te)
+	if _, ok := contents.Settings[SettingBox]; !ok {
+		// a paragraph is only placed in the page box of a surrounding box
+		box := NewText()
+		box.Settings[SettingBox] = true
+		box.Items = append(box.Items, contents)
+		contents = box
+	}
+	vl, err := fe.CreateVlist(contents, 1*bag.Factor)
+	if err != nil {
+		return 0, 0, err
+	}
+	minWd := minBoxWidth(vl)
+	if vl, err = fe.CreateVlist(contents, bag.MaxSP); err != nil {
+		return 0, 0, err
+	}
+	return minWd, bag.Max(minWd, maxBoxWidth(vl)), nil
+}
+
+// newFlexItems measures the items of the flex container te.
+func (fe *Document) newFlexItems(te *Text, fc FlexContainer, hsize bag.ScaledPoint) ([]*flexItem, error) {
+	var items []*flexItem
+	for _, itm := range te.Items {
+		t, ok := itm.(*Text)
+		if !ok {
+			continue
+		}
+		hv := SettingsToValues(t.Settings)
+		it := &flexItem{
+			te:     t,
+			extra:  hv.MarginLeft + hv.MarginRight + hv.BorderLeftWidth + hv.BorderRightWidth + hv.PaddingLeft + hv.PaddingRight,
+			max:    bag.MaxSP,
+			shrink: 1,
+			align:  fc.AlignItems,
+		}
+		fi, hasFlex := t.Settings[SettingFlexItem].(FlexItem)
+		if hasFlex {
+			it.grow, it.shrink = fi.Grow, fi.Shrink
+			if fi.AlignSelf != FlexAlignAuto {
+				it.align = fi.AlignSelf
+			}
+		}
+		if it.align == FlexAlignAuto {
+			it.align = FlexAlignStretch
+		}
+		var maxWd bag.ScaledPoint
+		var err error
+		if it.min, maxWd, err = fe.IntrinsicWidths(t); err != nil {
+			return nil, err
+		}
+		if basis, ok := offset(fi.Basis, hsize); hasFlex && ok {
+			it.basis = basis
+		} else if wd, ok := offset(t.Settings[SettingWidth], hsize); ok {
+			it.basis = wd
+		} else {
+			it.basis = maxWd
+		}
+		it.basis = bag.Max(it.basis, 0)
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// flexLines breaks the items into lines which are not wider than hsize. Without
+// wrap all items are in one line.
+func flexLines(items []*flexItem, hsize, gap bag.ScaledPoint, wrap bool) [][]*flexItem {
+	if !wrap {
+		return [][]*flexItem{items}
+	}
+	var lines [][]*flexItem
+	var line []*flexItem
+	var wd bag.ScaledPoint
+	for _, it := range items {
+		itemWidth := it.basis + it.extra
+		if len(line) > 0 && wd+gap+itemWidth > hsize {
+			lines = append(lines, line)
+			line, wd = nil, 0
+		}
+		if len(line) > 0 {
+			wd += gap
+		}
+		line = append(line, it)
+		wd += itemWidth
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// resolveFlexibleWidths sets the widths of the items in the line, so that the
+// line fills hsize. The items grow or shrink according to their factors
+// within their minimum and maximum widths. An item which reaches a limit is
+// frozen and the remaining free space is distributed among the other items
+// (CSS "resolve flexible lengths").
+func resolveFlexibleWidths(line []*flexItem, hsize, gap bag.ScaledPoint) {
+	available := hsize - gap*bag.ScaledPoint(len(line)-1)
+	free := available
+	for _, it := range line {
+		free -= bag.Max(it.min, bag.Min(it.basis, it.max)) + it.extra
+	}
+	grow := free > 0
+	frozen := make(map[*flexItem]bool, len(line))
+	for _, it := range line {
+		it.size = bag.Max(it.min, bag.Min(it.basis, it.max))
+		if grow && (it.grow == 0 || it.basis > it.size) || !grow && (it.shrink == 0 || it.basis < it.size) {
+			frozen[it] = true
+		}
+	}
+	for len(frozen) < len(line) {
+		free = available
+		var sumGrow, sumShrink float64
+		for _, it := range line {
+			if frozen[it] {
+				free -= it.size + it.extra
+			} else {
+				free -= it.basis + it.extra
+				sumGrow += it.grow
+				sumShrink += it.shrink * it.basis.ToPT()
+			}
+		}
+		var violation bag.ScaledPoint
+		for _, it := range line {
+			if frozen[it] {
+				continue
+			}
+			size := it.basis
+			if grow && sumGrow > 0 {
+				size += bag.MultiplyFloat(free, it.grow/sumGrow)
+			} else if !grow && sumShrink > 0 {
+				size += bag.MultiplyFloat(free, it.shrink*it.basis.ToPT()/sumShrink)
+			}
+			it.size = bag.Max(it.min, bag.Min(size, it.max))
+			violation += it.size - size
+		}
+		// freeze all items on a total violation of zero, otherwise only the
+		// items at the limit which the total violation points to
+		for _, it := range line {
+			if frozen[it] {
+				continue
+			}
+			switch {
+			case violation == 0,
+				violation > 0 && it.size == it.min,
+				violation < 0 && it.size == it.max:
+				frozen[it] = true
+			}
+		}
+	}
+}
+
+// justifyFlexLine returns the space before the first item and the space
+// between two items.
+func justifyFlexLine(justify FlexJustify, free, gap bag.ScaledPoint, n int) (bag.ScaledPoint, bag.ScaledPoint) {
+	free = bag.Max(free, 0)
+	count := bag.ScaledPoint(n)
+	switch justify {
+	case FlexJustifyEnd:
+		return free, gap
+	case FlexJustifyCenter:
+		return free / 2, gap
+	case FlexJustifySpaceBetween:
+		if n > 1 {
+			return 0, gap + free/(count-1)
+		}
+	case FlexJustifySpaceAround:
+		return free / (2 * count), gap + free/count
+	case FlexJustifySpaceEvenly:
+		return free / (count + 1), gap + free/(count+1)
+	}
+	return 0, gap
+}
+
+// crossOffset returns the distance of an item with the given size from the
+// start of the cross axis.
+func crossOffset(align FlexAlign, size, lineSize bag.ScaledPoint) bag.ScaledPoint {
+	switch align {
+	case FlexAlignEnd:
+		return lineSize - size
+	case FlexAlignCenter:
+		return (lineSize - size) / 2
+	}
+	return 0
+}
+
+// itemBox is a formatted flex or grid item.
+type itemBox struct {
+	info   *VlistInfo
+	height bag.ScaledPoint
+}
+
+// buildItemBox formats the item te with the width of the margin box at the
+// horizontal position x and returns the box and the height of its margin box.
+func (fe *Document) buildItemBox(te *Text, width, x, top bag.ScaledPoint) (itemBox, error) {
+	hv := SettingsToValues(te.Settings)
+	info, err := fe.buildVlist(te, width, x, top+hv.MarginTop+hv.PaddingTop+hv.BorderTopWidth, nil)
+	if err != nil {
+		return itemBox{}, err
+	}
+	height := info.marginTop + info.height + info.marginBottom
+	height += info.hv.PaddingTop + info.hv.PaddingBottom + info.hv.BorderTopWidth + info.hv.BorderBottomWidth
+	return itemBox{info: info, height: height}, nil
+}
+
+// stretch makes the box as high as the line.
+func (fb *itemBox) stretch(lineHeight bag.ScaledPoint) {
+	fb.info.height += lineHeight - fb.height
+	fb.height = lineHeight
+}
+
+// buildFlex arranges the items of the flex container te (a box) in the content
+// box at x with the width hsize. It fills the page box and the height of ret.
+func (fe *Document) buildFlex(te *Text, fc FlexContainer, ret *VlistInfo, hsize, x, shiftDown bag.ScaledPoint) error {
+	items, err := fe.newFlexItems(te, fc, hsize)
+	if err != nil {
+		return err
+	}
+	reverse := fc.Direction == FlexDirectionRowReverse || fc.Direction == FlexDirectionColumnReverse
+	if fc.Direction == FlexDirectionColumn || fc.Direction == FlexDirectionColumnReverse {
+		if reverse {
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+		}
+		return fe.buildFlexColumn(items, fc, ret, hsize, x, shiftDown)
+	}
+	justify := fc.Justify
+	if reverse {
+		switch justify {
+		case FlexJustifyStart:
+			justify = FlexJustifyEnd
+		case FlexJustifyEnd:
+			justify = FlexJustifyStart
+		}
+	}
+	var height bag.ScaledPoint
+	lines := flexLines(items, hsize, fc.ColumnGap, fc.Wrap)
+	for i, line := range lines {
+		if reverse {
+			for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+				line[i], line[j] = line[j], line[i]
+			}
+		}
+		resolveFlexibleWidths(line, hsize, fc.ColumnGap)
+		free := hsize - fc.ColumnGap*bag.ScaledPoint(len(line)-1)
+		for _, it := range line {
+			free -= it.outerWidth()
+		}
+		start, between := justifyFlexLine(justify, free, fc.ColumnGap, len(line))
+
+		boxes := make([]itemBox, len(line))
+		var lineHeight bag.ScaledPoint
+		for j, it := range line {
+			if boxes[j], err = fe.buildItemBox(it.te, it.outerWidth(), 0, shiftDown+height); err != nil {
+				return err
+			}
+			ret.positioned = append(ret.positioned, boxes[j].info.positioned...)
+			lineHeight = bag.Max(lineHeight, boxes[j].height)
+		}
+		var head, cur node.Node
+		for j, it := range line {
+			k := node.NewKern()
+			k.Kern = between
+			if j == 0 {
+				k.Kern = start
+			}
+			head = node.InsertAfter(head, cur, k)
+			cur = k
+
+			if it.align == FlexAlignStretch {
+				boxes[j].stretch(lineHeight)
+			}
+			vl := fe.boxToVList(boxes[j].info)
+			top := node.NewKern()
+			top.Kern = crossOffset(it.align, boxes[j].height, lineHeight)
+			node.InsertAfter(top, top, vl)
+			wrap := node.Vpack(top)
+			wrap.Width = it.outerWidth()
+			// the items are aligned at the top of the line
+			wrap.Height = lineHeight
+			wrap.Depth = 0
+			wrap.Attributes = node.H{"origin": "flex item"}
+			head = node.InsertAfter(head, cur, wrap)
+			cur = wrap
+		}
+		hl := node.Hpack(head)
+		hl.Attributes = node.H{"origin": "flex line"}
+		if i < len(lines)-1 && fc.RowGap != 0 {
+			g := node.NewGlue()
+			g.Width = fc.RowGap
+			node.InsertAfter(hl, hl, g)
+		}
+		lineVL := node.Vpack(hl)
+		lineVL.Attributes = node.H{"height": lineVL.Height + lineVL.Depth, "x": x, "origin": "flex line"}
+		ret.Pagebox = append(ret.Pagebox, lineVL)
+		height += lineVL.Height + lineVL.Depth
+	}
+	ret.height = height
+	return nil
+}
+
+// buildFlexColumn places the items of a flex container with a vertical main
+// axis below each other.
+func (fe *Document) buildFlexColumn(items []*flexItem, fc FlexContainer, ret *VlistInfo, hsize, x, shiftDown bag.ScaledPoint) error {
+	var height bag.ScaledPoint
+	for i, it := range items {
+		_, hasWidth := offset(it.te.Settings[SettingWidth], hsize)
+		if it.align == FlexAlignStretch && !hasWidth {
+			it.size = hsize - it.extra
+		} else {
+			it.size = bag.Min(it.basis, hsize-it.extra)
+		}
+		fb, err := fe.buildItemBox(it.te, it.outerWidth(), x+crossOffset(it.align, it.outerWidth(), hsize), shiftDown+height)
+		if err != nil {
+			return err
+		}
+		ret.positioned = append(ret.positioned, fb.info.positioned...)
+		vl := fe.boxToVList(fb.info)
+		skip := fb.info.marginBottom
+		if i < len(items)-1 {
+			skip += fc.RowGap
+		}
+		if skip != 0 {
+			g := node.NewGlue()
+			g.Width = skip
+			node.InsertAfter(vl.List, node.Tail(vl.List), g)
+			vl = node.Vpack(vl.List)
+		}
+		vl.Attributes = node.H{"height": vl.Height + vl.Depth, "x": bag.ScaledPoint(0), "origin": "flex item"}
+		ret.Pagebox = append(ret.Pagebox, vl)
+		height += vl.Height + vl.Depth
+	}
+	ret.height = height
+	return nil
+}
@@ -0,0 +1,76 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+func TestFlexLines(t *testing.T) {
+	items := []*flexItem{
+		{basis: 40 * bag.Factor},
+		{basis: 40 * bag.Factor},
+		{basis: 40 * bag.Factor},
+	}
+	if got := len(flexLines(items, 100*bag.Factor, 10*bag.Factor, false)); got != 1 {
+		t.Errorf("len(lines) without wrap = %d, want 1", got)
+	}
+	lines := flexLines(items, 100*bag.Factor, 10*bag.Factor, true)
+	if len(lines) != 2 || len(lines[0]) != 2 || len(lines[1]) != 1 {
+		t.Errorf("lines with wrap = %v, want 2 and 1 items", lines)
+	}
+}
+
+func TestResolveFlexibleWidths(t *testing.T) {
+	// grow 1 and 3 with 40pt free space
+	line := []*flexItem{
+		{basis: 20 * bag.Factor, max: bag.MaxSP, grow: 1, shrink: 1},
+		{basis: 30 * bag.Factor, max: bag.MaxSP, grow: 3, shrink: 1},
+	}
+	resolveFlexibleWidths(line, 100*bag.Factor, 10*bag.Factor)
+	if line[0].size != 30*bag.Factor || line[1].size != 60*bag.Factor {
+		t.Errorf("grow: sizes = %s, %s, want 30pt, 60pt", line[0].size, line[1].size)
+	}
+
+	// the first item stops at its maximum width, the second item gets the
+	// rest of the free space
+	line = []*flexItem{
+		{basis: 20 * bag.Factor, max: 25 * bag.Factor, grow: 1, shrink: 1},
+		{basis: 30 * bag.Factor, max: bag.MaxSP, grow: 3, shrink: 1},
+	}
+	resolveFlexibleWidths(line, 100*bag.Factor, 10*bag.Factor)
+	if line[0].size != 25*bag.Factor || line[1].size != 65*bag.Factor {
+		t.Errorf("grow with max: sizes = %s, %s, want 25pt, 65pt", line[0].size, line[1].size)
+	}
+
+	// shrink by 30pt, weighted by the basis, the first item stops at its
+	// minimum width and the second item shrinks by the rest
+	line = []*flexItem{
+		{basis: 40 * bag.Factor, max: bag.MaxSP, shrink: 1, min: 35 * bag.Factor},
+		{basis: 80 * bag.Factor, max: bag.MaxSP, shrink: 1},
+	}
+	resolveFlexibleWidths(line, 90*bag.Factor, 0)
+	if line[0].size != 35*bag.Factor || line[1].size != 55*bag.Factor {
+		t.Errorf("shrink: sizes = %s, %s, want 35pt, 55pt", line[0].size, line[1].size)
+	}
+}
+
+func TestJustifyFlexLine(t *testing.T) {
+	testdata := []struct {
+		justify       FlexJustify
+		before, space bag.ScaledPoint
+	}{
+		{FlexJustifyStart, 0, 2 * bag.Factor},
+		{FlexJustifyEnd, 60 * bag.Factor, 2 * bag.Factor},
+		{FlexJustifyCenter, 30 * bag.Factor, 2 * bag.Factor},
+		{FlexJustifySpaceBetween, 0, 32 * bag.Factor},
+		{FlexJustifySpaceAround, 10 * bag.Factor, 22 * bag.Factor},
+		{FlexJustifySpaceEvenly, 15 * bag.Factor, 17 * bag.Factor},
+	}
+	for _, td := range testdata {
+		before, space := justifyFlexLine(td.justify, 60*bag.Factor, 2*bag.Factor, 3)
+		if before != td.before || space != td.space {
+			t.Errorf("justifyFlexLine(%d) = %s, %s, want %s, %s", td.justify, before, space, td.before, td.space)
+		}
+	}
+}
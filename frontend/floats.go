@@ -0,0 +1,235 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// Float is the value of SettingFloat.
+type Float uint
+
+const (
+	// FloatNone is a box in the normal flow.
+	FloatNone Float = iota
+	// FloatLeft places the box at the left edge of the surrounding box.
+	FloatLeft
+	// FloatRight places the box at the right edge of the surrounding box.
+	FloatRight
+)
+
+// Clear is the value of SettingClear.
+type Clear uint
+
+const (
+	// ClearNone places the box next to the floating boxes.
+	ClearNone Clear = iota
+	// ClearLeft moves the box below the boxes floating on the left side.
+	ClearLeft
+	// ClearRight moves the box below the boxes floating on the right side.
+	ClearRight
+	// ClearBoth moves the box below all floating boxes.
+	ClearBoth
+)
+
+// An ExclusionFunc returns the space on the left and on the right side which
+// is not available for the lines between top and bottom. The positions are
+// measured from the top of the paragraph.
+type ExclusionFunc func(top, bottom bag.ScaledPoint) (left, right bag.ScaledPoint)
+
+// paragraphShape returns the paragraph shape for the line breaking. Each line
+// is assumed to be lineHeight high.
+func (f ExclusionFunc) paragraphShape(lineHeight bag.ScaledPoint) node.ParagraphShapeFunc {
+	return func(row int) (bag.ScaledPoint, bag.ScaledPoint) {
+		top := lineHeight * bag.ScaledPoint(row)
+		return f(top, top+lineHeight)
+	}
+}
+
+// floatBox is the margin box of a floating box. The vertical positions are
+// measured from the top of the frame.
+type floatBox struct {
+	side   Float
+	top    bag.ScaledPoint
+	bottom bag.ScaledPoint
+	left   bag.ScaledPoint
+	right  bag.ScaledPoint
+}
+
+// floatList contains the floating boxes which affect the following boxes.
+type floatList []floatBox
+
+// below returns the floating boxes which reach below the vertical position y.
+func (fl floatList) below(y bag.ScaledPoint) floatList {
+	var ret floatList
+	for _, f := range fl {
+		if f.bottom > y {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// clearance returns the bottom of the lowest floating box which is cleared
+// by c or 0 if there is none.
+func (fl floatList) clearance(c Clear) bag.ScaledPoint {
+	var y bag.ScaledPoint
+	for _, f := range fl {
+		if c == ClearBoth || c == ClearLeft && f.side == FloatLeft || c == ClearRight && f.side == FloatRight {
+			y = bag.Max(y, f.bottom)
+		}
+	}
+	return y
+}
+
+// place returns the position of a new floating box of the given width and
+// height at the vertical position top or below. The box is placed next to the
+// floating boxes on the same side. If there is not enough space beside the
+// floating boxes for the whole height of the box, the box moves down until it
+// fits. x and hsize are the position and the width of the surrounding content
+// box.
+func (fl floatList) place(side Float, width, height, top, x, hsize bag.ScaledPoint) (bag.ScaledPoint, bag.ScaledPoint) {
+	for {
+		left, right := x, x+hsize
+		// next is the top of the next free space below top
+		next := bag.ScaledPoint(-1)
+		for _, f := range fl {
+			if f.bottom <= top || f.top > top && f.top >= top+height {
+				continue
+			}
+			if f.side == FloatLeft {
+				left = bag.Max(left, f.right)
+			} else {
+				right = bag.Min(right, f.left)
+			}
+			if next < 0 || f.bottom < next {
+				next = f.bottom
+			}
+		}
+		if right-left >= width || next < 0 {
+			if side == FloatLeft {
+				return left, top
+			}
+			return right - width, top
+		}
+		top = next
+	}
+}
+
+// exclusions returns the space taken by the floating boxes beside a paragraph
+// with the content box at x (width hsize) and top (measured from the top of
+// the frame). It returns nil if there are no floating boxes.
+func (fl floatList) exclusions(x, hsize, top bag.ScaledPoint) ExclusionFunc {
+	fl = fl.below(top)
+	if len(fl) == 0 {
+		return nil
+	}
+	return func(t, b bag.ScaledPoint) (bag.ScaledPoint, bag.ScaledPoint) {
+		var left, right bag.ScaledPoint
+		for _, f := range fl {
+			if f.top >= top+b || f.bottom <= top+t {
+				continue
+			}
+			if f.side == FloatLeft {
+				left = bag.Max(left, f.right-x)
+			} else {
+				right = bag.Max(right, x+hsize-f.left)
+			}
+		}
+		return left, right
+	}
+}
+
+// outOfFlowWidth returns the width of the contents of te which is placed
+// outside of the normal flow (a floating or an absolutely positioned box).
+// Without a width setting, the box gets the width of its contents but not more
+// than the available width.
+func (fe *Document) outOfFlowWidth(te *Text, hsize bag.ScaledPoint) (bag.ScaledPoint, error) {
+	hv := SettingsToValues(te.Settings)
+	avail := hsize - hv.MarginLeft - hv.MarginRight - hv.BorderLeftWidth - hv.BorderRightWidth - hv.PaddingLeft - hv.PaddingRight
+	if sWd, ok := te.Settings[SettingWidth]; ok {
+		wd, err := parseInlineBlockWidth(sWd, avail)
+		if err != nil || wd > 0 {
+			return wd, err
+		}
+	}
+	vl, err := fe.CreateVlist(contentsText(te), bag.MaxSP)
+	if err != nil {
+		return 0, err
+	}
+	return bag.Min(maxContentWidth(vl), avail), nil
+}
+
+// buildFloat formats the floating box te at the vertical position y (or below
+// if it does not fit beside the other floating boxes) in the content box at x
+// with the width hsize. It returns the margin box of the floating box and a
+// vertical list at y which does not take vertical space.
+func (fe *Document) buildFloat(te *Text, side Float, hsize, x, y bag.ScaledPoint, floats floatList) (floatBox, *node.VList, error) {
+	wd, err := fe.outOfFlowWidth(te, hsize)
+	if err != nil {
+		return floatBox{}, nil, err
+	}
+	hv := SettingsToValues(te.Settings)
+	width := wd + hv.MarginLeft + hv.MarginRight + hv.BorderLeftWidth + hv.BorderRightWidth + hv.PaddingLeft + hv.PaddingRight
+	// The box is formatted at the edge of the content box first to get its
+	// height for the placement. It is formatted again if it moves.
+	left := x
+	if side == FloatRight {
+		left = x + hsize - width
+	}
+	info, err := fe.buildVlist(te, width, left, y+hv.MarginTop+hv.PaddingTop+hv.BorderTopWidth, nil)
+	if err != nil {
+		return floatBox{}, nil, err
+	}
+	vl := fe.boxToVList(info)
+	newLeft, top := floats.place(side, width, vl.Height+vl.Depth+info.marginBottom, y, x, hsize)
+	if newLeft != left || top != y {
+		left = newLeft
+		if info, err = fe.buildVlist(te, width, left, top+hv.MarginTop+hv.PaddingTop+hv.BorderTopWidth, nil); err != nil {
+			return floatBox{}, nil, err
+		}
+		vl = fe.boxToVList(info)
+	}
+	fb := floatBox{
+		side:   side,
+		top:    top,
+		bottom: top + vl.Height + vl.Depth + info.marginBottom,
+		left:   left,
+		right:  left + width,
+	}
+	if top > y {
+		g := node.NewGlue()
+		g.Width = top - y
+		node.InsertAfter(g, g, vl)
+		vl = node.Vpack(g)
+	}
+	// the floating box does not move the following material down, the page
+	// breaking gets the height of the box in floatHeight.
+	vl.Height = 0
+	vl.Depth = 0
+	vl.Attributes = node.H{"height": bag.ScaledPoint(0), "floatHeight": fb.bottom - y, "x": bag.ScaledPoint(0), "origin": "float"}
+	return fb, vl, nil
+}
+
+// boxToVList returns the box described by info (including the margins,
+// the border and the padding) as a single vertical list.
+func (fe *Document) boxToVList(info *VlistInfo) *node.VList {
+	start := node.NewStartStop()
+	start.Attributes = node.H{
+		"shiftDown": info.marginTop,
+		"hv":        info.hv,
+		"height":    info.height,
+		"hsize":     info.hsize,
+		"x":         info.x,
+	}
+	fragment := []node.Node{start}
+	if info.vl == nil {
+		fragment = append(fragment, info.Pagebox...)
+	} else {
+		fragment = append(fragment, info.vl)
+	}
+	stop := node.NewStartStop()
+	stop.Attributes = node.H{"hv": info.hv}
+	stop.StartNode = start
+	fragment = append(fragment, stop)
+	return fe.pageboxToVList(fragment)
+}
@@ -0,0 +1,70 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+func TestFloatListExclusions(t *testing.T) {
+	// a content box from 10pt to 110pt with a left float (30pt wide, 0pt -
+	// 40pt) and a right float (20pt wide, 20pt - 60pt)
+	fl := floatList{}
+	fl = append(fl, floatBox{side: FloatLeft, top: 0, bottom: 40 * bag.Factor, left: 10 * bag.Factor, right: 40 * bag.Factor})
+	if got, top := fl.place(FloatRight, 20*bag.Factor, 10*bag.Factor, 20*bag.Factor, 10*bag.Factor, 100*bag.Factor); got != 90*bag.Factor || top != 20*bag.Factor {
+		t.Errorf("place(right) = %s, %s, want 90pt, 20pt", got, top)
+	}
+	if got, top := fl.place(FloatLeft, 20*bag.Factor, 10*bag.Factor, 20*bag.Factor, 10*bag.Factor, 100*bag.Factor); got != 40*bag.Factor || top != 20*bag.Factor {
+		t.Errorf("place(left) = %s, %s, want 40pt, 20pt", got, top)
+	}
+	// a box which does not fit beside the left float moves below it
+	if got, top := fl.place(FloatLeft, 80*bag.Factor, 10*bag.Factor, 20*bag.Factor, 10*bag.Factor, 100*bag.Factor); got != 10*bag.Factor || top != 40*bag.Factor {
+		t.Errorf("place(left, 80pt) = %s, %s, want 10pt, 40pt", got, top)
+	}
+	fl = append(fl, floatBox{side: FloatRight, top: 20 * bag.Factor, bottom: 60 * bag.Factor, left: 90 * bag.Factor, right: 110 * bag.Factor})
+	// between the floats there is space for 50pt until 40pt and then for
+	// 80pt until 60pt
+	if got, top := fl.place(FloatRight, 60*bag.Factor, 10*bag.Factor, 20*bag.Factor, 10*bag.Factor, 100*bag.Factor); got != 30*bag.Factor || top != 40*bag.Factor {
+		t.Errorf("place(right, 60pt) = %s, %s, want 30pt, 40pt", got, top)
+	}
+	if got, top := fl.place(FloatLeft, 90*bag.Factor, 10*bag.Factor, 20*bag.Factor, 10*bag.Factor, 100*bag.Factor); got != 10*bag.Factor || top != 60*bag.Factor {
+		t.Errorf("place(left, 90pt) = %s, %s, want 10pt, 60pt", got, top)
+	}
+	// a 40pt high box at 0pt does not fit beside the right float starting
+	// at 20pt
+	if got, top := fl.place(FloatLeft, 60*bag.Factor, 40*bag.Factor, 0, 10*bag.Factor, 100*bag.Factor); got != 10*bag.Factor || top != 40*bag.Factor {
+		t.Errorf("place(left, 60pt, 40pt high) = %s, %s, want 10pt, 40pt", got, top)
+	}
+	// a box which is higher than the space above a lower float on the same
+	// side moves below it
+	lower := floatList{{side: FloatLeft, top: 30 * bag.Factor, bottom: 50 * bag.Factor, left: 10 * bag.Factor, right: 40 * bag.Factor}}
+	if got, top := lower.place(FloatLeft, 80*bag.Factor, 40*bag.Factor, 0, 10*bag.Factor, 100*bag.Factor); got != 10*bag.Factor || top != 50*bag.Factor {
+		t.Errorf("place(left, 80pt, 40pt high) = %s, %s, want 10pt, 50pt", got, top)
+	}
+
+	// a paragraph which starts 10pt below the top
+	excl := fl.exclusions(10*bag.Factor, 100*bag.Factor, 10*bag.Factor)
+	testdata := []struct {
+		top, bottom, left, right bag.ScaledPoint
+	}{
+		{0, 10 * bag.Factor, 30 * bag.Factor, 0},
+		{10 * bag.Factor, 20 * bag.Factor, 30 * bag.Factor, 20 * bag.Factor},
+		{30 * bag.Factor, 40 * bag.Factor, 0, 20 * bag.Factor},
+		{50 * bag.Factor, 60 * bag.Factor, 0, 0},
+	}
+	for _, td := range testdata {
+		left, right := excl(td.top, td.bottom)
+		if left != td.left || right != td.right {
+			t.Errorf("exclusions(%s, %s) = %s, %s, want %s, %s", td.top, td.bottom, left, right, td.left, td.right)
+		}
+	}
+	if got, want := fl.clearance(ClearLeft), 40*bag.Factor; got != want {
+		t.Errorf("clearance(left) = %s, want %s", got, want)
+	}
+	if got, want := fl.clearance(ClearBoth), 60*bag.Factor; got != want {
+		t.Errorf("clearance(both) = %s, want %s", got, want)
+	}
+	if excl := fl.exclusions(10*bag.Factor, 100*bag.Factor, 60*bag.Factor); excl != nil {
+		t.Error("exclusions below the floats, want nil")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	pdf "github.com/speedata/baseline-pdf"
 	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
 )
 
 var (
@@ -82,6 +83,12 @@ type FontSource struct {
 	Location     string
 	Data         []byte
 	SizeAdjust   float64 // 1 - SizeAdjust is the relative adjustment.
+	// Protrusion is the protrusion table used for margin protrusion
+	// (SettingMarginProtrusion).
+	Protrusion map[rune]font.Protrusion
+	// Expansion contains the font expansion parameters. Without them all
+	// glyphs are expanded by the amount of SettingFontExpansion.
+	Expansion *font.Expansion
 	// The sub font index within the font file.
 	Index int
 	// Used to save a face once it is loaded.
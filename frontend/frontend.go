@@ -25,6 +25,7 @@ type Document struct {
 	usedFonts             map[*pdf.Face]map[bag.ScaledPoint]*font.Font
 	dirstack              []string
 	postLinebreakCallback []PostLinebreakCallbackFunc
+	pageAreaCallback      PageAreaCallbackFunc
 }
 
 func initDocument() *Document {
@@ -96,8 +97,18 @@ type HangingPunctuation uint8
 
 const (
 	// HangingPunctuationAllowEnd allows hanging punctuation at the end of a
-	// line.
-	HangingPunctuationAllowEnd = 1
+	// line. Since the line breaking always takes the hanging punctuation into
+	// account, this is the same as HangingPunctuationForceEnd.
+	HangingPunctuationAllowEnd HangingPunctuation = 1 << iota
+	// HangingPunctuationFirst lets an opening bracket or quote at the start
+	// of the first line hang.
+	HangingPunctuationFirst
+	// HangingPunctuationLast lets a closing bracket or quote at the end of
+	// the last line hang.
+	HangingPunctuationLast
+	// HangingPunctuationForceEnd lets stops and commas at the end of each
+	// line hang.
+	HangingPunctuationForceEnd
 )
 
 // HorizontalAlignment is the horizontal alignment.
@@ -144,4 +155,13 @@ const (
 	VAlignMiddle
 	// VAlignBottom aligns the contents at the bottom of the surrounding box.
 	VAlignBottom
+	// VAlignBaseline aligns the baseline of an inline object with the
+	// baseline of the line.
+	VAlignBaseline
+	// VAlignTextTop aligns the top of an inline object with the top of the
+	// font.
+	VAlignTextTop
+	// VAlignTextBottom aligns the bottom of an inline object with the bottom
+	// of the font.
+	VAlignTextBottom
 )
@@ -0,0 +1,445 @@
+package frontend
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// GridFraction is a flexible track size (the fr unit). The flexible tracks
+// share the space which is not used by the other tracks.
+type GridFraction float64
+
+// GridTrack is the size of a column or a row of a grid. Min and Max are
+// "auto", "min-content", "max-content", a bag.ScaledPoint or a percentage
+// string of the container width. Max can also be a GridFraction. A track with
+// a fixed size has the same Min and Max.
+type GridTrack struct {
+	Min any
+	Max any
+}
+
+// GridContainer is the value of SettingGrid. The tracks which are not in
+// Columns and Rows (the implicit grid) have the size auto.
+type GridContainer struct {
+	Columns   []GridTrack
+	Rows      []GridTrack
+	RowGap    bag.ScaledPoint
+	ColumnGap bag.ScaledPoint
+}
+
+// GridLine is the placement of a grid item on one axis. Start and End are line
+// numbers starting with 1, negative numbers count from the end of the explicit
+// grid and 0 is auto. Span is the number of tracks of the item if Start or End
+// is auto.
+type GridLine struct {
+	Start int
+	End   int
+	Span  int
+}
+
+// GridItem is the value of SettingGridItem. Items without this setting are
+// placed automatically.
+type GridItem struct {
+	Column GridLine
+	Row    GridLine
+}
+
+// resolve returns the first track (starting with 0) and the number of tracks
+// of the placement in a grid with n explicit tracks. definite is false if the
+// position is found by the auto placement.
+func (gl GridLine) resolve(n int) (start, span int, definite bool) {
+	line := func(l int) int {
+		if l < 0 {
+			l = n + 2 + l
+		}
+		if l < 1 {
+			l = 1
+		}
+		return l
+	}
+	span = gl.Span
+	if span < 1 {
+		span = 1
+	}
+	switch {
+	case gl.Start != 0 && gl.End != 0:
+		s, e := line(gl.Start), line(gl.End)
+		if e < s {
+			s, e = e, s
+		}
+		if e == s {
+			e = s + 1
+		}
+		return s - 1, e - s, true
+	case gl.Start != 0:
+		return line(gl.Start) - 1, span, true
+	case gl.End != 0:
+		s := line(gl.End) - span
+		if s < 1 {
+			s = 1
+		}
+		return s - 1, span, true
+	}
+	return 0, span, false
+}
+
+// gridItem is an item of a grid container during the layout. The widths are
+// the widths of the margin box.
+type gridItem struct {
+	te      *Text
+	pos     GridItem
+	minWd   bag.ScaledPoint
+	maxWd   bag.ScaledPoint
+	col     int
+	colSpan int
+	row     int
+	rowSpan int
+	box     itemBox
+}
+
+// gridTrack returns the track i of the explicit grid or an auto track.
+func gridTrack(tracks []GridTrack, i int) GridTrack {
+	if i < len(tracks) {
+		return tracks[i]
+	}
+	return GridTrack{Min: "auto", Max: "auto"}
+}
+
+// trackLength returns the length of the track size v and true if it is a
+// length or a percentage of base. Percentages of the base 0 are not lengths.
+func trackLength(v any, base bag.ScaledPoint) (bag.ScaledPoint, bool) {
+	switch t := v.(type) {
+	case bag.ScaledPoint:
+		return t, true
+	case string:
+		if strings.HasSuffix(t, "%") && base > 0 {
+			return offset(t, base)
+		}
+	}
+	return 0, false
+}
+
+// placeGridItems sets the position of the items in a grid with nCols explicit
+// columns and nRows explicit rows. The items without a definite position are
+// placed row by row into the next free cells. It returns the number of columns
+// and rows of the grid including the implicit tracks.
+func placeGridItems(items []*gridItem, nCols, nRows int) (int, int) {
+	explicitCols, explicitRows := nCols, nRows
+	if nCols < 1 {
+		nCols = 1
+	}
+	occupied := map[[2]int]bool{}
+	fits := func(row, col, rowSpan, colSpan int) bool {
+		for r := row; r < row+rowSpan; r++ {
+			for c := col; c < col+colSpan; c++ {
+				if occupied[[2]int{r, c}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	var curRow, curCol int
+	for _, it := range items {
+		col, colSpan, colDefinite := it.pos.Column.resolve(explicitCols)
+		row, rowSpan, rowDefinite := it.pos.Row.resolve(explicitRows)
+		if !colDefinite && colSpan > nCols {
+			colSpan = nCols
+		}
+		switch {
+		case colDefinite && rowDefinite:
+			// nothing to do
+		case rowDefinite:
+			for col = 0; !fits(row, col, rowSpan, colSpan); col++ {
+			}
+		case colDefinite:
+			for row = curRow; !fits(row, col, rowSpan, colSpan); row++ {
+			}
+		default:
+			row, col = curRow, curCol
+			for col+colSpan > nCols || !fits(row, col, rowSpan, colSpan) {
+				col++
+				if col+colSpan > nCols {
+					row++
+					col = 0
+				}
+			}
+			curRow, curCol = row, col+colSpan
+		}
+		for r := row; r < row+rowSpan; r++ {
+			for c := col; c < col+colSpan; c++ {
+				occupied[[2]int{r, c}] = true
+			}
+		}
+		it.col, it.colSpan, it.row, it.rowSpan = col, colSpan, row, rowSpan
+		if col+colSpan > nCols {
+			nCols = col + colSpan
+		}
+		if row+rowSpan > nRows {
+			nRows = row + rowSpan
+		}
+	}
+	return nCols, nRows
+}
+
+// gridColumnWidths returns the widths of the n columns of a grid in the content
+// box with the width hsize. The fixed tracks get their size, the auto tracks
+// are sized like the columns of a table and the flexible tracks share the
+// remaining space.
+func gridColumnWidths(tracks []GridTrack, n int, items []*gridItem, hsize, gap bag.ScaledPoint) []bag.ScaledPoint {
+	colmin := make([]bag.ScaledPoint, n)
+	colmax := make([]bag.ScaledPoint, n)
+	fixedMin := make([]bool, n)
+	fixedMax := make([]bool, n)
+	fr := make([]float64, n)
+	for i := 0; i < n; i++ {
+		t := gridTrack(tracks, i)
+		colmin[i], fixedMin[i] = trackLength(t.Min, hsize)
+		colmax[i], fixedMax[i] = trackLength(t.Max, hsize)
+		if f, ok := t.Max.(GridFraction); ok {
+			fr[i] = float64(f)
+		}
+	}
+	intrinsic := func(i int) bool {
+		return !fixedMax[i] && fr[i] == 0
+	}
+	var minSpans, maxSpans []span
+	for _, it := range items {
+		if it.colSpan == 1 {
+			t := gridTrack(tracks, it.col)
+			if !fixedMin[it.col] {
+				contribution := it.minWd
+				if t.Min == "max-content" {
+					contribution = it.maxWd
+				}
+				colmin[it.col] = bag.Max(colmin[it.col], contribution)
+			}
+			if intrinsic(it.col) {
+				contribution := it.maxWd
+				if t.Max == "min-content" {
+					contribution = it.minWd
+				}
+				colmax[it.col] = bag.Max(colmax[it.col], contribution)
+			}
+			continue
+		}
+		// items spanning several tracks only enlarge auto tracks
+		spanIntrinsic := true
+		for i := it.col; i < it.col+it.colSpan; i++ {
+			spanIntrinsic = spanIntrinsic && intrinsic(i)
+		}
+		if spanIntrinsic {
+			gaps := gap * bag.ScaledPoint(it.colSpan-1)
+			minSpans = append(minSpans, span{start: it.col, end: it.col + it.colSpan - 1, size: it.minWd - gaps})
+			maxSpans = append(maxSpans, span{start: it.col, end: it.col + it.colSpan - 1, size: it.maxWd - gaps})
+		}
+	}
+	distributeSpans(colmin, minSpans)
+	distributeSpans(colmax, maxSpans)
+
+	widths := make([]bag.ScaledPoint, n)
+	free := hsize - gap*bag.ScaledPoint(n-1)
+	var sumFr float64
+	var autoCols []int
+	for i := range widths {
+		colmax[i] = bag.Max(colmax[i], colmin[i])
+		switch {
+		case fr[i] > 0:
+			sumFr += fr[i]
+			widths[i] = colmin[i]
+		case fixedMax[i]:
+			widths[i] = colmax[i]
+		default:
+			autoCols = append(autoCols, i)
+			continue
+		}
+		free -= widths[i]
+	}
+	if len(autoCols) > 0 {
+		amin := make([]bag.ScaledPoint, len(autoCols))
+		amax := make([]bag.ScaledPoint, len(autoCols))
+		var sumMax bag.ScaledPoint
+		for j, i := range autoCols {
+			amin[j], amax[j] = colmin[i], colmax[i]
+			sumMax += colmax[i]
+		}
+		avail := bag.Max(free, 0)
+		if sumFr > 0 {
+			// the flexible tracks take the remaining space
+			avail = bag.Min(avail, sumMax)
+		}
+		if sumMax > 0 {
+			for j, wd := range distributeWidths(amin, amax, avail) {
+				widths[autoCols[j]] = wd
+				free -= wd
+			}
+		}
+	}
+	if sumFr > 0 && free > 0 {
+		space := free
+		for i, f := range fr {
+			if f > 0 {
+				space += widths[i]
+			}
+		}
+		distributeFractions(widths, fr, space)
+	}
+	return widths
+}
+
+// distributeFractions shares the space between the flexible tracks according
+// to their fractions. The current widths of the tracks are their minimum
+// widths.
+func distributeFractions(widths []bag.ScaledPoint, fr []float64, space bag.ScaledPoint) {
+	flexible := make([]bool, len(fr))
+	var sumFr float64
+	for i, f := range fr {
+		if f > 0 {
+			flexible[i] = true
+			sumFr += f
+		}
+	}
+	for changed := true; changed && sumFr > 0; {
+		changed = false
+		for i, f := range fr {
+			if flexible[i] && bag.MultiplyFloat(space, f/sumFr) < widths[i] {
+				// the track keeps its minimum width
+				flexible[i] = false
+				space -= widths[i]
+				sumFr -= f
+				changed = true
+			}
+		}
+	}
+	for i, f := range fr {
+		if flexible[i] {
+			widths[i] = bag.MultiplyFloat(space, f/sumFr)
+		}
+	}
+}
+
+// gridRowHeight returns the height of row r with the height of the contents
+// ht.
+func gridRowHeight(tracks []GridTrack, r int, ht bag.ScaledPoint) bag.ScaledPoint {
+	t := gridTrack(tracks, r)
+	if mn, ok := trackLength(t.Min, 0); ok {
+		ht = bag.Max(ht, mn)
+	}
+	if mx, ok := trackLength(t.Max, 0); ok {
+		ht = bag.Min(ht, mx)
+	}
+	return ht
+}
+
+// buildGrid arranges the items of the grid container te (a box) in the content
+// box at x with the width hsize. It fills the page box and the height of ret.
+// Each row of the grid is a vertical list in the page box, items spanning
+// several rows are placed in their first row.
+func (fe *Document) buildGrid(te *Text, gc GridContainer, ret *VlistInfo, hsize, x, shiftDown bag.ScaledPoint) error {
+	var items []*gridItem
+	for _, itm := range te.Items {
+		t, ok := itm.(*Text)
+		if !ok {
+			continue
+		}
+		hv := SettingsToValues(t.Settings)
+		extra := hv.MarginLeft + hv.MarginRight + hv.BorderLeftWidth + hv.BorderRightWidth + hv.PaddingLeft + hv.PaddingRight
+		minWd, maxWd, err := fe.IntrinsicWidths(t)
+		if err != nil {
+			return err
+		}
+		it := &gridItem{te: t, minWd: minWd + extra, maxWd: maxWd + extra}
+		it.pos, _ = t.Settings[SettingGridItem].(GridItem)
+		items = append(items, it)
+	}
+	nCols, nRows := placeGridItems(items, len(gc.Columns), len(gc.Rows))
+	widths := gridColumnWidths(gc.Columns, nCols, items, hsize, gc.ColumnGap)
+	colX := make([]bag.ScaledPoint, nCols+1)
+	for i, wd := range widths {
+		colX[i+1] = colX[i] + wd + gc.ColumnGap
+	}
+	areaWidth := func(it *gridItem) bag.ScaledPoint {
+		return colX[it.col+it.colSpan] - colX[it.col] - gc.ColumnGap
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].row != items[j].row {
+			return items[i].row < items[j].row
+		}
+		return items[i].col < items[j].col
+	})
+
+	// rowTop[r] is the top of row r, rowTop[nRows] is the height of the grid
+	// plus the gap.
+	rowTop := make([]bag.ScaledPoint, nRows+1)
+	rowHeights := make([]bag.ScaledPoint, nRows)
+	for r := 0; r < nRows; r++ {
+		var ht bag.ScaledPoint
+		for _, it := range items {
+			if it.row != r {
+				continue
+			}
+			var err error
+			if it.box, err = fe.buildItemBox(it.te, areaWidth(it), 0, shiftDown+rowTop[r]); err != nil {
+				return err
+			}
+			ret.positioned = append(ret.positioned, it.box.info.positioned...)
+			if it.rowSpan == 1 {
+				ht = bag.Max(ht, it.box.height)
+			}
+		}
+		// the last row of a spanning item gets the missing height
+		for _, it := range items {
+			if it.rowSpan > 1 && it.row+it.rowSpan-1 == r {
+				ht = bag.Max(ht, it.box.height-(rowTop[r]-rowTop[it.row]))
+			}
+		}
+		rowHeights[r] = gridRowHeight(gc.Rows, r, ht)
+		rowTop[r+1] = rowTop[r] + rowHeights[r] + gc.RowGap
+	}
+
+	for r := 0; r < nRows; r++ {
+		var head, cur node.Node
+		var pos bag.ScaledPoint
+		for _, it := range items {
+			if it.row != r {
+				continue
+			}
+			k := node.NewKern()
+			k.Kern = colX[it.col] - pos
+			head = node.InsertAfter(head, cur, k)
+			cur = k
+			it.box.stretch(rowTop[it.row+it.rowSpan] - rowTop[it.row] - gc.RowGap)
+			wrap := node.Vpack(fe.boxToVList(it.box.info))
+			wrap.Width = areaWidth(it)
+			// items spanning several rows reach into the following rows
+			wrap.Height = rowHeights[r]
+			wrap.Depth = 0
+			wrap.Attributes = node.H{"origin": "grid item"}
+			head = node.InsertAfter(head, cur, wrap)
+			cur = wrap
+			pos = colX[it.col] + wrap.Width
+		}
+		if head == nil {
+			head = node.NewKern()
+		}
+		hl := node.Hpack(head)
+		hl.Height = rowHeights[r]
+		hl.Depth = 0
+		hl.Attributes = node.H{"origin": "grid row"}
+		if r < nRows-1 && gc.RowGap != 0 {
+			g := node.NewGlue()
+			g.Width = gc.RowGap
+			node.InsertAfter(hl, hl, g)
+		}
+		rowVL := node.Vpack(hl)
+		rowVL.Attributes = node.H{"height": rowVL.Height + rowVL.Depth, "x": x, "origin": "grid row"}
+		ret.Pagebox = append(ret.Pagebox, rowVL)
+	}
+	if nRows > 0 {
+		ret.height = rowTop[nRows] - gc.RowGap
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+func TestGridLineResolve(t *testing.T) {
+	testdata := []struct {
+		gl       GridLine
+		start    int
+		span     int
+		definite bool
+	}{
+		{GridLine{}, 0, 1, false},
+		{GridLine{Span: 2}, 0, 2, false},
+		{GridLine{Start: 2}, 1, 1, true},
+		{GridLine{Start: 1, End: 3}, 0, 2, true},
+		{GridLine{Start: 1, End: -1}, 0, 3, true},
+		{GridLine{End: 4, Span: 2}, 1, 2, true},
+	}
+	for _, td := range testdata {
+		start, span, definite := td.gl.resolve(3)
+		if start != td.start || span != td.span || definite != td.definite {
+			t.Errorf("%v.resolve(3) = %d, %d, %t, want %d, %d, %t", td.gl, start, span, definite, td.start, td.span, td.definite)
+		}
+	}
+}
+
+func TestPlaceGridItems(t *testing.T) {
+	// a header spanning all columns, an item in the second column of the
+	// second row and two auto placed items
+	items := []*gridItem{
+		{pos: GridItem{Column: GridLine{Start: 1, End: -1}}},
+		{pos: GridItem{Column: GridLine{Start: 2}, Row: GridLine{Start: 2}}},
+		{},
+		{},
+	}
+	nCols, nRows := placeGridItems(items, 2, 0)
+	if nCols != 2 || nRows != 3 {
+		t.Errorf("grid size = %d x %d, want 2 x 3", nCols, nRows)
+	}
+	want := [][2]int{{0, 0}, {1, 1}, {1, 0}, {2, 0}}
+	for i, it := range items {
+		if got := [2]int{it.row, it.col}; got != want[i] {
+			t.Errorf("item %d at %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestGridColumnWidths(t *testing.T) {
+	// 50pt 1fr 2fr auto with an item of 20pt in the auto column
+	tracks := []GridTrack{
+		{Min: 50 * bag.Factor, Max: 50 * bag.Factor},
+		{Min: "auto", Max: GridFraction(1)},
+		{Min: "auto", Max: GridFraction(2)},
+		{Min: "auto", Max: "auto"},
+	}
+	items := []*gridItem{{col: 3, colSpan: 1, minWd: 10 * bag.Factor, maxWd: 20 * bag.Factor}}
+	widths := gridColumnWidths(tracks, 4, items, 200*bag.Factor, 10*bag.Factor)
+	want := []bag.ScaledPoint{50 * bag.Factor, 33 * bag.Factor, 67 * bag.Factor, 20 * bag.Factor}
+	for i, wd := range widths {
+		if d := wd - want[i]; d < -bag.Factor || d > bag.Factor {
+			t.Errorf("column %d = %s, want %s", i, wd, want[i])
+		}
+	}
+
+	// without flexible tracks the auto tracks fill the width
+	tracks = []GridTrack{{Min: "auto", Max: "auto"}, {Min: "auto", Max: "auto"}}
+	items = []*gridItem{
+		{col: 0, colSpan: 1, maxWd: 20 * bag.Factor},
+		{col: 1, colSpan: 1, maxWd: 60 * bag.Factor},
+	}
+	widths = gridColumnWidths(tracks, 2, items, 160*bag.Factor, 0)
+	if widths[0] != 40*bag.Factor || widths[1] != 120*bag.Factor {
+		t.Errorf("auto columns = %s, %s, want 40pt, 120pt", widths[0], widths[1])
+	}
+}
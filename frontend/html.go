@@ -15,6 +15,55 @@ const (
 	BorderStyleNone BorderStyle = iota
 	// BorderStyleSolid is a solid line
 	BorderStyleSolid
+	// BorderStyleHidden is no border. In a table with collapsed borders it
+	// suppresses the borders of the adjacent cells.
+	BorderStyleHidden
+	// BorderStyleDotted is a series of round dots. Only table cells draw
+	// dots, other borders are solid.
+	BorderStyleDotted
+	// BorderStyleDashed is a series of short line segments. Only table cells
+	// draw dashes, other borders are solid.
+	BorderStyleDashed
+	// BorderStyleDouble is two parallel lines. Only table cells draw two
+	// lines, other borders are solid.
+	BorderStyleDouble
+)
+
+// ParseBorderStyle returns the border style of the CSS value v. Unsupported
+// styles such as groove are solid.
+func ParseBorderStyle(v string) BorderStyle {
+	switch v {
+	case "none":
+		return BorderStyleNone
+	case "hidden":
+		return BorderStyleHidden
+	case "dotted":
+		return BorderStyleDotted
+	case "dashed":
+		return BorderStyleDashed
+	case "double":
+		return BorderStyleDouble
+	}
+	return BorderStyleSolid
+}
+
+// visible returns true if the border style draws a border.
+func (sty BorderStyle) visible() bool {
+	return sty != BorderStyleNone && sty != BorderStyleHidden
+}
+
+// BoxDecorationBreak determines how the border, the padding and the
+// background of an inline element are drawn when the element is broken across
+// lines.
+type BoxDecorationBreak uint
+
+const (
+	// BoxDecorationBreakSlice draws the element as if it were not broken and
+	// slices it at the line breaks. This is the default.
+	BoxDecorationBreakSlice BoxDecorationBreak = iota
+	// BoxDecorationBreakClone draws the border and the padding around each
+	// line fragment.
+	BoxDecorationBreakClone
 )
 
 // HTMLProperties contains css values
@@ -50,12 +99,43 @@ type HTMLValues struct {
 }
 
 func (hv HTMLValues) hasBorder() bool {
-	return hv.BorderTopWidth > 0 && hv.BorderTopStyle != BorderStyleNone ||
-		hv.BorderLeftWidth > 0 && hv.BorderLeftStyle != BorderStyleNone ||
-		hv.BorderBottomWidth > 0 && hv.BorderBottomStyle != BorderStyleNone ||
-		hv.BorderRightWidth > 0 && hv.BorderRightStyle != BorderStyleNone
+	return hv.BorderTopWidth > 0 && hv.BorderTopStyle.visible() ||
+		hv.BorderLeftWidth > 0 && hv.BorderLeftStyle.visible() ||
+		hv.BorderBottomWidth > 0 && hv.BorderBottomStyle.visible() ||
+		hv.BorderRightWidth > 0 && hv.BorderRightStyle.visible()
+}
+
+func (hv HTMLValues) hasPadding() bool {
+	return hv.PaddingTop > 0 || hv.PaddingRight > 0 || hv.PaddingBottom > 0 || hv.PaddingLeft > 0
+}
+
+func (hv HTMLValues) hasBackground() bool {
+	return hv.BackgroundColor != nil && hv.BackgroundColor.Space != color.ColorNone
+}
+
+// isBoxSetting returns true if the setting belongs to the box of an element
+// (background, border, margin, padding, inline block and its vertical
+// alignment) and must not be inherited by the children.
+func isBoxSetting(st SettingType) bool {
+	switch st {
+	case SettingBackgroundColor,
+		SettingBorderBottomWidth, SettingBorderLeftWidth, SettingBorderRightWidth, SettingBorderTopWidth,
+		SettingBorderBottomColor, SettingBorderLeftColor, SettingBorderRightColor, SettingBorderTopColor,
+		SettingBorderBottomStyle, SettingBorderLeftStyle, SettingBorderRightStyle, SettingBorderTopStyle,
+		SettingBorderBottomLeftRadius, SettingBorderBottomRightRadius, SettingBorderTopLeftRadius, SettingBorderTopRightRadius,
+		SettingBoxDecorationBreak, SettingBreakAfter, SettingBreakBefore, SettingBreakInside, SettingInlineBlock, SettingInlineVAlign,
+		SettingClear, SettingColumnCount, SettingColumnGap, SettingColumnRule, SettingColumnSpan,
+		SettingFlex, SettingFlexItem, SettingFloat, SettingGrid, SettingGridItem,
+		SettingMarginBottom, SettingMarginLeft, SettingMarginRight, SettingMarginTop,
+		SettingPaddingBottom, SettingPaddingLeft, SettingPaddingRight, SettingPaddingTop, SettingPage, SettingPosition,
+		SettingRunning, SettingStringSet:
+		return true
+	}
+	return false
 }
 
+// SettingsToValues converts the background, border, margin and padding
+// settings to HTMLValues. The padding settings are removed from s.
 func SettingsToValues(s TypesettingSettings) HTMLValues {
 	hv := HTMLValues{}
 	if c, ok := s[SettingBackgroundColor]; ok {
@@ -175,13 +255,7 @@ func (d *Document) CSSPropertiesToValues(p HTMLProperties) HTMLValues {
 		case "border-left-color":
 			hv.BorderLeftColor = d.GetColor(v)
 		case "border-top-style", "border-right-style", "border-bottom-style", "border-left-style":
-			var sty BorderStyle
-			switch v {
-			case "none":
-				sty = BorderStyleNone
-			default:
-				sty = BorderStyleSolid
-			}
+			sty := ParseBorderStyle(v)
 			switch k {
 			case "border-top-style":
 				hv.BorderTopStyle = sty
@@ -294,25 +368,55 @@ func getBorderPaths(x0, y0, x1, y1, x2, y2, x3, y3 bag.ScaledPoint, hv HTMLValue
 	return
 }
 
-// HTMLBorder returns two string with a HTML border. The first string is part of
-// a prefix for a possible background string and the second string renders the
-// border.
-func (d *Document) HTMLBorder(vl *node.VList, hv HTMLValues) *node.VList {
-	width := vl.Width
-	height := vl.Height
-	black := d.GetColor("black")
+// drawBackground returns the PDF instructions to fill the area inside the
+// border with the background color.
+func drawBackground(x0, y0, x1, y1, x2, y2, x3, y3 bag.ScaledPoint, hv HTMLValues) string {
+	innerBG, _ := getBorderPaths(x0, y0, x1, y1, x2, y2, x3, y3, hv)
+	innerBG.Clip().Endpath()
+	innerBG.ColorNonstroking(*hv.BackgroundColor).Rect(x0, y3, x3-x0, y0-y3).Fill()
+	return "q " + innerBG.String() + " Q"
+}
+
+// drawBorder returns the PDF instructions to draw the four borders. The
+// border colors must be set.
+func drawBorder(x0, y0, x1, y1, x2, y2, x3, y3 bag.ScaledPoint, hv HTMLValues) string {
+	inner, outer := getBorderPaths(x0, y0, x1, y1, x2, y2, x3, y3, hv)
+	inner.Clip().Endpath()
+	// for debugging:
+	// inner.Stroke().Endpath()
+
+	// Draw the four trapezoids
+	inner.ColorNonstroking(*hv.BorderTopColor).Moveto(x0, y0).Lineto(x1, y1).Lineto(x2, y1).Lineto(x3, y0).Close().Fill()
+	inner.ColorNonstroking(*hv.BorderLeftColor).Moveto(x0, y3).Lineto(x1, y2).Lineto(x1, y1).Lineto(x0, y0).Close().Fill()
+	inner.ColorNonstroking(*hv.BorderBottomColor).Moveto(x0, y3).Lineto(x3, y3).Lineto(x2, y2).Lineto(x1, y2).Close().Fill()
+	inner.ColorNonstroking(*hv.BorderRightColor).Moveto(x2, y2).Lineto(x3, y3).Lineto(x3, y0).Lineto(x2, y1).Close().Fill()
+
+	return "q " + outer.String() + " " + inner.String() + " Q"
+}
+
+// setDefaultBorderColors sets all missing border colors to col.
+func (hv *HTMLValues) setDefaultBorderColors(col *color.Color) {
 	if hv.BorderTopColor == nil {
-		hv.BorderTopColor = black
+		hv.BorderTopColor = col
 	}
 	if hv.BorderRightColor == nil {
-		hv.BorderRightColor = black
+		hv.BorderRightColor = col
 	}
 	if hv.BorderBottomColor == nil {
-		hv.BorderBottomColor = black
+		hv.BorderBottomColor = col
 	}
 	if hv.BorderLeftColor == nil {
-		hv.BorderLeftColor = black
+		hv.BorderLeftColor = col
 	}
+}
+
+// HTMLBorder returns two string with a HTML border. The first string is part of
+// a prefix for a possible background string and the second string renders the
+// border.
+func (d *Document) HTMLBorder(vl *node.VList, hv HTMLValues) *node.VList {
+	width := vl.Width
+	height := vl.Height
+	hv.setDefaultBorderColors(d.GetColor("black"))
 
 	// We start with 4 trapezoids (1 for each border).
 	//
@@ -364,11 +468,7 @@ func (d *Document) HTMLBorder(vl *node.VList, hv HTMLValues) *node.VList {
 		// this is the rule node for the background
 		rbg := node.NewRule()
 		rbg.Hide = true
-		var innerBG *pdfdraw.Object
-		innerBG, _ = getBorderPaths(xbg0, ybg0, xbg1, ybg1, xbg2, ybg2, xbg3, ybg3, hv)
-		innerBG.Clip().Endpath()
-		innerBG.ColorNonstroking(*hv.BackgroundColor).Rect(xbg0, ybg3, xbg3-xbg0, ybg0-ybg3).Fill()
-		rbg.Pre = "q " + innerBG.String() + " Q"
+		rbg.Pre = drawBackground(xbg0, ybg0, xbg1, ybg1, xbg2, ybg2, xbg3, ybg3, hv)
 		rbg.Attributes = node.H{"origin": "html background color"}
 		vl.List = node.InsertBefore(vl.List, vl.List, rbg)
 	}
@@ -406,18 +506,7 @@ func (d *Document) HTMLBorder(vl *node.VList, hv HTMLValues) *node.VList {
 		r.Attributes = node.H{"origin": "html border + clipping"}
 		r.Hide = true
 
-		inner, outer := getBorderPaths(x0, y0, x1, y1, x2, y2, x3, y3, hv)
-		inner.Clip().Endpath()
-		// for debugging:
-		// inner.Stroke().Endpath()
-
-		// Draw the four trapezoids
-		inner.ColorNonstroking(*hv.BorderTopColor).Moveto(x0, y0).Lineto(x1, y1).Lineto(x2, y1).Lineto(x3, y0).Close().Fill()
-		inner.ColorNonstroking(*hv.BorderLeftColor).Moveto(x0, y3).Lineto(x1, y2).Lineto(x1, y1).Lineto(x0, y0).Close().Fill()
-		inner.ColorNonstroking(*hv.BorderBottomColor).Moveto(x0, y3).Lineto(x3, y3).Lineto(x2, y2).Lineto(x1, y2).Close().Fill()
-		inner.ColorNonstroking(*hv.BorderRightColor).Moveto(x2, y2).Lineto(x3, y3).Lineto(x3, y0).Lineto(x2, y1).Close().Fill()
-
-		r.Pre = "q " + outer.String() + " " + inner.String() + " Q"
+		r.Pre = drawBorder(x0, y0, x1, y1, x2, y2, x3, y3, hv)
 		head = node.InsertAfter(head, tail, r)
 	}
 
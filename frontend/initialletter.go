@@ -0,0 +1,162 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
+	"github.com/speedata/boxesandglue/backend/lang"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// InitialLetter is the value of SettingInitialLetter. A Text with this setting
+// at the start of a paragraph is enlarged so that it spans several lines (a
+// drop cap). The following lines are indented by the width of the initial
+// letter and its right margin (SettingMarginRight).
+type InitialLetter struct {
+	// Size is the height of the initial letter in lines. The letter reaches
+	// from the cap height of the first line to the baseline of the line Size.
+	Size float64
+	// Sink is the number of lines the initial letter sinks into the
+	// paragraph. The baseline of the initial letter is the baseline of this
+	// line. 0 means the integer part of Size.
+	Sink int
+}
+
+func (il InitialLetter) sink() int {
+	if il.Sink > 0 {
+		return il.Sink
+	}
+	return int(il.Size)
+}
+
+// initialLetter returns the first item of te and its initial letter settings
+// if te starts with an initial letter which is followed by other material.
+func initialLetter(te *Text) (*Text, InitialLetter) {
+	if len(te.Items) < 2 {
+		return nil, InitialLetter{}
+	}
+	t, ok := te.Items[0].(*Text)
+	if !ok {
+		return nil, InitialLetter{}
+	}
+	il, ok := t.Settings[SettingInitialLetter].(InitialLetter)
+	if !ok || il.Size < 1 {
+		return nil, InitialLetter{}
+	}
+	return t, il
+}
+
+// firstFont returns the font of the first glyph in the list starting at n or
+// nil if there is no glyph.
+func firstFont(n node.Node) *font.Font {
+	for e := n; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.Glyph:
+			if t.Font != nil {
+				return t.Font
+			}
+		case *node.HList:
+			if f := firstFont(t.List); f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// capHeight returns the cap height of f. If the font does not contain the cap
+// height, the ascender is used instead and a warning is logged.
+func capHeight(f *font.Font) bag.ScaledPoint {
+	if f.CapHeight > 0 {
+		return f.CapHeight
+	}
+	bag.Logger.Warn("font has no cap height, using the ascender for the initial letter", "size", f.Size)
+	return f.Size - f.Depth
+}
+
+// insertInitialLetter typesets the initial letter te and places it in front
+// of the paragraph hlist. The font size of the initial letter is chosen so
+// that its cap height reaches from the cap height of the first line to the
+// baseline of the line il.Size. The cap height of the first line is taken
+// from its first glyph or, in a paragraph without glyphs, from the font of
+// the paragraph settings. The paragraph shape of the linebreak settings
+// is changed to indent the lines next to the initial letter in addition to
+// the indentation of the paragraph. The paragraph settings and the
+// language are inherited by te.
+func (fe *Document) insertInitialLetter(hlist node.Node, te *Text, il InitialLetter, settings TypesettingSettings, ls *node.LinebreakSettings, language *lang.Lang) (node.Node, error) {
+	letter := &Text{Settings: make(TypesettingSettings), Items: te.Items}
+	for k, v := range te.Settings {
+		letter.Settings[k] = v
+	}
+	for k, v := range settings {
+		if isBoxSetting(k) {
+			continue
+		}
+		if _, found := letter.Settings[k]; !found {
+			letter.Settings[k] = v
+		}
+	}
+	f := firstFont(hlist)
+	if f == nil {
+		var err error
+		if f, err = fe.settingsFont(settings); err != nil {
+			return nil, err
+		}
+	}
+	height := capHeight(f) + bag.MultiplyFloat(ls.LineHeight, il.Size-1)
+
+	nl, _, err := fe.mknodes(letter, ls.HSize, language, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f := firstFont(nl); f != nil {
+		letter.Settings[SettingSize] = height * f.Size / capHeight(f)
+		if nl, _, err = fe.mknodes(letter, ls.HSize, language, false, nil); err != nil {
+			return nil, err
+		}
+	}
+	if nl == nil {
+		return hlist, nil
+	}
+	hl := node.Hpack(nl)
+	hl.Attributes = node.H{"origin": "initial letter"}
+	sink := il.sink()
+	hl.Shift = ls.LineHeight * bag.ScaledPoint(sink-1)
+	// The initial letter must not change the height and the depth of the
+	// first line.
+	hl.Height = hl.Shift
+	hl.Depth = -hl.Shift
+
+	var gap bag.ScaledPoint
+	if mr, ok := te.Settings[SettingMarginRight].(bag.ScaledPoint); ok {
+		gap = mr
+	}
+	indent := hl.Width + gap
+	ls.ParagraphShape = indentRows(ls.ParagraphShape, indent, sink)
+
+	// The first line is indented like the other lines next to the initial
+	// letter, so the initial letter moves back into the indentation.
+	back := node.NewKern()
+	back.Kern = -indent
+	back.Attributes = node.H{"origin": "initial letter"}
+	after := node.NewKern()
+	after.Kern = gap
+	after.Attributes = node.H{"origin": "initial letter"}
+	head := node.InsertAfter(back, back, hl)
+	head = node.InsertAfter(head, hl, after)
+	return node.InsertAfter(head, after, hlist), nil
+}
+
+// indentRows returns a paragraph shape which indents the first rows lines of
+// shape (nil is no indentation) by indent on the left side.
+func indentRows(shape node.ParagraphShapeFunc, indent bag.ScaledPoint, rows int) node.ParagraphShapeFunc {
+	return func(row int) (bag.ScaledPoint, bag.ScaledPoint) {
+		var left, right bag.ScaledPoint
+		if shape != nil {
+			left, right = shape(row)
+		}
+		if row < rows {
+			left += indent
+		}
+		return left, right
+	}
+}
@@ -0,0 +1,137 @@
+package frontend
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
+)
+
+func TestIndentRows(t *testing.T) {
+	shape := func(row int) (bag.ScaledPoint, bag.ScaledPoint) {
+		return bag.ScaledPoint(row) * bag.Factor, 2 * bag.Factor
+	}
+	testdata := []struct {
+		shape       node.ParagraphShapeFunc
+		row         int
+		left, right bag.ScaledPoint
+	}{
+		{nil, 0, 10 * bag.Factor, 0},
+		{nil, 2, 0, 0},
+		{shape, 1, 11 * bag.Factor, 2 * bag.Factor},
+		{shape, 3, 3 * bag.Factor, 2 * bag.Factor},
+	}
+	for _, td := range testdata {
+		left, right := indentRows(td.shape, 10*bag.Factor, 2)(td.row)
+		if left != td.left || right != td.right {
+			t.Errorf("indentRows()(%d) = %s, %s, want %s, %s", td.row, left, right, td.left, td.right)
+		}
+	}
+}
+
+func TestInsertInitialLetter(t *testing.T) {
+	var buf bytes.Buffer
+	fe, err := NewForWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff := fe.NewFontFamily("text")
+	if err = ff.AddMember(&FontSource{Name: "crimson", Data: crimsonproregular.TTF}, FontWeight400, FontStyleNormal); err != nil {
+		t.Fatal(err)
+	}
+	settings := TypesettingSettings{SettingFontFamily: ff, SettingSize: 10 * bag.Factor}
+	hlist, err := fe.BuildNodelistFromString(settings, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	letter := NewText()
+	letter.Settings[SettingMarginRight] = 2 * bag.Factor
+	letter.Items = append(letter.Items, "W")
+
+	// the paragraph has a hanging indent of 5pt from the second line on
+	ls := node.NewLinebreakSettings()
+	ls.HSize = 100 * bag.Factor
+	ls.LineHeight = 12 * bag.Factor
+	ls.Indent = 5 * bag.Factor
+	ls.IndentRows = -1
+	head, err := fe.insertInitialLetter(hlist, letter, InitialLetter{Size: 3}, settings, ls, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, ok := head.(*node.Kern)
+	if !ok {
+		t.Fatalf("head = %T, want *node.Kern", head)
+	}
+	hl, ok := back.Next().(*node.HList)
+	if !ok {
+		t.Fatalf("initial letter = %T, want *node.HList", back.Next())
+	}
+	indent := hl.Width + 2*bag.Factor
+	if back.Kern != -indent {
+		t.Errorf("kern before the initial letter = %s, want %s", back.Kern, -indent)
+	}
+	// the initial letter drops to the baseline of the third line
+	if got, want := hl.Shift, 24*bag.Factor; got != want {
+		t.Errorf("drop = %s, want %s", got, want)
+	}
+	if ls.Indent != 5*bag.Factor || ls.IndentRows != -1 {
+		t.Errorf("paragraph indent = %s (%d rows), want 5pt (-1)", ls.Indent, ls.IndentRows)
+	}
+	for row, want := range []bag.ScaledPoint{indent, indent, indent, 0} {
+		if left, _ := ls.ParagraphShape(row); left != want {
+			t.Errorf("shape(%d) = %s, want %s", row, left, want)
+		}
+	}
+}
+
+func TestInitialLetterWithoutGlyphs(t *testing.T) {
+	var buf bytes.Buffer
+	fe, err := NewForWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff := fe.NewFontFamily("text")
+	if err = ff.AddMember(&FontSource{Name: "crimson", Data: crimsonproregular.TTF}, FontWeight400, FontStyleNormal); err != nil {
+		t.Fatal(err)
+	}
+	settings := TypesettingSettings{SettingFontFamily: ff, SettingSize: 10 * bag.Factor}
+	text, err := fe.BuildNodelistFromString(settings, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := node.NewKern()
+	k.Kern = 10 * bag.Factor
+	// without a glyph in the paragraph the cap height comes from the font of
+	// the paragraph settings
+	var widths []bag.ScaledPoint
+	for _, hlist := range []node.Node{text, k} {
+		letter := NewText()
+		letter.Items = append(letter.Items, "W")
+		ls := node.NewLinebreakSettings()
+		ls.HSize = 100 * bag.Factor
+		ls.LineHeight = 12 * bag.Factor
+		head, err := fe.insertInitialLetter(hlist, letter, InitialLetter{Size: 3}, settings, ls, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		widths = append(widths, head.Next().(*node.HList).Width)
+	}
+	if widths[0] != widths[1] {
+		t.Errorf("width of the initial letter = %s, want %s", widths[1], widths[0])
+	}
+}
+
+func TestCapHeight(t *testing.T) {
+	f := &font.Font{Size: 10 * bag.Factor, Depth: 2 * bag.Factor, CapHeight: 7 * bag.Factor}
+	if got := capHeight(f); got != 7*bag.Factor {
+		t.Errorf("capHeight() = %s, want 7pt", got)
+	}
+	// the ascender without a cap height in the font
+	f.CapHeight = 0
+	if got := capHeight(f); got != 8*bag.Factor {
+		t.Errorf("capHeight() = %s, want 8pt", got)
+	}
+}
@@ -0,0 +1,201 @@
+package frontend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// inlineObject wraps the list starting at head (an image or a vlist) in a
+// hlist which is placed in the line like a glyph. The hlist is shifted
+// according to the vertical alignment va (a VerticalAlignment or a
+// bag.ScaledPoint). The alignments top and bottom depend on the height of the
+// line and are resolved in alignInlineObjects after the line breaking. The
+// ascender, the descender and the x-height are taken from the font in the
+// settings ts.
+func (fe *Document) inlineObject(head node.Node, va any, ts TypesettingSettings) *node.HList {
+	hl := node.Hpack(head)
+	hl.Attributes = node.H{"origin": "inline object"}
+
+	var ascent, descent, xheight bag.ScaledPoint
+	if fnt, err := fe.settingsFont(ts); err == nil {
+		ascent = fnt.Size - fnt.Depth
+		descent = fnt.Depth
+		xheight = fnt.XHeight
+	} else {
+		// Without a font use the usual approximations for the ascender, the
+		// descender and the x-height.
+		fontsize := 12 * bag.Factor
+		if fs, ok := ts[SettingSize].(bag.ScaledPoint); ok {
+			fontsize = fs
+		}
+		ascent = fontsize * 4 / 5
+		descent = fontsize - ascent
+		xheight = fontsize / 2
+	}
+
+	switch t := va.(type) {
+	case VerticalAlignment:
+		switch t {
+		case VAlignMiddle:
+			hl.Shift = (hl.Height-hl.Depth)/2 - xheight/2
+		case VAlignTextTop:
+			hl.Shift = hl.Height - ascent
+		case VAlignTextBottom:
+			hl.Shift = descent - hl.Depth
+		case VAlignTop, VAlignBottom:
+			hl.Attributes["valign"] = t
+			hl.Attributes["ascent"] = ascent
+			hl.Attributes["descent"] = descent
+		}
+	case bag.ScaledPoint:
+		hl.Shift = -t
+	}
+	return hl
+}
+
+// alignInlineObjects shifts the inline objects with the vertical alignment top
+// or bottom to the top or the bottom of their line. The line heights and the
+// glue between the lines are adjusted accordingly.
+func alignInlineObjects(vl *node.VList, lineheight bag.ScaledPoint) *node.VList {
+	changed := false
+	for e := vl.List; e != nil; e = e.Next() {
+		hl, ok := e.(*node.HList)
+		if !ok {
+			continue
+		}
+		var objects []*node.HList
+		var ht, dp bag.ScaledPoint
+		for cur := hl.List; cur != nil; cur = cur.Next() {
+			if obj, ok := cur.(*node.HList); ok {
+				if _, ok := obj.Attributes["valign"]; ok {
+					objects = append(objects, obj)
+					if a := obj.Attributes["ascent"].(bag.ScaledPoint); a > ht {
+						ht = a
+					}
+					if d := obj.Attributes["descent"].(bag.ScaledPoint); d > dp {
+						dp = d
+					}
+					continue
+				}
+			}
+			_, h, d := node.Dimensions(cur, cur, node.Horizontal)
+			ht = bag.Max(ht, h)
+			dp = bag.Max(dp, d)
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		changed = true
+		lineHt, lineDp := ht, dp
+		for _, obj := range objects {
+			if obj.Attributes["valign"] == VAlignTop {
+				obj.Shift = obj.Height - ht
+			} else {
+				obj.Shift = dp - obj.Depth
+			}
+			lineHt = bag.Max(lineHt, obj.Height-obj.Shift)
+			lineDp = bag.Max(lineDp, obj.Depth+obj.Shift)
+		}
+		hl.Height = lineHt
+		hl.Depth = lineDp
+
+		// the glue before a line and the glue after the last line depend on
+		// the height of the line.
+		lineskip := bag.Max(0, lineheight-lineHt-lineDp)
+		if g, ok := hl.Prev().(*node.Glue); ok && g.Attributes["origin"] == "lineskip" {
+			g.Width = lineskip
+		}
+		if g, ok := hl.Next().(*node.Glue); ok && g.Attributes["origin"] == "last lineskip" {
+			g.Width = lineskip
+		}
+	}
+	if !changed {
+		return vl
+	}
+	newVL := node.Vpack(vl.List)
+	newVL.Attributes = vl.Attributes
+	return newVL
+}
+
+// parseInlineBlockWidth returns the width of an inline block from the width
+// setting which is either an absolute length or a percentage of hsize.
+func parseInlineBlockWidth(wd any, hsize bag.ScaledPoint) (bag.ScaledPoint, error) {
+	switch t := wd.(type) {
+	case bag.ScaledPoint:
+		return t, nil
+	case string:
+		if t == "" || t == "auto" {
+			return 0, nil
+		}
+		if p, ok := strings.CutSuffix(t, "%"); ok {
+			f, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return 0, err
+			}
+			return bag.MultiplyFloat(hsize, f/100), nil
+		}
+		return bag.Sp(t)
+	}
+	return 0, fmt.Errorf("unknown width %v", wd)
+}
+
+// buildInlineBlock formats the text as a block (CSS display: inline-block)
+// and returns an inline object which can be placed in a line. Without a
+// width, the block gets the width of its contents but not more than hsize.
+func (fe *Document) buildInlineBlock(te *Text, hsize bag.ScaledPoint) (*node.HList, error) {
+	hv := SettingsToValues(te.Settings)
+	contents := NewText()
+	for k, v := range te.Settings {
+		if !isBoxSetting(k) && k != SettingWidth {
+			contents.Settings[k] = v
+		}
+	}
+	contents.Items = te.Items
+	inner := NewText()
+	inner.Settings[SettingBox] = true
+	inner.Items = []any{contents}
+
+	var wd bag.ScaledPoint
+	var err error
+	if sWd, ok := te.Settings[SettingWidth]; ok {
+		if wd, err = parseInlineBlockWidth(sWd, hsize); err != nil {
+			return nil, err
+		}
+	}
+	if wd == 0 {
+		// shrink to fit
+		vl, err := fe.CreateVlist(inner, bag.MaxSP)
+		if err != nil {
+			return nil, err
+		}
+		wd = maxContentWidth(vl)
+		if avail := hsize - hv.MarginLeft - hv.MarginRight - hv.BorderLeftWidth - hv.BorderRightWidth - hv.PaddingLeft - hv.PaddingRight; hsize > 0 && wd > avail {
+			wd = avail
+		}
+	}
+	vl, err := fe.CreateVlist(inner, wd)
+	if err != nil {
+		return nil, err
+	}
+	vl.Width = wd
+	vl = fe.HTMLBorder(vl, hv)
+
+	var head node.Node = vl
+	if hv.MarginLeft != 0 {
+		k := node.NewKern()
+		k.Kern = hv.MarginLeft
+		head = node.InsertBefore(head, vl, k)
+	}
+	if hv.MarginRight != 0 {
+		k := node.NewKern()
+		k.Kern = hv.MarginRight
+		node.InsertAfter(head, vl, k)
+	}
+	hl := fe.inlineObject(head, te.Settings[SettingInlineVAlign], te.Settings)
+	hl.Attributes["origin"] = "inline block"
+	return hl, nil
+}
@@ -0,0 +1,51 @@
+package frontend
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
+)
+
+func TestInlineObjectFontMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	fe, err := NewForWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff := fe.NewFontFamily("text")
+	if err = ff.AddMember(&FontSource{Name: "crimson", Data: crimsonproregular.TTF}, FontWeight400, FontStyleNormal); err != nil {
+		t.Fatal(err)
+	}
+	settings := TypesettingSettings{
+		SettingFontFamily: ff,
+		SettingSize:       10 * bag.Factor,
+	}
+	fnt, err := fe.settingsFont(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the font metrics differ from the approximations
+	if fnt.Depth == 2*bag.Factor || fnt.XHeight == 5*bag.Factor {
+		t.Fatalf("font metrics depth %s, x-height %s are the approximations", fnt.Depth, fnt.XHeight)
+	}
+	testdata := []struct {
+		va    VerticalAlignment
+		shift bag.ScaledPoint
+	}{
+		{VAlignTextTop, 20*bag.Factor - (fnt.Size - fnt.Depth)},
+		{VAlignTextBottom, fnt.Depth},
+		{VAlignMiddle, 10*bag.Factor - fnt.XHeight/2},
+	}
+	for _, td := range testdata {
+		r := node.NewRule()
+		r.Width = 5 * bag.Factor
+		r.Height = 20 * bag.Factor
+		hl := fe.inlineObject(r, td.va, settings)
+		if hl.Shift != td.shift {
+			t.Errorf("inlineObject(%v) shift = %s, want %s", td.va, hl.Shift, td.shift)
+		}
+	}
+}
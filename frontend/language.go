@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/speedata/boxesandglue/backend/bag"
 	"github.com/speedata/boxesandglue/backend/font"
 	"github.com/speedata/boxesandglue/backend/lang"
 	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/textlayout/harfbuzz"
 )
 
 //go:generate rake genpatterns
@@ -88,36 +90,209 @@ func GetLanguage(langname string) (*lang.Lang, error) {
 	return l, nil
 }
 
-func insertBreakpoints(l *lang.Lang, word *strings.Builder, wordstart node.Node, fnt *font.Font) {
-	cur := wordstart
-	if word.Len() > 0 {
-		str := word.String()
-		word.Reset()
-		bp := l.Hyphenate(str)
-		for _, step := range bp {
-			for i := 0; i <= step-1; i++ {
-				cur = cur.Next()
-				if cur.Type() == node.TypeKern {
-					cur = cur.Next()
-				}
-			}
-			disc := node.NewDisc()
-			hyphen := node.NewGlyph()
-			if fnt != nil {
-				hyphen.Font = fnt
-				hyphen.Width = fnt.Hyphenchar.Advance
-				hyphen.Components = fnt.Hyphenchar.Components
-				hyphen.Codepoint = fnt.Hyphenchar.Codepoint
+// hyphenationSettings contains the paragraph wide settings for the automatic
+// hyphenation. Zero values mean the default of the language.
+type hyphenationSettings struct {
+	limitChars HyphenateLimitChars
+	hyphenChar string
+}
+
+// isExplicitHyphen returns true if the string is a hyphen character which
+// allows a line break after it.
+func isExplicitHyphen(s string) bool {
+	return s == "-" || s == "\u2010"
+}
+
+// hyphenNode returns the node which is inserted at a hyphenation point. If
+// hyphenchar is empty, the hyphen character of the font is used.
+func hyphenNode(fnt *font.Font, hyphenchar string) node.Node {
+	if fnt == nil || hyphenchar == "" {
+		hyphen := node.NewGlyph()
+		if fnt != nil {
+			hyphen.Font = fnt
+			hyphen.Width = fnt.Hyphenchar.Advance
+			hyphen.Components = fnt.Hyphenchar.Components
+			hyphen.Codepoint = fnt.Hyphenchar.Codepoint
+		}
+		return hyphen
+	}
+	var head, cur node.Node
+	for _, r := range fnt.Shape(hyphenchar, nil) {
+		g := node.NewGlyph()
+		g.Font = fnt
+		g.Width = r.Advance
+		g.Height = r.Height
+		g.Depth = r.Depth
+		g.Components = r.Components
+		g.Codepoint = r.Codepoint
+		head = node.InsertAfter(head, cur, g)
+		cur = g
+	}
+	if head != nil && head.Next() != nil {
+		return node.Hpack(head)
+	}
+	return head
+}
+
+// shapingInfo contains the settings of a text run which are necessary to
+// shape parts of a word again at a hyphenation point. It is stored in the
+// attribute "shaping" of the first glyph of the run.
+type shapingInfo struct {
+	font          *font.Font
+	features      []harfbuzz.Feature
+	letterspacing bag.ScaledPoint
+	yoffset       bag.ScaledPoint
+}
+
+// shape returns the glyphs and kerns of the shaped string str. A trailing kern
+// is omitted, since the kern after the fragment is not known here.
+func (si *shapingInfo) shape(str string) node.Node {
+	var head, cur node.Node
+	for _, r := range si.font.Shape(str, si.features) {
+		g := node.NewGlyph()
+		g.Font = si.font
+		g.Codepoint = r.Codepoint
+		g.Components = r.Components
+		g.Width = r.Advance
+		g.Height = r.Height
+		g.Depth = r.Depth
+		g.YOffset = si.yoffset
+		head = node.InsertAfter(head, cur, g)
+		cur = g
+		if kern := r.Kernafter + si.letterspacing; kern != 0 {
+			k := node.NewKern()
+			k.Kern = kern
+			head = node.InsertAfter(head, cur, k)
+			cur = k
+		}
+	}
+	if k, ok := cur.(*node.Kern); ok {
+		head = node.DeleteFromList(head, k)
+	}
+	return head
+}
+
+// wordGlyph is a glyph of a word and the range of characters (start
+// inclusive, end exclusive) it represents. A ligature represents more than
+// one character.
+type wordGlyph struct {
+	glyph      *node.Glyph
+	start, end int
+}
+
+// insertBreakpoints inserts discretionaries in the word starting at
+// wordstart. With shaping information the characters around each hyphenation
+// point are shaped again for the broken and the unbroken case, so ligatures
+// across the hyphenation point get resolved and the hyphen is kerned.
+// Otherwise a hyphen is only inserted between two glyphs.
+func insertBreakpoints(l *lang.Lang, word *strings.Builder, wordstart node.Node, fnt *font.Font, si *shapingInfo, hs *hyphenationSettings) {
+	if word.Len() == 0 {
+		return
+	}
+	runes := []rune(word.String())
+	word.Reset()
+	if len(runes) < hs.limitChars.Word {
+		return
+	}
+	left, right := l.Lefthyphenmin, l.Righthyphenmin
+	if hs.limitChars.Before > 0 {
+		left = hs.limitChars.Before
+	}
+	if hs.limitChars.After > 0 {
+		right = hs.limitChars.After
+	}
+	bp := l.HyphenateLimits(string(runes), left, right)
+	if len(bp) == 0 {
+		return
+	}
+
+	var glyphs []wordGlyph
+	pos := 0
+	for e := wordstart; e != nil && pos < len(runes); e = e.Next() {
+		g, ok := e.(*node.Glyph)
+		if !ok {
+			continue
+		}
+		count := utf8.RuneCountInString(g.Components)
+		if count == 0 {
+			count = 1
+		}
+		glyphs = append(glyphs, wordGlyph{glyph: g, start: pos, end: pos + count})
+		pos += count
+	}
+	glyphAt := func(pos int) int {
+		for i, wg := range glyphs {
+			if wg.start <= pos && pos < wg.end {
+				return i
 			}
+		}
+		return -1
+	}
 
-			disc.Pre = hyphen
-			node.InsertBefore(wordstart, cur, disc)
+	hyphenchar := hs.hyphenChar
+	if hyphenchar == "" && si != nil {
+		hyphenchar = si.font.Hyphenchar.Components
+	}
+	// the glyphs up to lastUsed belong to the previous discretionary
+	lastUsed := -1
+	pos = 0
+	for _, step := range bp {
+		pos += step
+		before, after := glyphAt(pos-1), glyphAt(pos)
+		if before < 0 || after < 0 {
+			continue
 		}
+		disc := node.NewDisc()
+		if si == nil {
+			if before == after {
+				// no hyphenation inside a ligature
+				continue
+			}
+			disc.Pre = hyphenNode(fnt, hs.hyphenChar)
+			node.InsertBefore(wordstart, glyphs[after].glyph, disc)
+			continue
+		}
+		// Two discretionaries must not replace the same glyph. If the glyph
+		// before the break point belongs to the previous discretionary (one
+		// character between two hyphenation points), this discretionary
+		// starts at the break point.
+		from := before
+		if from <= lastUsed {
+			from = lastUsed + 1
+		}
+		if from > after {
+			continue
+		}
+		first, last := glyphs[from].glyph, glyphs[after].glyph
+		prev, next := first.Prev(), last.Next()
+		if prev == nil {
+			// the head of the list must not be replaced
+			continue
+		}
+		start, end := glyphs[from].start, glyphs[after].end
+		disc.Pre = si.shape(string(runes[start:pos]) + hyphenchar)
+		disc.Post = si.shape(string(runes[pos:end]))
+		// the original glyphs are typeset if the line is not broken here
+		first.SetPrev(nil)
+		last.SetNext(nil)
+		disc.Replace = first
+		prev.SetNext(disc)
+		disc.SetPrev(prev)
+		if next != nil {
+			next.SetPrev(disc)
+			disc.SetNext(next)
+		}
+		lastUsed = after
 	}
 }
 
-// Hyphenate inserts hyphenation points in to the list.
+// Hyphenate inserts hyphenation points in to the list. The hyphenation
+// exceptions of the language take precedence over the patterns.
 func Hyphenate(nodelist node.Node, defaultLang *lang.Lang) {
+	hyphenate(nodelist, defaultLang, &hyphenationSettings{})
+}
+
+func hyphenate(nodelist node.Node, defaultLang *lang.Lang, hs *hyphenationSettings) {
 	// hyphenation points should be inserted when a language changes or when
 	// the word ends (with a comma or a space for example).
 	curlang := defaultLang
@@ -125,21 +300,32 @@ func Hyphenate(nodelist node.Node, defaultLang *lang.Lang) {
 
 	var wordstart node.Node
 	var b strings.Builder
-	var curfont *font.Font
+	var wordfont *font.Font
+	var curShaping, wordShaping *shapingInfo
 
 	for e := nodelist; e != nil; e = e.Next() {
 		switch v := e.(type) {
 		case *node.Glyph:
-			curfont = v.Font
+			if si, ok := v.Attributes["shaping"].(*shapingInfo); ok {
+				curShaping = si
+			} else if curShaping != nil && curShaping.font != v.Font {
+				curShaping = nil
+			}
 			if wordstart == nil && v.Hyphenate {
 				b.Reset()
 				wordstart = e
+				wordfont = v.Font
+				wordShaping = curShaping
 			}
 			if wordstart != nil && !v.Hyphenate {
 				wordboundary = true
 			}
 
 			if v.Hyphenate {
+				if curShaping != wordShaping {
+					// the word consists of several text runs
+					wordShaping = nil
+				}
 				if v.Components != "" {
 					b.WriteString(v.Components)
 				} else {
@@ -158,12 +344,12 @@ func Hyphenate(nodelist node.Node, defaultLang *lang.Lang) {
 
 		}
 		if wordboundary {
-			insertBreakpoints(curlang, &b, wordstart, curfont)
+			insertBreakpoints(curlang, &b, wordstart, wordfont, wordShaping, hs)
 			wordstart = nil
 			wordboundary = false
 		}
 	}
 	if wordstart != nil {
-		insertBreakpoints(curlang, &b, wordstart, curfont)
+		insertBreakpoints(curlang, &b, wordstart, wordfont, wordShaping, hs)
 	}
 }
@@ -3,12 +3,15 @@ package frontend
 import (
 	"bytes"
 	"path/filepath"
+	"strings"
 	"testing"
 	"unicode"
 
 	"github.com/speedata/boxesandglue/backend/bag"
 	"github.com/speedata/boxesandglue/backend/document"
+	"github.com/speedata/boxesandglue/backend/font"
 	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
 )
 
 func TestLoadLang(t *testing.T) {
@@ -74,3 +77,110 @@ func TestHyphenate(t *testing.T) {
 		head = head.Next()
 	}
 }
+
+func TestHyphenateLigature(t *testing.T) {
+	var head, cur node.Node
+	g := node.NewGlue()
+	g.Width = 5 * bag.Factor
+	head = g
+	cur = g
+	for _, c := range []string{"o", "ffi", "c", "i", "a", "l", "."} {
+		n := node.NewGlyph()
+		n.Hyphenate = c != "."
+		n.Components = c
+		n.Width = 5 * bag.Factor
+		head = node.InsertAfter(head, cur, n)
+		cur = n
+	}
+
+	var dummy bytes.Buffer
+	doc := document.NewDocument(&dummy)
+	l, err := doc.LoadPatternFile(filepath.Join("testdata/hyph-en-us.pat.txt"), "dummylang")
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	// of-fi-cial: without shaping information there is no hyphenation point
+	// inside the ligature.
+	Hyphenate(head, l)
+	data := []node.Type{
+		node.TypeGlue, node.TypeGlyph, node.TypeGlyph, node.TypeDisc, node.TypeGlyph, node.TypeGlyph, node.TypeGlyph, node.TypeGlyph, node.TypeGlyph,
+	}
+	for i, nt := range data {
+		if head == nil {
+			t.Fatalf("list too short at pos %d", i)
+		}
+		if want, got := nt, head.Type(); want != got {
+			t.Errorf("head.Type() = %d, want %d (pos %d)", got, want, i)
+		}
+		head = head.Next()
+	}
+}
+
+// componentString returns the characters of the glyphs in the list. Each
+// discretionary contributes the list returned by disc.
+func componentString(head node.Node, disc func(*node.Disc) node.Node) string {
+	var b strings.Builder
+	for e := head; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.Glyph:
+			b.WriteString(t.Components)
+		case *node.Disc:
+			b.WriteString(componentString(disc(t), disc))
+		}
+	}
+	return b.String()
+}
+
+func TestHyphenateShaping(t *testing.T) {
+	var dummy bytes.Buffer
+	doc := document.NewDocument(&dummy)
+	face, err := doc.LoadFaceFromData(crimsonproregular.TTF, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := doc.LoadPatternFile(filepath.Join("testdata/hyph-en-us.pat.txt"), "dummylang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := &shapingInfo{font: font.NewFont(face, 10*bag.Factor)}
+	word := si.shape("ideological")
+	for e := word; e != nil; e = e.Next() {
+		if g, ok := e.(*node.Glyph); ok {
+			g.Hyphenate = true
+		}
+	}
+	word.(*node.Glyph).Attributes = node.H{"shaping": si}
+	head := node.NewGlue()
+	node.InsertAfter(head, head, word)
+	hyphenate(head, l, &hyphenationSettings{})
+
+	unbroken := func(d *node.Disc) node.Node { return d.Replace }
+	if got := componentString(head, unbroken); got != "ideological" {
+		t.Errorf("unbroken word = %q, want ideological", got)
+	}
+	// ide-o-log-i-cal: there is only one character between two
+	// hyphenation points.
+	want := []string{"ide-|ological", "ideo-|logical", "ideolog-|ical", "ideologi-|cal"}
+	var got []string
+	for e := node.Node(head); e != nil; e = e.Next() {
+		disc, ok := e.(*node.Disc)
+		if !ok {
+			continue
+		}
+		// break at disc, all other discretionaries are not broken
+		next := disc.Next()
+		disc.SetNext(nil)
+		before := componentString(head, func(d *node.Disc) node.Node {
+			if d == disc {
+				return d.Pre
+			}
+			return d.Replace
+		})
+		disc.SetNext(next)
+		after := componentString(disc.Post, unbroken) + componentString(next, unbroken)
+		got = append(got, before+"|"+after)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("breaks = %q, want %q", got, want)
+	}
+}
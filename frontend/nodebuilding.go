@@ -7,6 +7,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"unicode"
 
 	pdf "github.com/speedata/baseline-pdf"
 	"github.com/speedata/boxesandglue/backend/bag"
@@ -100,20 +101,118 @@ const (
 	FontStyleOblique
 )
 
-// TextDecorationLine sets the underline type
+// TextDecorationLine sets the underline type. The values can be combined
+// (for example TextDecorationUnderline | TextDecorationOverline).
 type TextDecorationLine int
 
 const (
 	// TextDecorationLineNone means no underline
-	TextDecorationLineNone TextDecorationLine = iota
+	TextDecorationLineNone TextDecorationLine = 0
 	// TextDecorationUnderline is a simple underlining
-	TextDecorationUnderline
+	TextDecorationUnderline TextDecorationLine = 1
 	// TextDecorationOverline has a line above
-	TextDecorationOverline
+	TextDecorationOverline TextDecorationLine = 2
 	// TextDecorationLineThrough is a strike out
-	TextDecorationLineThrough
+	TextDecorationLineThrough TextDecorationLine = 4
 )
 
+// TextDecorationStyle is the style of the line drawn by a text decoration.
+type TextDecorationStyle int
+
+const (
+	// TextDecorationStyleSolid draws a single line.
+	TextDecorationStyleSolid TextDecorationStyle = iota
+	// TextDecorationStyleDouble draws two parallel lines.
+	TextDecorationStyleDouble
+	// TextDecorationStyleDotted draws a dotted line.
+	TextDecorationStyleDotted
+	// TextDecorationStyleDashed draws a dashed line.
+	TextDecorationStyleDashed
+	// TextDecorationStyleWavy draws a wavy line.
+	TextDecorationStyleWavy
+)
+
+// Hyphens controls the hyphenation of the text.
+type Hyphens int
+
+const (
+	// HyphensAuto hyphenates words automatically and at soft hyphens.
+	HyphensAuto Hyphens = iota
+	// HyphensManual hyphenates words only at soft hyphens (U+00AD).
+	HyphensManual
+	// HyphensNone disables the hyphenation.
+	HyphensNone
+)
+
+func (h Hyphens) String() string {
+	switch h {
+	case HyphensAuto:
+		return "auto"
+	case HyphensManual:
+		return "manual"
+	case HyphensNone:
+		return "none"
+	default:
+		return "???"
+	}
+}
+
+// HyphenateLimitChars is the minimum number of characters of a word to be
+// hyphenated and the minimum number of characters before and after the
+// hyphenation point. 0 means the default of the language.
+type HyphenateLimitChars struct {
+	Word   int
+	Before int
+	After  int
+}
+
+// TextTransform changes the case of the text before shaping.
+type TextTransform int
+
+const (
+	// TextTransformNone leaves the text unchanged.
+	TextTransformNone TextTransform = iota
+	// TextTransformUppercase converts all characters to upper case.
+	TextTransformUppercase
+	// TextTransformLowercase converts all characters to lower case.
+	TextTransformLowercase
+	// TextTransformCapitalize converts the first character of each word to
+	// title case.
+	TextTransformCapitalize
+)
+
+func (tt TextTransform) String() string {
+	switch tt {
+	case TextTransformNone:
+		return "none"
+	case TextTransformUppercase:
+		return "uppercase"
+	case TextTransformLowercase:
+		return "lowercase"
+	case TextTransformCapitalize:
+		return "capitalize"
+	default:
+		return "???"
+	}
+}
+
+func (tds TextDecorationStyle) String() string {
+	switch tds {
+	case TextDecorationStyleSolid:
+		return "solid"
+	case TextDecorationStyleDouble:
+		return "double"
+	case TextDecorationStyleDotted:
+		return "dotted"
+	case TextDecorationStyleDashed:
+		return "dashed"
+	case TextDecorationStyleWavy:
+		return "wavy"
+	default:
+		return "???"
+	}
+}
+
 const (
 	// SettingDummy is a no op.
 	SettingDummy SettingType = iota
@@ -121,6 +220,10 @@ const (
 	SettingBox
 	// SettingBackgroundColor sets the background color.
 	SettingBackgroundColor
+	// SettingBaselineGrid is the distance of the lines of a baseline grid
+	// (bag.ScaledPoint). The baselines of the paragraphs are placed on
+	// multiples of this value, measured from the top of the vertical list.
+	SettingBaselineGrid
 	// SettingBorderBottomWidth sets the bottom border width.
 	SettingBorderBottomWidth
 	// SettingBorderLeftWidth sets the left border width.
@@ -153,40 +256,109 @@ const (
 	SettingBorderBottomLeftRadius
 	// SettingBorderBottomRightRadius sets the bottom right radius (x and y are the same).
 	SettingBorderBottomRightRadius
+	// SettingBoxDecorationBreak determines if the borders and the padding of an
+	// inline element are drawn at each line break (BoxDecorationBreakClone)
+	// or only at the start and the end of the element (BoxDecorationBreakSlice).
+	SettingBoxDecorationBreak
+	// SettingBreakAfter forces or avoids a page break after a block (Break).
+	SettingBreakAfter
+	// SettingBreakBefore forces or avoids a page break before a block
+	// (Break).
+	SettingBreakBefore
+	// SettingBreakInside avoids page breaks inside a block (BreakAvoid).
+	SettingBreakInside
+	// SettingClear moves a box below the preceding floating boxes (Clear).
+	SettingClear
 	// SettingColor sets a predefined color.
 	SettingColor
+	// SettingColumnCount sets the number of columns of a multi-column element
+	// (int).
+	SettingColumnCount
+	// SettingColumnGap is the distance between two columns (bag.ScaledPoint).
+	SettingColumnGap
+	// SettingColumnRule is the line between two columns (ColumnRule).
+	SettingColumnRule
+	// SettingColumnSpan lets an element in a multi-column element span all
+	// columns (bool).
+	SettingColumnSpan
 	// SettingDebug can contain debugging information
 	SettingDebug
-	// SettingFontExpansion is the amount of expansion / shrinkage allowed. Value is a float between 0 (no expansion) and 1 (100% of the glyph width).
+	// SettingFlex makes a box a flex container (FlexContainer). The items of
+	// the box are the flex items.
+	SettingFlex
+	// SettingFlexItem controls the size of a flex item (FlexItem).
+	SettingFlexItem
+	// SettingFloat places a box at the left or the right edge of the
+	// surrounding box, the following paragraphs flow around it (Float).
+	SettingFloat
+	// SettingFontExpansion is the amount of expansion / shrinkage allowed. Value is a float between 0 (no expansion) and 1 (100% of the glyph width). Fonts with expansion parameters (FontSource.Expansion) use their own limits, the value 0 disables the font expansion.
 	SettingFontExpansion
 	// SettingFontFamily selects a font family.
 	SettingFontFamily
 	// SettingFontWeight represents a font weight setting.
 	SettingFontWeight
+	// SettingGrid makes a box a grid container (GridContainer). The items of
+	// the box are the grid items.
+	SettingGrid
+	// SettingGridItem places a grid item in the grid (GridItem).
+	SettingGridItem
 	// SettingHAlign sets the horizontal alignment of the paragraph.
 	SettingHAlign
-	// SettingHangingPunctuation sets the margin protrusion.
+	// SettingHangingPunctuation lets punctuation at the start or at the end of
+	// the lines hang into the margin (HangingPunctuation).
 	SettingHangingPunctuation
 	// SettingHeight sets the height of a box if it should be vertically aligned.
 	SettingHeight
 	// SettingHyperlink defines an external hyperlink.
 	SettingHyperlink
+	// SettingHyphenateCharacter is the string which is inserted at a
+	// hyphenation point. The default is the hyphen of the font.
+	SettingHyphenateCharacter
+	// SettingHyphenateLimitChars sets the minimum length of a hyphenated word
+	// and the minimum number of characters before and after the hyphen
+	// (HyphenateLimitChars).
+	SettingHyphenateLimitChars
+	// SettingHyphenateLimitLines sets the maximum number of consecutive
+	// hyphenated lines (int). 0 means no limit.
+	SettingHyphenateLimitLines
+	// SettingHyphens controls the hyphenation (Hyphens).
+	SettingHyphens
 	// SettingIndentLeft inserts a left margin
 	SettingIndentLeft
 	// SettingIndentLeftRows determines the number of rows to be indented (positive value), or the number of rows not indented (negative values). 0 means all rows.
 	SettingIndentLeftRows
+	// SettingInitialLetter turns the text into an initial letter (drop cap)
+	// if it is the first item of a paragraph (InitialLetter).
+	SettingInitialLetter
+	// SettingInlineBlock makes the text a block which is placed inside the
+	// paragraph like a single (large) glyph (CSS display: inline-block).
+	SettingInlineBlock
+	// SettingInlineVAlign sets the vertical alignment of images and inline
+	// blocks within the line. The value is a VerticalAlignment or a
+	// bag.ScaledPoint which raises the object above the baseline.
+	SettingInlineVAlign
 	// SettingLeading determines the distance between two base lines (line height).
 	SettingLeading
+	// SettingLetterSpacing sets the additional space between the glyphs
+	// (tracking). Ligatures are disabled if the value is not 0.
+	SettingLetterSpacing
 	// SettingMarginBottom sets the bottom margin.
 	SettingMarginBottom
 	// SettingMarginLeft sets the left margin.
 	SettingMarginLeft
+	// SettingMarginProtrusion enables the margin protrusion of the glyphs
+	// according to the protrusion tables of the fonts (bool). Fonts without a
+	// protrusion table use font.DefaultProtrusion.
+	SettingMarginProtrusion
 	// SettingMarginRight sets the right margin.
 	SettingMarginRight
 	// SettingMarginTop sets the top margin.
 	SettingMarginTop
 	// SettingOpenTypeFeature allows the user to (de)select OpenType features such as ligatures.
 	SettingOpenTypeFeature
+	// SettingOrphans is the minimum number of lines of a paragraph at the
+	// bottom of a page.
+	SettingOrphans
 	// SettingPaddingBottom is the bottom padding.
 	SettingPaddingBottom
 	// SettingPaddingLeft is the left hand padding.
@@ -195,24 +367,58 @@ const (
 	SettingPaddingRight
 	// SettingPaddingTop is the top padding.
 	SettingPaddingTop
+	// SettingPage is the name of the page master of a block (CSS page).
+	SettingPage
+	// SettingPosition places a box outside of the normal flow or shifts it
+	// (Position).
+	SettingPosition
 	// SettingPrepend contains a node list which should be prepended to the list.
 	SettingPrepend
 	// SettingPreserveWhitespace makes a monospace paragraph with newlines.
 	SettingPreserveWhitespace
+	// SettingRunning removes a box from the normal flow and makes it a
+	// running element with the given name (string) for the page margin boxes.
+	SettingRunning
 	// SettingSize sets the font size.
 	SettingSize
 	// SettingStyle represents a font style such as italic or normal.
 	SettingStyle
+	// SettingStringSet assigns values to named strings for the page margin
+	// boxes ([]StringSet).
+	SettingStringSet
 	// SettingTabSizeSpaces is the amount of spaces for a tab.
 	SettingTabSizeSpaces
 	// SettingTabSize is the tab width.
 	SettingTabSize
-	// SettingTextDecorationLine sets underline
+	// SettingTextDecorationColor sets the color of the text decoration. The
+	// default is the text color.
+	SettingTextDecorationColor
+	// SettingTextDecorationLine sets underline, overline and line through.
 	SettingTextDecorationLine
+	// SettingTextDecorationSkipInk interrupts underlines where glyphs descend
+	// below the underline (bool).
+	SettingTextDecorationSkipInk
+	// SettingTextDecorationStyle sets the style (solid, double, ...) of the
+	// text decoration.
+	SettingTextDecorationStyle
+	// SettingTextDecorationThickness sets the line width of the text
+	// decoration. 0 means the default thickness of the font size.
+	SettingTextDecorationThickness
+	// SettingTextTransform changes the case of the text (uppercase,
+	// lowercase, capitalize).
+	SettingTextTransform
+	// SettingTextUnderlineOffset sets the distance between the baseline and
+	// the underline.
+	SettingTextUnderlineOffset
+	// SettingWidows is the minimum number of lines of a paragraph at the
+	// top of a page.
+	SettingWidows
 	// SettingWidth sets alternative widths for the text.
 	SettingWidth
 	// SettingVAlign sets the vertical alignment. A height should be set.
 	SettingVAlign
+	// SettingWordSpacing sets the additional space between words.
+	SettingWordSpacing
 	// SettingYOffset shifts the glyph.
 	SettingYOffset
 )
@@ -222,6 +428,8 @@ func (st SettingType) String() string {
 	switch st {
 	case SettingBackgroundColor:
 		settingName = "SettingBackgroundColor"
+	case SettingBaselineGrid:
+		settingName = "SettingBaselineGrid"
 	case SettingBorderBottomColor:
 		settingName = "SettingBorderBottomColor"
 	case SettingBorderBottomLeftRadius:
@@ -256,16 +464,44 @@ func (st SettingType) String() string {
 		settingName = "SettingBorderTopWidth"
 	case SettingBox:
 		settingName = "SettingBox"
+	case SettingBoxDecorationBreak:
+		settingName = "SettingBoxDecorationBreak"
+	case SettingBreakAfter:
+		settingName = "SettingBreakAfter"
+	case SettingBreakBefore:
+		settingName = "SettingBreakBefore"
+	case SettingBreakInside:
+		settingName = "SettingBreakInside"
+	case SettingClear:
+		settingName = "SettingClear"
 	case SettingColor:
 		settingName = "SettingColor"
+	case SettingColumnCount:
+		settingName = "SettingColumnCount"
+	case SettingColumnGap:
+		settingName = "SettingColumnGap"
+	case SettingColumnRule:
+		settingName = "SettingColumnRule"
+	case SettingColumnSpan:
+		settingName = "SettingColumnSpan"
 	case SettingDebug:
 		settingName = "SettingDebug"
+	case SettingFlex:
+		settingName = "SettingFlex"
+	case SettingFlexItem:
+		settingName = "SettingFlexItem"
+	case SettingFloat:
+		settingName = "SettingFloat"
 	case SettingFontExpansion:
 		settingName = "SettingFontExpansion"
 	case SettingFontFamily:
 		settingName = "SettingFontFamily"
 	case SettingFontWeight:
 		settingName = "SettingFontWeight"
+	case SettingGrid:
+		settingName = "SettingGrid"
+	case SettingGridItem:
+		settingName = "SettingGridItem"
 	case SettingHAlign:
 		settingName = "SettingHAlign"
 	case SettingHangingPunctuation:
@@ -274,22 +510,42 @@ func (st SettingType) String() string {
 		settingName = "SettingHeight"
 	case SettingHyperlink:
 		settingName = "SettingHyperlink"
+	case SettingHyphenateCharacter:
+		settingName = "SettingHyphenateCharacter"
+	case SettingHyphenateLimitChars:
+		settingName = "SettingHyphenateLimitChars"
+	case SettingHyphenateLimitLines:
+		settingName = "SettingHyphenateLimitLines"
+	case SettingHyphens:
+		settingName = "SettingHyphens"
 	case SettingIndentLeft:
 		settingName = "SettingIndentLeft"
 	case SettingIndentLeftRows:
 		settingName = "SettingIndentLeftRows"
+	case SettingInitialLetter:
+		settingName = "SettingInitialLetter"
+	case SettingInlineBlock:
+		settingName = "SettingInlineBlock"
+	case SettingInlineVAlign:
+		settingName = "SettingInlineVAlign"
 	case SettingLeading:
 		settingName = "SettingLeading"
+	case SettingLetterSpacing:
+		settingName = "SettingLetterSpacing"
 	case SettingMarginBottom:
 		settingName = "SettingMarginBottom"
 	case SettingMarginLeft:
 		settingName = "SettingMarginLeft"
+	case SettingMarginProtrusion:
+		settingName = "SettingMarginProtrusion"
 	case SettingMarginRight:
 		settingName = "SettingMarginRight"
 	case SettingMarginTop:
 		settingName = "SettingMarginTop"
 	case SettingOpenTypeFeature:
 		settingName = "SettingOpenTypeFeature"
+	case SettingOrphans:
+		settingName = "SettingOrphans"
 	case SettingPaddingBottom:
 		settingName = "SettingPaddingBottom"
 	case SettingPaddingLeft:
@@ -298,24 +554,48 @@ func (st SettingType) String() string {
 		settingName = "SettingPaddingRight"
 	case SettingPaddingTop:
 		settingName = "SettingPaddingTop"
+	case SettingPage:
+		settingName = "SettingPage"
+	case SettingPosition:
+		settingName = "SettingPosition"
 	case SettingPrepend:
 		settingName = "SettingPrepend"
 	case SettingPreserveWhitespace:
 		settingName = "SettingPreserveWhitespace"
+	case SettingRunning:
+		settingName = "SettingRunning"
 	case SettingSize:
 		settingName = "SettingSize"
 	case SettingStyle:
 		settingName = "SettingStyle"
+	case SettingStringSet:
+		settingName = "SettingStringSet"
 	case SettingTabSize:
 		settingName = "SettingTabSize"
 	case SettingTabSizeSpaces:
 		settingName = "SettingTabSizeSpaces"
+	case SettingTextDecorationColor:
+		settingName = "SettingTextDecorationColor"
 	case SettingTextDecorationLine:
 		settingName = "SettingTextDecorationLine"
+	case SettingTextDecorationSkipInk:
+		settingName = "SettingTextDecorationSkipInk"
+	case SettingTextDecorationStyle:
+		settingName = "SettingTextDecorationStyle"
+	case SettingTextDecorationThickness:
+		settingName = "SettingTextDecorationThickness"
+	case SettingTextTransform:
+		settingName = "SettingTextTransform"
+	case SettingTextUnderlineOffset:
+		settingName = "SettingTextUnderlineOffset"
 	case SettingVAlign:
 		settingName = "SettingVAlign"
+	case SettingWidows:
+		settingName = "SettingWidows"
 	case SettingWidth:
 		settingName = "SettingWidth"
+	case SettingWordSpacing:
+		settingName = "SettingWordSpacing"
 	case SettingYOffset:
 		settingName = "SettingYOffset"
 	default:
@@ -413,10 +693,30 @@ func debugText(ts *Text, enc *xml.Encoder) {
 			if t == 0 {
 				showSetting = false
 			}
+		case TextDecorationStyle:
+			if t == 0 {
+				showSetting = false
+			}
 		case HangingPunctuation:
 			if t == 0 {
 				showSetting = false
 			}
+		case BoxDecorationBreak:
+			if t == 0 {
+				showSetting = false
+			}
+		case TextTransform:
+			if t == 0 {
+				showSetting = false
+			}
+		case Hyphens:
+			if t == 0 {
+				showSetting = false
+			}
+		case HyphenateLimitChars:
+			if t == (HyphenateLimitChars{}) {
+				showSetting = false
+			}
 		case FontWeight:
 			if t == 0 {
 				showSetting = false
@@ -512,8 +812,11 @@ func (ts *Text) String() string {
 // Options collects the TypesettingOption for FormatParagraph.
 type Options struct {
 	Alignment      HorizontalAlignment
+	BaselineGrid   bag.ScaledPoint
+	Exclusions     ExclusionFunc
 	Fontfamily     *FontFamily
 	Fontsize       bag.ScaledPoint
+	GridOffset     bag.ScaledPoint
 	hsize          bag.ScaledPoint
 	IndentLeft     bag.ScaledPoint
 	IndentLeftRows int
@@ -554,6 +857,24 @@ func IndentLeft(size bag.ScaledPoint, rows int) TypesettingOption {
 	}
 }
 
+// BaselineGrid places the baselines of the paragraph on a grid with the
+// distance grid. The origin of the grid is offset above the top of the
+// paragraph.
+func BaselineGrid(grid, offset bag.ScaledPoint) TypesettingOption {
+	return func(p *Options) {
+		p.BaselineGrid = grid
+		p.GridOffset = offset
+	}
+}
+
+// Exclusions sets the space beside the lines which is taken by floating
+// boxes.
+func Exclusions(f ExclusionFunc) TypesettingOption {
+	return func(p *Options) {
+		p.Exclusions = f
+	}
+}
+
 // Family sets the font family for the paragraph.
 func Family(fam *FontFamily) TypesettingOption {
 	return func(p *Options) {
@@ -595,6 +916,9 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 			p.Alignment = HAlignDefault
 		}
 	}
+	if grid, ok := te.Settings[SettingBaselineGrid].(bag.ScaledPoint); ok {
+		p.BaselineGrid = grid
+	}
 	for _, opt := range opts {
 		opt(p)
 	}
@@ -630,7 +954,14 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 	var hlist, tail node.Node
 	var err error
 
-	hlist, tail, err = fe.Mknodes(te)
+	// the initial letter is formatted separately and placed in front of the
+	// paragraph after the hyphenation.
+	paragraph := te
+	initial, il := initialLetter(te)
+	if initial != nil {
+		paragraph = &Text{Settings: te.Settings, Items: te.Items[1:]}
+	}
+	hlist, tail, err = fe.mknodes(paragraph, p.hsize, p.Language, false, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -643,7 +974,15 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 		return node.Vpack(hlist), nil, nil
 	}
 
-	Hyphenate(hlist, p.Language)
+	hs := &hyphenationSettings{}
+	if lc, ok := te.Settings[SettingHyphenateLimitChars]; ok {
+		hs.limitChars, _ = lc.(HyphenateLimitChars)
+	}
+	if hc, ok := te.Settings[SettingHyphenateCharacter]; ok {
+		hs.hyphenChar, _ = hc.(string)
+	}
+	hyphenate(hlist, p.Language, hs)
+	reserveClonePadding(hlist)
 	node.AppendLineEndAfter(hlist, tail)
 
 	ls := node.NewLinebreakSettings()
@@ -651,12 +990,21 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 	ls.Indent = p.IndentLeft
 	ls.IndentRows = p.IndentLeftRows
 	ls.Tolerance = 4
+	var hangingPunctuation HangingPunctuation
+	var marginProtrusion bool
 	if hp, ok := te.Settings[SettingHangingPunctuation]; ok {
 		if hps, ok := hp.(HangingPunctuation); ok {
-			ls.HangingPunctuationEnd = hps&HangingPunctuationAllowEnd == 1
+			hangingPunctuation = hps
 		}
 	}
+	if mp, ok := te.Settings[SettingMarginProtrusion]; ok {
+		marginProtrusion, _ = mp.(bool)
+	}
+	ls.Protrusion = protrusionFunc(hangingPunctuation, marginProtrusion)
 
+	if ll, ok := te.Settings[SettingHyphenateLimitLines]; ok {
+		ls.HyphenateLimitLines, _ = ll.(int)
+	}
 	if fe, ok := te.Settings[SettingFontExpansion]; ok {
 		if fef, ok := fe.(float64); ok {
 			ls.FontExpansion = fef
@@ -672,6 +1020,16 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 	} else {
 		ls.LineHeight = p.Leading
 	}
+	ls.GridHeight = p.BaselineGrid
+	ls.GridOffset = p.GridOffset
+	if p.Exclusions != nil {
+		ls.ParagraphShape = p.Exclusions.paragraphShape(ls.LineHeight)
+	}
+	if initial != nil {
+		if hlist, err = fe.insertInitialLetter(hlist, initial, il, te.Settings, ls, p.Language); err != nil {
+			return nil, nil, err
+		}
+	}
 	if p.Alignment == HAlignLeft || p.Alignment == HAlignCenter {
 		lg := node.NewGlue()
 		lg.Stretch = bag.Factor
@@ -687,6 +1045,11 @@ func (fe *Document) FormatParagraph(te *Text, hsize bag.ScaledPoint, opts ...Typ
 		ls.LineStartGlue = lg
 	}
 	vlist, info := node.Linebreak(hlist, ls)
+	vlist = alignInlineObjects(vlist, ls.LineHeight)
+	if ls.GridHeight > 0 {
+		// the inline objects might have changed the line distances
+		vlist = node.SnapToGrid(vlist, ls)
+	}
 
 	for _, inf := range info {
 		pi.Widths = append(pi.Widths, inf.Width)
@@ -758,9 +1121,128 @@ func parseHarfbuzzFontFeatures(featurelist any) []harfbuzz.Feature {
 	return fontfeatures
 }
 
+// transformText changes the case of str according to tt. The language name
+// (such as "tr" or "de_DE") selects the language specific case mappings.
+func transformText(str string, tt TextTransform, langname string) string {
+	langname = strings.ToLower(langname)
+	if i := strings.IndexAny(langname, "_-"); i > 0 {
+		langname = langname[:i]
+	}
+	var sc unicode.SpecialCase
+	switch langname {
+	case "tr":
+		sc = unicode.TurkishCase
+	case "az":
+		sc = unicode.AzeriCase
+	}
+	switch tt {
+	case TextTransformUppercase:
+		// ß has no single upper case character
+		str = strings.ReplaceAll(str, "ß", "SS")
+		if sc != nil {
+			return strings.ToUpperSpecial(sc, str)
+		}
+		return strings.ToUpper(str)
+	case TextTransformLowercase:
+		if sc != nil {
+			return strings.ToLowerSpecial(sc, str)
+		}
+		return strings.ToLower(str)
+	case TextTransformCapitalize:
+		var b strings.Builder
+		wordstart := true
+		for _, r := range str {
+			if unicode.IsSpace(r) {
+				wordstart = true
+			} else if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				if wordstart {
+					if sc != nil {
+						r = sc.ToTitle(r)
+					} else {
+						r = unicode.ToTitle(r)
+					}
+				}
+				wordstart = false
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	return str
+}
+
 // BuildNodelistFromString returns a node list containing glyphs from the string
 // with the settings in ts.
 func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string) (node.Node, error) {
+	return fe.buildNodelistFromString(ts, str, fe.Doc.DefaultLanguage)
+}
+
+// getFont returns the font of the font family with the weight, the style and
+// the size. The font source is returned for its font features.
+func (fe *Document) getFont(fontfamily *FontFamily, fontweight FontWeight, fontstyle FontStyle, fontsize bag.ScaledPoint) (*font.Font, *FontSource, error) {
+	var fnt *font.Font
+	var face *pdf.Face
+	var fs *FontSource
+	var err error
+	if fs, err = fontfamily.GetFontSource(fontweight, fontstyle); err != nil {
+		return nil, nil, err
+	}
+	bag.Logger.Log(nil, -8, "GetFontSource", "fs", fs.Name)
+	// fs.SizeAdjust is CSS size-adjust normalized so that 0 = 100% and negative = shrinking.
+	if fs.SizeAdjust != 0 {
+		fontsize = bag.ScaledPointFromFloat(fontsize.ToPT() * (1 - fs.SizeAdjust))
+	}
+	if face, err = fe.LoadFace(fs); err != nil {
+		if fs.Name == "" {
+			bag.Logger.Error("Cannot load face", "location", fs.Location)
+		} else {
+			bag.Logger.Error("Cannot load face", "name", fs.Name)
+		}
+		return nil, nil, err
+	}
+	if fe.usedFonts[face] == nil {
+		fe.usedFonts = make(map[*pdf.Face]map[bag.ScaledPoint]*font.Font)
+	}
+	if fe.usedFonts[face][fontsize] == nil {
+		fe.usedFonts[face] = make(map[bag.ScaledPoint]*font.Font)
+	}
+
+	var found bool
+	if fnt, found = fe.usedFonts[face][fontsize]; !found {
+		fnt = fe.Doc.CreateFont(face, fontsize)
+		fnt.Protrusion = fs.Protrusion
+		fnt.Expansion = fs.Expansion
+		fe.usedFonts[face][fontsize] = fnt
+	}
+	return fnt, fs, nil
+}
+
+// settingsFont returns the font of the font family, the weight, the style and
+// the size in the settings ts.
+func (fe *Document) settingsFont(ts TypesettingSettings) (*font.Font, error) {
+	fontweight := FontWeight400
+	fontstyle := FontStyleNormal
+	fontsize := 12 * bag.Factor
+	switch t := ts[SettingFontWeight].(type) {
+	case int:
+		fontweight = FontWeight(t)
+	case FontWeight:
+		fontweight = t
+	}
+	if st, ok := ts[SettingStyle].(FontStyle); ok {
+		fontstyle = st
+	}
+	if fs, ok := ts[SettingSize].(bag.ScaledPoint); ok {
+		fontsize = fs
+	}
+	fontfamily, _ := ts[SettingFontFamily].(*FontFamily)
+	fnt, _, err := fe.getFont(fontfamily, fontweight, fontstyle, fontsize)
+	return fnt, err
+}
+
+// buildNodelistFromString is BuildNodelistFromString with the language of the
+// text which is also used for the hyphenation.
+func (fe *Document) buildNodelistFromString(ts TypesettingSettings, str string, language *lang.Lang) (node.Node, error) {
 	bag.Logger.Log(nil, -8, "Document#BuildNodelistFromString")
 	fontweight := FontWeight400
 	fontstyle := FontStyleNormal
@@ -769,13 +1251,17 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 	var col *color.Color
 	var hyperlink document.Hyperlink
 	var hasHyperlink bool
-	var hasUnderline bool
+	var decoration *textDecoration
 	fontfeatures := make([]harfbuzz.Feature, 0, len(fe.DefaultFeatures))
 	for _, f := range fe.DefaultFeatures {
 		fontfeatures = append(fontfeatures, f)
 	}
 	preserveWhitespace := false
 	yoffset := bag.ScaledPoint(0)
+	var letterspacing, wordspacing bag.ScaledPoint
+	var texttransform TextTransform
+	var hyphens Hyphens
+	var hyphenchar string
 	var settingFontFeatures []harfbuzz.Feature
 	for k, v := range ts {
 		switch k {
@@ -803,9 +1289,11 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 			hyperlink = v.(document.Hyperlink)
 			hasHyperlink = true
 		case SettingTextDecorationLine:
-			if underlineType, ok := v.(TextDecorationLine); ok && underlineType == TextDecorationUnderline {
-				hasUnderline = true
+			if decorationLine, ok := v.(TextDecorationLine); ok && decorationLine != TextDecorationLineNone {
+				decoration = &textDecoration{line: decorationLine}
 			}
+		case SettingTextDecorationColor, SettingTextDecorationSkipInk, SettingTextDecorationStyle, SettingTextDecorationThickness, SettingTextUnderlineOffset:
+			// handled below
 		case SettingFontExpansion:
 			// ignore
 		case SettingStyle:
@@ -814,7 +1302,7 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 			settingFontFeatures = parseHarfbuzzFontFeatures(v)
 		case SettingMarginTop, SettingMarginRight, SettingMarginBottom, SettingMarginLeft, SettingPaddingRight, SettingPaddingBottom, SettingPaddingTop, SettingPaddingLeft:
 			// ignore
-		case SettingHAlign, SettingLeading, SettingIndentLeft, SettingIndentLeftRows, SettingTabSize, SettingTabSizeSpaces:
+		case SettingHAlign, SettingLeading, SettingIndentLeft, SettingIndentLeftRows, SettingInitialLetter, SettingTabSize, SettingTabSizeSpaces:
 			// ignore
 		case SettingBorderBottomWidth, SettingBorderLeftWidth, SettingBorderRightWidth, SettingBorderTopWidth:
 			// ignore
@@ -824,54 +1312,46 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 			// ignore
 		case SettingBorderBottomLeftRadius, SettingBorderBottomRightRadius, SettingBorderTopLeftRadius, SettingBorderTopRightRadius:
 			// ignore
-		case SettingBackgroundColor, SettingPrepend, SettingDebug, SettingHeight, SettingVAlign, SettingHangingPunctuation:
+		case SettingBackgroundColor, SettingBaselineGrid, SettingPrepend, SettingDebug, SettingHeight, SettingVAlign, SettingHangingPunctuation, SettingMarginProtrusion:
+			// ignore
+		case SettingWidth, SettingBox, SettingBoxDecorationBreak, SettingInlineBlock, SettingInlineVAlign:
+			// ignore
+		case SettingColumnCount, SettingColumnGap, SettingColumnRule, SettingColumnSpan:
 			// ignore
-		case SettingWidth, SettingBox:
+		case SettingBreakAfter, SettingBreakBefore, SettingBreakInside, SettingClear, SettingFlex, SettingFlexItem, SettingFloat, SettingGrid, SettingGridItem, SettingOrphans, SettingPage, SettingPosition, SettingRunning, SettingStringSet, SettingWidows:
 			// ignore
 		case SettingPreserveWhitespace:
 			preserveWhitespace = v.(bool)
 		case SettingYOffset:
 			yoffset = v.(bag.ScaledPoint)
+		case SettingLetterSpacing:
+			letterspacing, _ = v.(bag.ScaledPoint)
+		case SettingWordSpacing:
+			wordspacing, _ = v.(bag.ScaledPoint)
+		case SettingTextTransform:
+			texttransform, _ = v.(TextTransform)
+		case SettingHyphens:
+			hyphens, _ = v.(Hyphens)
+		case SettingHyphenateCharacter:
+			hyphenchar, _ = v.(string)
+		case SettingHyphenateLimitChars, SettingHyphenateLimitLines:
+			// used in FormatParagraph
 		default:
 			return nil, fmt.Errorf("Unknown setting %v", k)
 		}
 	}
 
-	var fnt *font.Font
-	var face *pdf.Face
-	var fs *FontSource
-	var err error
-	if fs, err = fontfamily.GetFontSource(fontweight, fontstyle); err != nil {
+	fnt, fs, err := fe.getFont(fontfamily, fontweight, fontstyle, fontsize)
+	if err != nil {
 		return nil, err
 	}
-	bag.Logger.Log(nil, -8, "GetFontSource", "fs", fs.Name)
-	// fs.SizeAdjust is CSS size-adjust normalized so that 0 = 100% and negative = shrinking.
-	if fs.SizeAdjust != 0 {
-		fontsize = bag.ScaledPointFromFloat(fontsize.ToPT() * (1 - fs.SizeAdjust))
-	}
 	// First the font source default features should get applied, then the
 	// features from the current settings.
 	fontfeatures = append(fontfeatures, parseHarfbuzzFontFeatures(fs.FontFeatures)...)
 	fontfeatures = append(fontfeatures, settingFontFeatures...)
-	if face, err = fe.LoadFace(fs); err != nil {
-		if fs.Name == "" {
-			bag.Logger.Error("Cannot load face", "location", fs.Location)
-		} else {
-			bag.Logger.Error("Cannot load face", "name", fs.Name)
-		}
-		return nil, err
-	}
-	if fe.usedFonts[face] == nil {
-		fe.usedFonts = make(map[*pdf.Face]map[bag.ScaledPoint]*font.Font)
-	}
-	if fe.usedFonts[face][fontsize] == nil {
-		fe.usedFonts[face] = make(map[bag.ScaledPoint]*font.Font)
-	}
-
-	var found bool
-	if fnt, found = fe.usedFonts[face][fontsize]; !found {
-		fnt = fe.Doc.CreateFont(face, fontsize)
-		fe.usedFonts[face][fontsize] = fnt
+	if letterspacing != 0 {
+		// ligatures would not get the additional space
+		fontfeatures = append(fontfeatures, parseHarfbuzzFontFeatures("-liga,-clig,-dlig")...)
 	}
 
 	var head, cur node.Node
@@ -885,19 +1365,18 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 		}
 		head = hyperlinkStart
 	}
-	var underlineStart *node.StartStop
-	if hasUnderline {
-		underlineStart = node.NewStartStop()
-		node.SetAttribute(underlineStart, "underline", true)
-		node.SetAttribute(underlineStart, "underlinepos", -fontsize/6)
-		node.SetAttribute(underlineStart, "underlinelw", fontsize/20)
-		node.SetAttribute(underlineStart, "SettingTextDecorationLine", TextDecorationUnderline)
+	var decorationStart *node.StartStop
+	if decoration != nil {
+		fe.setupTextDecoration(decoration, ts, fnt, col)
+		decorationStart = node.NewStartStop()
+		node.SetAttribute(decorationStart, "textdecoration", decoration)
+		node.SetAttribute(decorationStart, "SettingTextDecorationLine", decoration.line)
 		if head != nil {
-			head = node.InsertAfter(head, head, underlineStart)
+			head = node.InsertAfter(head, head, decorationStart)
 		} else {
-			head = underlineStart
+			head = decorationStart
 		}
-		underlineStart.Action = node.ActionUserSetting
+		decorationStart.Action = node.ActionUserSetting
 	}
 	if col != nil {
 		colStart := node.NewStartStop()
@@ -912,14 +1391,38 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 	}
 	cur = head
 	var lastglue node.Node
-	atoms := fnt.Shape(str, fontfeatures)
-	for _, r := range atoms {
-		if r.IsSpace {
+	// the first glyph carries the information to shape parts of the words
+	// again at hyphenation points
+	si := &shapingInfo{font: fnt, features: fontfeatures, letterspacing: letterspacing, yoffset: yoffset}
+	if texttransform != TextTransformNone {
+		var langname string
+		if language != nil {
+			langname = language.Name
+		}
+		str = transformText(str, texttransform, langname)
+	}
+	// soft hyphens are turned into discretionaries
+	var atoms []font.Atom
+	for i, segment := range strings.Split(str, "\u00AD") {
+		if i > 0 {
+			atoms = append(atoms, font.Atom{Components: "\u00AD"})
+		}
+		atoms = append(atoms, fnt.Shape(segment, fontfeatures)...)
+	}
+	for i, r := range atoms {
+		if r.Components == "\u00AD" {
+			if hyphens != HyphensNone {
+				disc := node.NewDisc()
+				disc.Pre = hyphenNode(fnt, hyphenchar)
+				head = node.InsertAfter(head, cur, disc)
+				cur = disc
+			}
+		} else if r.IsSpace {
 			if preserveWhitespace {
 				switch r.Components {
 				case " ":
 					g := node.NewRule()
-					g.Width = fnt.Space
+					g.Width = fnt.Space + wordspacing
 					head = node.InsertAfter(head, cur, g)
 					cur = g
 					lastglue = g
@@ -969,7 +1472,7 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 
 				if lastglue == nil {
 					g := node.NewGlue()
-					g.Width = fnt.Space
+					g.Width = fnt.Space + wordspacing
 					g.Stretch = fnt.SpaceStretch
 					g.Shrink = fnt.SpaceShrink
 					head = node.InsertAfter(head, cur, g)
@@ -979,7 +1482,7 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 			}
 		} else {
 			n := node.NewGlyph()
-			n.Hyphenate = r.Hyphenate
+			n.Hyphenate = r.Hyphenate && hyphens == HyphensAuto
 			n.Codepoint = r.Codepoint
 			n.Components = r.Components
 			n.Font = fnt
@@ -987,16 +1490,27 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 			n.Height = r.Height
 			n.Depth = r.Depth
 			n.YOffset = yoffset
+			if si != nil {
+				node.SetAttribute(n, "shaping", si)
+				si = nil
+			}
 			head = node.InsertAfter(head, cur, n)
 			cur = n
 			lastglue = nil
 
-			if r.Kernafter != 0 {
+			if kern := r.Kernafter + letterspacing; kern != 0 {
 				k := node.NewKern()
-				k.Kern = r.Kernafter
+				k.Kern = kern
 				head = node.InsertAfter(head, cur, k)
 				cur = k
 			}
+			// A hyphen inside a word such as "E-Mail" allows a line break
+			// without adding another hyphen.
+			if isExplicitHyphen(r.Components) && !preserveWhitespace && i > 0 && !atoms[i-1].IsSpace && i < len(atoms)-1 && !atoms[i+1].IsSpace {
+				disc := node.NewDisc()
+				head = node.InsertAfter(head, cur, disc)
+				cur = disc
+			}
 		}
 	}
 	if col != nil {
@@ -1008,12 +1522,12 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 		node.InsertAfter(head, cur, stop)
 		cur = stop
 	}
-	if hasUnderline {
-		underlineStop := node.NewStartStop()
-		underlineStop.StartNode = underlineStart
-		node.SetAttribute(underlineStop, "underline", false)
-		head = node.InsertAfter(head, cur, underlineStop)
-		cur = underlineStop
+	if decoration != nil {
+		decorationStop := node.NewStartStop()
+		decorationStop.StartNode = decorationStart
+		node.SetAttribute(decorationStop, "textdecoration", false)
+		head = node.InsertAfter(head, cur, decorationStop)
+		cur = decorationStop
 	}
 	if hasHyperlink {
 		hyperlinkStop = node.NewStartStop()
@@ -1029,6 +1543,15 @@ func (fe *Document) BuildNodelistFromString(ts TypesettingSettings, str string)
 // width. The returned head and the tail are the beginning and the end of the
 // node list.
 func (fe *Document) Mknodes(ts *Text) (head node.Node, tail node.Node, err error) {
+	return fe.mknodes(ts, 0, fe.Doc.DefaultLanguage, false, nil)
+}
+
+// mknodes is Mknodes with the width of the paragraph (hsize) which is the
+// maximum width for inline blocks and the language of the paragraph. hsize 0
+// means no restriction. If inlineBox is true, ts is an inline element which
+// gets its right padding from wrapInlineBox. The text decorations of the
+// ancestors (outer) are drawn around each text in ts.
+func (fe *Document) mknodes(ts *Text, hsize bag.ScaledPoint, language *lang.Lang, inlineBox bool, outer []TypesettingSettings) (head node.Node, tail node.Node, err error) {
 	bag.Logger.Log(nil, -8, "Document#Mknodes")
 	if len(ts.Items) == 0 {
 		return nil, nil, nil
@@ -1038,6 +1561,9 @@ func (fe *Document) Mknodes(ts *Text) (head node.Node, tail node.Node, err error
 	for k, v := range ts.Settings {
 		newSettings[k] = v
 	}
+	// The decorations are propagated to the children and drawn by decorate.
+	decorations := textDecorations(outer, newSettings)
+	delete(newSettings, SettingTextDecorationLine)
 	var hyperlinkStartNode *node.StartStop
 	var hyperlinkDest string
 	for _, itm := range ts.Items {
@@ -1052,13 +1578,13 @@ func (fe *Document) Mknodes(ts *Text) (head node.Node, tail node.Node, err error
 				tail = endHL
 			}
 
-			nl, err = fe.BuildNodelistFromString(newSettings, t)
+			nl, err = fe.buildNodelistFromString(newSettings, t, language)
 			if err != nil {
 				return nil, nil, err
 			}
 
 			if nl != nil {
-				if pr, ok := ts.Settings[SettingPaddingRight]; ok {
+				if pr, ok := ts.Settings[SettingPaddingRight]; ok && !inlineBox {
 					paddingRight := pr.(bag.ScaledPoint)
 					if paddingRight > 0 {
 						g := node.NewGlue()
@@ -1067,6 +1593,9 @@ func (fe *Document) Mknodes(ts *Text) (head node.Node, tail node.Node, err error
 						node.InsertAfter(nl, node.Tail(nl), g)
 					}
 				}
+				if nl, err = fe.decorate(decorations, newSettings, nl); err != nil {
+					return nil, nil, err
+				}
 				head = node.InsertAfter(head, tail, nl)
 				tail = node.Tail(nl)
 			}
@@ -1093,21 +1622,41 @@ func (fe *Document) Mknodes(ts *Text) (head node.Node, tail node.Node, err error
 			}
 			// copy current settings to the child if not already set.
 			for k, v := range newSettings {
+				if isBoxSetting(k) {
+					continue
+				}
 				if _, found := t.Settings[k]; !found {
 					t.Settings[k] = v
 				}
 			}
 			// we don't want to inherit hyperlinks
 			delete(t.Settings, SettingHyperlink)
-			nl, end, err = fe.Mknodes(t)
+			if ib, ok := t.Settings[SettingInlineBlock]; ok && ib.(bool) {
+				ibl, err := fe.buildInlineBlock(t, hsize)
+				if err != nil {
+					return nil, nil, err
+				}
+				head = node.InsertAfter(head, tail, ibl)
+				tail = ibl
+				continue
+			}
+			nl, end, err = fe.mknodes(t, hsize, language, true, decorations)
 			if err != nil {
 				return nil, nil, err
 			}
 			if nl != nil {
+				nl, end = fe.wrapInlineBox(t.Settings, nl, end)
 				head = node.InsertAfter(head, tail, nl)
 				tail = end
 			}
 		case node.Node:
+			// the node could be part of a previous formatting attempt
+			t.SetPrev(nil)
+			t.SetNext(nil)
+			// vlists are always wrapped, so they sit on the baseline.
+			if va, ok := newSettings[SettingInlineVAlign]; ok || t.Type() == node.TypeVList {
+				t = fe.inlineObject(t, va, newSettings)
+			}
 			head = node.InsertAfter(head, tail, t)
 			tail = t
 		case *Table:
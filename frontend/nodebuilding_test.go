@@ -0,0 +1,61 @@
+package frontend
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/lang"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/fonts/crimsonproregular"
+)
+
+func TestTransformText(t *testing.T) {
+	testdata := []struct {
+		str      string
+		tt       TextTransform
+		langname string
+		expected string
+	}{
+		{"hello world", TextTransformNone, "en", "hello world"},
+		{"hello world", TextTransformUppercase, "en", "HELLO WORLD"},
+		{"Straße", TextTransformUppercase, "de", "STRASSE"},
+		{"istanbul", TextTransformUppercase, "tr", "İSTANBUL"},
+		{"istanbul", TextTransformUppercase, "en_US", "ISTANBUL"},
+		{"HELLO World", TextTransformLowercase, "en", "hello world"},
+		{"DİYARBAKIR", TextTransformLowercase, "tr_TR", "diyarbakır"},
+		{"hello (big) world", TextTransformCapitalize, "en", "Hello (Big) World"},
+		{"izmir", TextTransformCapitalize, "tr", "İzmir"},
+	}
+	for _, td := range testdata {
+		if got := transformText(td.str, td.tt, td.langname); got != td.expected {
+			t.Errorf("transformText(%q, %s, %q) = %q, want %q", td.str, td.tt, td.langname, got, td.expected)
+		}
+	}
+}
+
+func TestTextTransformLanguage(t *testing.T) {
+	var buf bytes.Buffer
+	fe, err := NewForWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff := fe.NewFontFamily("text")
+	if err = ff.AddMember(&FontSource{Name: "crimson", Data: crimsonproregular.TTF}, FontWeight400, FontStyleNormal); err != nil {
+		t.Fatal(err)
+	}
+	fe.Doc.DefaultLanguage = &lang.Lang{Name: "en"}
+	settings := TypesettingSettings{
+		SettingFontFamily:    ff,
+		SettingSize:          10 * bag.Factor,
+		SettingTextTransform: TextTransformUppercase,
+	}
+	// the language of the paragraph wins over the default language
+	nl, err := fe.buildNodelistFromString(settings, "i", &lang.Lang{Name: "tr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := componentString(nl, func(d *node.Disc) node.Node { return d.Replace }); got != "İ" {
+		t.Errorf("uppercase i in Turkish = %q, want İ", got)
+	}
+}
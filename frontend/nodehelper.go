@@ -69,3 +69,74 @@ func getMinWidthHlistWithoutStretch(hl *node.HList) bag.ScaledPoint {
 	wd, _, _ := node.Dimensions(start, stop, node.Horizontal)
 	return wd
 }
+
+// maxContentWidth returns the width of the widest line in vl without the
+// width of the stretchable (infinite) glue.
+func maxContentWidth(vl *node.VList) bag.ScaledPoint {
+	maxWd := bag.ScaledPoint(0)
+	for e := vl.List; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.VList:
+			maxWd = bag.Max(maxWd, maxContentWidth(t)+t.ShiftX)
+		case *node.HList:
+			maxWd = bag.Max(maxWd, hlistContentWidth(t))
+		}
+	}
+	return maxWd
+}
+
+func hlistContentWidth(hl *node.HList) bag.ScaledPoint {
+	wd := bag.ScaledPoint(0)
+	for e := hl.List; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.Glue:
+			if t.StretchOrder == 0 {
+				wd += t.Width
+			}
+		case *node.HList:
+			wd += hlistContentWidth(t)
+		default:
+			w, _, _ := node.Dimensions(e, e, node.Horizontal)
+			wd += w
+		}
+	}
+	return wd
+}
+
+// maxBoxWidth returns the width of the widest line of all boxes in vl
+// including their horizontal shift, not counting the stretchable glue at the
+// end of the lines.
+func maxBoxWidth(vl *node.VList) bag.ScaledPoint {
+	maxWd := bag.ScaledPoint(0)
+	found := false
+	for e := vl.List; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.VList:
+			maxWd = bag.Max(maxWd, maxBoxWidth(t)+t.ShiftX)
+			found = true
+		case *node.HList:
+			maxWd = bag.Max(maxWd, getMaxWidthHlistWithoutStretch(t))
+			found = true
+		}
+	}
+	if !found {
+		return vl.Width
+	}
+	return maxWd
+}
+
+// minBoxWidth returns the width of the widest line of all boxes in vl
+// including their horizontal shift, not counting the stretchable glue at the
+// end of the lines. vl should be formatted with the smallest possible width.
+func minBoxWidth(vl *node.VList) bag.ScaledPoint {
+	minWd := bag.ScaledPoint(0)
+	for e := vl.List; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.VList:
+			minWd = bag.Max(minWd, minBoxWidth(t)+t.ShiftX)
+		case *node.HList:
+			minWd = bag.Max(minWd, getMinWidthHlistWithoutStretch(t))
+		}
+	}
+	return minWd
+}
@@ -0,0 +1,205 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// Break is the type of a page break before or after a block (CSS
+// break-before and break-after) or inside a block (break-inside, only
+// BreakAuto and BreakAvoid).
+type Break uint
+
+const (
+	// BreakAuto does not force a page break.
+	BreakAuto Break = iota
+	// BreakPage forces a page break.
+	BreakPage
+	// BreakLeft forces one or two page breaks, so the next page is a left
+	// page.
+	BreakLeft
+	// BreakRight forces one or two page breaks, so the next page is a right
+	// page.
+	BreakRight
+	// BreakAvoid avoids a page break.
+	BreakAvoid
+)
+
+// Forced returns true if the break is a forced page break.
+func (b Break) Forced() bool {
+	return b == BreakPage || b == BreakLeft || b == BreakRight
+}
+
+func (b Break) String() string {
+	switch b {
+	case BreakPage:
+		return "page"
+	case BreakLeft:
+		return "left"
+	case BreakRight:
+		return "right"
+	case BreakAvoid:
+		return "avoid"
+	}
+	return "auto"
+}
+
+// pageName returns the name of the page master of te (CSS page) or the
+// empty string for the unnamed page.
+func pageName(te *Text) string {
+	name, _ := te.Settings[SettingPage].(string)
+	return name
+}
+
+// pageAttributes adds the page master name ("page"), the page break before
+// ("pagebreak") and inside te ("breakinside") to the attributes of its start
+// node.
+func pageAttributes(te *Text, attributes node.H) {
+	if name, ok := te.Settings[SettingPage].(string); ok {
+		attributes["page"] = name
+	}
+	if br, ok := te.Settings[SettingBreakBefore].(Break); ok && br != BreakAuto {
+		attributes["pagebreak"] = br
+	}
+	if br, ok := te.Settings[SettingBreakInside].(Break); ok && br != BreakAuto {
+		attributes["breakinside"] = br
+	}
+}
+
+// breakAfterAttributes adds the page break after te ("breakafter") to the
+// attributes of its stop node.
+func breakAfterAttributes(te *Text, attributes node.H) {
+	if br, ok := te.Settings[SettingBreakAfter].(Break); ok && br != BreakAuto {
+		attributes["breakafter"] = br
+	}
+}
+
+// lineAttributes adds the minimum number of lines of a paragraph at the
+// bottom ("orphans") and at the top of a page ("widows") to the attributes
+// of the paragraph's vertical list.
+func lineAttributes(te *Text, attributes node.H) {
+	if n, ok := te.Settings[SettingOrphans].(int); ok {
+		attributes["orphans"] = n
+	}
+	if n, ok := te.Settings[SettingWidows].(int); ok {
+		attributes["widows"] = n
+	}
+}
+
+// paragraphLines returns the number of lines of the paragraph vl or 0 if vl
+// is not a formatted paragraph.
+func paragraphLines(vl *node.VList) int {
+	if _, ok := vl.Attributes["table"]; ok || !isLineList(vl) {
+		return 0
+	}
+	lines := 0
+	for e := vl.List; e != nil; e = e.Next() {
+		if _, ok := e.(*node.HList); ok {
+			lines++
+		}
+	}
+	return lines
+}
+
+// LinesHeight returns the height of the first n lines of the paragraph vl
+// including the space between them. For a table (BuildTable) it returns the
+// height of the smallest part of the table. If vl is something else or if it
+// has no more than n lines, LinesHeight returns the height of vl.
+func LinesHeight(vl *node.VList, n int) bag.ScaledPoint {
+	if tr, ok := vl.Attributes["table"].(*tableRows); ok {
+		return bag.Min(tr.minHeight(), vl.Height+vl.Depth)
+	}
+	if paragraphLines(vl) <= n {
+		return vl.Height + vl.Depth
+	}
+	var sum bag.ScaledPoint
+	for e := vl.List; e != nil; e = e.Next() {
+		switch t := e.(type) {
+		case *node.HList:
+			sum += t.Height + t.Depth
+			if n--; n == 0 {
+				return sum
+			}
+		case *node.Glue:
+			sum += t.Width
+		}
+	}
+	return sum
+}
+
+// SplitParagraph breaks the paragraph vl between two lines so that the first
+// part is not higher than height, has at least orphans lines and leaves at
+// least widows lines for the second part. It returns nil and vl if vl is not
+// a formatted paragraph or if there is no such break. The list of vl is
+// changed if the paragraph is split.
+func SplitParagraph(vl *node.VList, height bag.ScaledPoint, orphans, widows int) (*node.VList, *node.VList) {
+	lines := paragraphLines(vl)
+	if orphans < 1 {
+		orphans = 1
+	}
+	if widows < 1 {
+		widows = 1
+	}
+	// the last line of the first part
+	var last node.Node
+	var sum bag.ScaledPoint
+	count := 0
+fill:
+	for e := vl.List; e != nil && count < lines-widows; e = e.Next() {
+		switch t := e.(type) {
+		case *node.HList:
+			if sum += t.Height + t.Depth; sum > height {
+				break fill
+			}
+			if count++; count >= orphans {
+				last = e
+			}
+		case *node.Glue:
+			sum += t.Width
+		}
+	}
+	if last == nil {
+		return nil, vl
+	}
+	rest := last.Next()
+	for rest != nil {
+		if _, ok := rest.(*node.Glue); !ok {
+			break
+		}
+		rest = rest.Next()
+	}
+	last.SetNext(nil)
+	rest.SetPrev(nil)
+	first := node.Vpack(vl.List)
+	second := node.Vpack(rest)
+	first.Attributes = partAttributes(vl, first)
+	second.Attributes = partAttributes(vl, second)
+	return first, second
+}
+
+// partAttributes returns a copy of the attributes of vl for a part of vl with
+// the height of the part.
+func partAttributes(vl, part *node.VList) node.H {
+	attributes := node.H{}
+	for k, v := range vl.Attributes {
+		attributes[k] = v
+	}
+	attributes["height"] = part.Height + part.Depth
+	return attributes
+}
+
+// pageArea returns the width and the horizontal position of the child of
+// parent. If the child has another page master than its parent, the width
+// and the position are adjusted to the page area of the child's page master.
+func (fe *Document) pageArea(parent, child *Text, wd, x bag.ScaledPoint) (bag.ScaledPoint, bag.ScaledPoint) {
+	if fe.pageAreaCallback == nil {
+		return wd, x
+	}
+	name, ok := child.Settings[SettingPage].(string)
+	if !ok || name == pageName(parent) {
+		return wd, x
+	}
+	px, pw := fe.pageAreaCallback(pageName(parent))
+	cx, cw := fe.pageAreaCallback(name)
+	return wd + cw - pw, x + cx - px
+}
@@ -0,0 +1,81 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// paragraph returns a vertical list with n lines (10pt high) and 2pt
+// lineskip.
+func paragraph(n int) *node.VList {
+	var head, cur node.Node
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			g := node.NewGlue()
+			g.Width = 2 * bag.Factor
+			head = node.InsertAfter(head, cur, g)
+			cur = g
+		}
+		hl := node.NewHList()
+		hl.Width = 50 * bag.Factor
+		hl.Height = 10 * bag.Factor
+		head = node.InsertAfter(head, cur, hl)
+		cur = hl
+	}
+	vl := node.Vpack(head)
+	vl.Attributes = node.H{"x": bag.ScaledPoint(0), "height": vl.Height + vl.Depth}
+	return vl
+}
+
+func TestSplitParagraph(t *testing.T) {
+	testdata := []struct {
+		lines   int
+		height  bag.ScaledPoint
+		orphans int
+		widows  int
+		want    int
+	}{
+		{6, 40 * bag.Factor, 2, 2, 3},
+		{6, 40 * bag.Factor, 2, 4, 2},
+		{6, 40 * bag.Factor, 4, 2, 0},
+		{6, 15 * bag.Factor, 1, 1, 1},
+		{3, 40 * bag.Factor, 2, 2, 0},
+	}
+	for _, td := range testdata {
+		vl := paragraph(td.lines)
+		first, rest := SplitParagraph(vl, td.height, td.orphans, td.widows)
+		if td.want == 0 {
+			if first != nil || rest != vl {
+				t.Errorf("SplitParagraph(%d lines, %s, %d, %d) splits, want no split", td.lines, td.height, td.orphans, td.widows)
+			}
+			continue
+		}
+		if first == nil {
+			t.Errorf("SplitParagraph(%d lines, %s, %d, %d) does not split, want %d lines", td.lines, td.height, td.orphans, td.widows, td.want)
+			continue
+		}
+		if got := paragraphLines(first); got != td.want {
+			t.Errorf("SplitParagraph(%d lines, %s, %d, %d) = %d lines, want %d", td.lines, td.height, td.orphans, td.widows, got, td.want)
+		}
+		if got := paragraphLines(rest); got != td.lines-td.want {
+			t.Errorf("rest = %d lines, want %d", got, td.lines-td.want)
+		}
+		if _, ok := rest.List.(*node.HList); !ok {
+			t.Errorf("rest starts with %T, want a line", rest.List)
+		}
+		if got, want := first.Attributes["height"], first.Height+first.Depth; got != want {
+			t.Errorf("height attribute = %v, want %s", got, want)
+		}
+	}
+}
+
+func TestLinesHeight(t *testing.T) {
+	if got, want := LinesHeight(paragraph(6), 2), 22*bag.Factor; got != want {
+		t.Errorf("LinesHeight(6 lines, 2) = %s, want %s", got, want)
+	}
+	if got, want := LinesHeight(paragraph(1), 2), 10*bag.Factor; got != want {
+		t.Errorf("LinesHeight(1 line, 2) = %s, want %s", got, want)
+	}
+}
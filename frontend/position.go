@@ -0,0 +1,194 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// PositionScheme determines how a box is positioned (CSS position).
+type PositionScheme uint
+
+const (
+	// PositionStatic is a box in the normal flow.
+	PositionStatic PositionScheme = iota
+	// PositionRelative shifts the box by the offsets, the box takes the space
+	// in the normal flow.
+	PositionRelative
+	// PositionAbsolute removes the box from the normal flow and places it
+	// relative to the nearest positioned ancestor or to the page.
+	PositionAbsolute
+	// PositionFixed removes the box from the normal flow and places it
+	// relative to the page on every page.
+	PositionFixed
+)
+
+// Position is the value of SettingPosition. The offsets are nil (auto), a
+// bag.ScaledPoint or a percentage string of the containing block.
+type Position struct {
+	Scheme PositionScheme
+	Top    any
+	Right  any
+	Bottom any
+	Left   any
+}
+
+// positioned returns true if a box with this position is the containing block
+// of its absolutely positioned descendants.
+func (p Position) positioned() bool {
+	return p.Scheme != PositionStatic
+}
+
+// outOfFlow returns true if the box is not in the normal flow.
+func (p Position) outOfFlow() bool {
+	return p.Scheme == PositionAbsolute || p.Scheme == PositionFixed
+}
+
+// offset returns the length of the offset v and true if it is not auto.
+func offset(v any, base bag.ScaledPoint) (bag.ScaledPoint, bool) {
+	if v == nil {
+		return 0, false
+	}
+	if str, ok := v.(string); ok && (str == "" || str == "auto") {
+		return 0, false
+	}
+	wd, err := parseInlineBlockWidth(v, base)
+	if err != nil {
+		bag.Logger.Warn("cannot parse offset", "offset", v)
+		return 0, false
+	}
+	return wd, true
+}
+
+// PositionedBox is a box which is placed outside of the normal flow relative
+// to the page. In the page box of BuildVlistInternal it is the "position"
+// attribute of a StartStop node. Fixed boxes are placed on every page.
+type PositionedBox struct {
+	Text     *Text
+	Position Position
+}
+
+// BuildPositioned formats the positioned box in a containing block with the
+// given width and height. It returns the box as a vertical list and the
+// position of its top left corner relative to the top left corner of the
+// containing block.
+func (fe *Document) BuildPositioned(pb *PositionedBox, width, height bag.ScaledPoint) (*node.VList, bag.ScaledPoint, bag.ScaledPoint, error) {
+	te := pb.Text
+	hv := SettingsToValues(te.Settings)
+	extra := hv.MarginLeft + hv.MarginRight + hv.BorderLeftWidth + hv.BorderRightWidth + hv.PaddingLeft + hv.PaddingRight
+	left, hasLeft := offset(pb.Position.Left, width)
+	right, hasRight := offset(pb.Position.Right, width)
+	top, hasTop := offset(pb.Position.Top, height)
+	bottom, hasBottom := offset(pb.Position.Bottom, height)
+
+	var wd bag.ScaledPoint
+	var err error
+	if _, ok := te.Settings[SettingWidth]; !ok && hasLeft && hasRight {
+		wd = width - left - right - extra
+	} else if wd, err = fe.outOfFlowWidth(te, width); err != nil {
+		return nil, 0, 0, err
+	}
+	info, err := fe.buildVlist(te, wd+extra, 0, hv.MarginTop+hv.PaddingTop+hv.BorderTopWidth, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	vl := fe.boxToVList(info)
+	vl.Attributes = node.H{"origin": "positioned box"}
+	var x, y bag.ScaledPoint
+	if hasLeft {
+		x = left
+	} else if hasRight {
+		x = width - right - wd - extra
+	}
+	if hasTop {
+		y = top
+	} else if hasBottom {
+		y = height - bottom - vl.Height - vl.Depth - info.marginBottom
+	}
+	return vl, x, y, nil
+}
+
+// placeAbsolute places the absolutely positioned boxes in the padding box of
+// te. The padding box is at x (left edge) and has the given width and height.
+// It returns vertical lists which do not take vertical space and are placed
+// at the top of the content of te.
+func (fe *Document) placeAbsolute(boxes []*PositionedBox, hv HTMLValues, x, width, height bag.ScaledPoint) ([]node.Node, error) {
+	var ret []node.Node
+	for _, pb := range boxes {
+		vl, bx, by, err := fe.BuildPositioned(pb, width, height)
+		if err != nil {
+			return nil, err
+		}
+		// the content starts below the top padding
+		k := node.NewKern()
+		k.Kern = by - hv.PaddingTop
+		node.InsertAfter(k, k, vl)
+		wrap := node.Vpack(k)
+		wrap.Height = 0
+		wrap.Depth = 0
+		wrap.Attributes = node.H{"height": bag.ScaledPoint(0), "x": x + bx, "origin": "positioned box"}
+		ret = append(ret, wrap)
+	}
+	return ret, nil
+}
+
+// containPositioned places the absolutely positioned descendants collected in
+// ret.positioned if te is their containing block. The content box of te is at
+// contentX with the width hsize and the height. Fixed boxes and the boxes of
+// an unpositioned te stay in ret.positioned for the ancestors.
+func (fe *Document) containPositioned(te *Text, ret *VlistInfo, hv HTMLValues, contentX, hsize, height bag.ScaledPoint) error {
+	if pos, _ := te.Settings[SettingPosition].(Position); !pos.positioned() {
+		return nil
+	}
+	var absolute, fixed []*PositionedBox
+	for _, pb := range ret.positioned {
+		if pb.Position.Scheme == PositionFixed {
+			fixed = append(fixed, pb)
+		} else {
+			absolute = append(absolute, pb)
+		}
+	}
+	ret.positioned = fixed
+	boxes, err := fe.placeAbsolute(absolute, hv, contentX-hv.PaddingLeft, hsize+hv.PaddingLeft+hv.PaddingRight, height+hv.PaddingTop+hv.PaddingBottom)
+	if err != nil {
+		return err
+	}
+	ret.Pagebox = append(boxes, ret.Pagebox...)
+	return nil
+}
+
+// shiftRelative shifts the page box entries of a relatively positioned box
+// (from its start node to its stop node) by the offsets of the position. The
+// box keeps its space in the normal flow: the start node moves the contents
+// down by the vertical offset and the stop node moves back up.
+func shiftRelative(pagebox []node.Node, pos Position, cbWidth bag.ScaledPoint) {
+	var dx, dy bag.ScaledPoint
+	if left, ok := offset(pos.Left, cbWidth); ok {
+		dx = left
+	} else if right, ok := offset(pos.Right, cbWidth); ok {
+		dx = -right
+	}
+	if top, ok := offset(pos.Top, 0); ok {
+		dy = top
+	} else if bottom, ok := offset(pos.Bottom, 0); ok {
+		dy = -bottom
+	}
+	for _, n := range pagebox {
+		var attr node.H
+		switch t := n.(type) {
+		case *node.StartStop:
+			if cs, ok := t.Attributes["columns"].(*ColumnSet); ok {
+				cs.X += dx
+			}
+			attr = t.Attributes
+		case *node.VList:
+			attr = t.Attributes
+		}
+		if x, ok := attr["x"].(bag.ScaledPoint); ok {
+			attr["x"] = x + dx
+		}
+	}
+	start := pagebox[0].(*node.StartStop)
+	start.Attributes["shiftDown"] = start.Attributes["shiftDown"].(bag.ScaledPoint) + dy
+	stop := pagebox[len(pagebox)-1].(*node.StartStop)
+	stop.Attributes["shiftDown"] = stop.Attributes["shiftDown"].(bag.ScaledPoint) - dy
+}
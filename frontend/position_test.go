@@ -0,0 +1,85 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+func TestOffset(t *testing.T) {
+	testdata := []struct {
+		v    any
+		want bag.ScaledPoint
+		ok   bool
+	}{
+		{nil, 0, false},
+		{"auto", 0, false},
+		{12 * bag.Factor, 12 * bag.Factor, true},
+		{"25%", 50 * bag.Factor, true},
+		{"3pt", 3 * bag.Factor, true},
+	}
+	for _, td := range testdata {
+		got, ok := offset(td.v, 200*bag.Factor)
+		if got != td.want || ok != td.ok {
+			t.Errorf("offset(%v) = %s, %t, want %s, %t", td.v, got, ok, td.want, td.ok)
+		}
+	}
+}
+
+func TestShiftRelative(t *testing.T) {
+	vl := node.NewVList()
+	vl.Attributes = node.H{"x": 10 * bag.Factor, "height": 20 * bag.Factor}
+	start := node.NewStartStop()
+	start.Attributes = node.H{"shiftDown": 2 * bag.Factor, "x": 10 * bag.Factor, "pagebreak": BreakPage}
+	stop := node.NewStartStop()
+	stop.StartNode = start
+	stop.Attributes = node.H{"shiftDown": 3 * bag.Factor, "breakafter": BreakPage}
+	pagebox := []node.Node{start, vl, stop}
+
+	pos := Position{Scheme: PositionRelative, Top: 5 * bag.Factor, Right: "10%"}
+	shiftRelative(pagebox, pos, 100*bag.Factor)
+	if got, want := start.Attributes["x"], 0*bag.Factor; got != want {
+		t.Errorf("start x = %v, want %s", got, want)
+	}
+	if got, want := vl.Attributes["x"], 0*bag.Factor; got != want {
+		t.Errorf("x = %v, want %s", got, want)
+	}
+	if got, want := start.Attributes["shiftDown"], 7*bag.Factor; got != want {
+		t.Errorf("start shift down = %v, want %s", got, want)
+	}
+	// the box takes the unshifted space in the flow
+	if got, want := stop.Attributes["shiftDown"], -2*bag.Factor; got != want {
+		t.Errorf("stop shift down = %v, want %s", got, want)
+	}
+	if start.Attributes["pagebreak"] != BreakPage || stop.Attributes["breakafter"] != BreakPage {
+		t.Error("the page break attributes are lost")
+	}
+}
+
+func TestAbsolutePositionInFlow(t *testing.T) {
+	fe := &Document{}
+	te := NewText()
+	te.Settings[SettingBox] = true
+	first := NewText()
+	first.Settings[SettingBox] = true
+	abs := NewText()
+	abs.Settings[SettingPosition] = Position{Scheme: PositionAbsolute, Top: bag.ScaledPoint(0)}
+	te.Items = append(te.Items, first, abs)
+
+	info, err := fe.BuildVlistInternal(te, 100*bag.Factor, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the position node follows the first box
+	if got, want := len(info.Pagebox), 3; got != want {
+		t.Fatalf("len(Pagebox) = %d, want %d", got, want)
+	}
+	start, ok := info.Pagebox[2].(*node.StartStop)
+	if !ok {
+		t.Fatalf("Pagebox[2] = %T, want *node.StartStop", info.Pagebox[2])
+	}
+	if pb, ok := start.Attributes["position"].(*PositionedBox); !ok || pb.Text != abs {
+		t.Errorf("Pagebox[2] is not the position node of the absolute box")
+	}
+}
@@ -0,0 +1,66 @@
+package frontend
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/font"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// isHangingStop returns true for the stops and commas which hang at the end
+// of a line with hanging-punctuation: allow-end or force-end.
+func isHangingStop(r rune) bool {
+	switch r {
+	case ',', '.', '،', '۔', '、', '。', '﹐', '﹑', '﹒', '，', '．', '｡', '､':
+		return true
+	}
+	return false
+}
+
+// isHangingQuote returns true for the brackets and quotes which hang at the
+// start of the first line (opening is true) or at the end of the last line.
+func isHangingQuote(r rune, opening bool) bool {
+	if r == '"' || r == '\'' || unicode.In(r, unicode.Pi, unicode.Pf) {
+		return true
+	}
+	if opening {
+		return unicode.Is(unicode.Ps, r)
+	}
+	return unicode.Is(unicode.Pe, r)
+}
+
+// protrusionFunc returns the function which determines the amount of margin
+// protrusion of a glyph for the line breaking. Hanging punctuation hangs
+// completely, other glyphs protrude according to the protrusion table of the
+// font if marginProtrusion is true.
+func protrusionFunc(hp HangingPunctuation, marginProtrusion bool) node.ProtrusionFunc {
+	if hp == 0 && !marginProtrusion {
+		return nil
+	}
+	return func(g *node.Glyph, start, firstLine, lastLine bool) bag.ScaledPoint {
+		var r rune
+		if start {
+			r, _ = utf8.DecodeRuneInString(g.Components)
+		} else {
+			r, _ = utf8.DecodeLastRuneInString(g.Components)
+		}
+		switch {
+		case start && firstLine && hp&HangingPunctuationFirst != 0 && isHangingQuote(r, true):
+			return g.Width
+		case !start && lastLine && hp&HangingPunctuationLast != 0 && isHangingQuote(r, false):
+			return g.Width
+		case !start && hp&(HangingPunctuationAllowEnd|HangingPunctuationForceEnd) != 0 && isHangingStop(r):
+			return g.Width
+		}
+		if !marginProtrusion {
+			return 0
+		}
+		table := font.DefaultProtrusion
+		if g.Font != nil && g.Font.Protrusion != nil {
+			table = g.Font.Protrusion
+		}
+		return node.ProtrusionFromTable(g, table, start)
+	}
+}
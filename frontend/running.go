@@ -0,0 +1,44 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// StringSet assigns the value to the named string Name (CSS string-set). The
+// page margin boxes show the named strings of their page.
+type StringSet struct {
+	Name  string
+	Value string
+}
+
+// RunningElement is a box which is removed from the normal flow (CSS
+// position: running(name)). It is shown in the page margin boxes of the page
+// where it would have been placed. In the page box of BuildVlistInternal it is
+// the "running" attribute of a StartStop node.
+type RunningElement struct {
+	Name string
+	Text *Text
+}
+
+// stringSetNode returns a StartStop node with the "stringset" attribute
+// ([]StringSet) if te assigns named strings, otherwise nil.
+func stringSetNode(te *Text) node.Node {
+	ss, ok := te.Settings[SettingStringSet].([]StringSet)
+	if !ok || len(ss) == 0 {
+		return nil
+	}
+	start := node.NewStartStop()
+	start.Attributes = node.H{"stringset": ss}
+	return start
+}
+
+// FormatRunningElement formats the running element te to the width wd and
+// returns it as a vertical list including its margins, borders and padding.
+func (fe *Document) FormatRunningElement(te *Text, wd bag.ScaledPoint) (*node.VList, error) {
+	info, err := fe.buildVlist(te, wd, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fe.boxToVList(info), nil
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/speedata/boxesandglue/backend/bag"
 	"github.com/speedata/boxesandglue/backend/color"
 	"github.com/speedata/boxesandglue/backend/node"
-	"github.com/speedata/boxesandglue/frontend/pdfdraw"
 )
 
 // Table represents tabular material to be typeset.
@@ -25,6 +24,30 @@ type Table struct {
 	nCol         int
 	nRow         int
 	cellMatrix   matrix
+
+	// HeaderRows is the number of rows at the start of Rows (thead) which
+	// are repeated at the top of each part of a split table.
+	HeaderRows int
+	// FooterRows is the number of rows at the end of Rows (tfoot) which are
+	// repeated at the bottom of each part of a split table.
+	FooterRows int
+	// MaxHeight is the maximum height of the parts of the table. BuildTable
+	// splits a higher table between two rows. 0 means no limit.
+	MaxHeight bag.ScaledPoint
+	// ContinuedTop and ContinuedBottom are optional markers (such as
+	// "continued") which are placed above the parts following a break and
+	// below the parts followed by a break.
+	ContinuedTop    *Text
+	ContinuedBottom *Text
+	// BorderSeparate selects the separated borders model (CSS
+	// border-collapse: separate) where each cell draws its own borders.
+	// Otherwise the borders of adjacent cells are collapsed into one.
+	BorderSeparate bool
+	// BorderSpacingX and BorderSpacingY are the horizontal and the vertical
+	// distance between the cells and between the cells and the edges of the
+	// table in the separated borders model.
+	BorderSpacingX bag.ScaledPoint
+	BorderSpacingY bag.ScaledPoint
 }
 
 // TableRow represents a row in a table.
@@ -36,7 +59,8 @@ type TableRow struct {
 	row              int
 }
 
-// TableCell represents a table cell
+// TableCell represents a table cell. A border with a width but without a
+// style is solid.
 type TableCell struct {
 	BorderTopWidth              bag.ScaledPoint
 	BorderBottomWidth           bag.ScaledPoint
@@ -46,6 +70,10 @@ type TableCell struct {
 	BorderBottomColor           *color.Color
 	BorderLeftColor             *color.Color
 	BorderRightColor            *color.Color
+	BorderTopStyle              BorderStyle
+	BorderBottomStyle           BorderStyle
+	BorderLeftStyle             BorderStyle
+	BorderRightStyle            BorderStyle
 	CalculatedWidth             bag.ScaledPoint
 	CalculatedHeight            bag.ScaledPoint
 	HAlign                      HorizontalAlignment
@@ -61,6 +89,16 @@ type TableCell struct {
 	calculatedBorderRightWidth  bag.ScaledPoint
 	calculatedBorderTopWidth    bag.ScaledPoint
 	calculatedBorderBottomWidth bag.ScaledPoint
+	calculatedBorderLeftStyle   BorderStyle
+	calculatedBorderRightStyle  BorderStyle
+	calculatedBorderTopStyle    BorderStyle
+	calculatedBorderBottomStyle BorderStyle
+	calculatedBorderLeftColor   *color.Color
+	calculatedBorderRightColor  *color.Color
+	calculatedBorderTopColor    *color.Color
+	calculatedBorderBottomColor *color.Color
+	neighborBorderTopWidth      bag.ScaledPoint // part of the top border drawn by the cell above
+	neighborBorderBottomWidth   bag.ScaledPoint // part of the bottom border drawn by the cell below
 	row                         *TableRow
 	rowStart                    int // top left corner
 	colStart                    int // top left corner
@@ -117,7 +155,7 @@ func (cell *TableCell) minWidth() (bag.ScaledPoint, error) {
 			}
 		}
 	}
-	return minwd + cell.PaddingLeft + cell.PaddingRight + cell.BorderLeftWidth + cell.BorderRightWidth, nil
+	return minwd + cell.PaddingLeft + cell.PaddingRight + cell.calculatedBorderLeftWidth + cell.calculatedBorderRightWidth, nil
 }
 
 // Format the cell with maximum size and find out the longest line.
@@ -148,7 +186,7 @@ func (cell *TableCell) maxWidth() (bag.ScaledPoint, error) {
 			}
 		}
 	}
-	return maxwd + cell.BorderLeftWidth + cell.BorderRightWidth + cell.PaddingLeft + cell.PaddingRight, nil
+	return maxwd + cell.calculatedBorderLeftWidth + cell.calculatedBorderRightWidth + cell.PaddingLeft + cell.PaddingRight, nil
 }
 
 func (cell *TableCell) build() (*node.VList, error) {
@@ -224,15 +262,15 @@ func (cell *TableCell) build() (*node.VList, error) {
 	vl.Height = vl.Height + vl.Depth
 	vl.Depth = 0
 	head = nil
+	black := fe.GetColor("black")
+	borderColor := func(col *color.Color) *color.Color {
+		if col == nil {
+			return black
+		}
+		return col
+	}
 	if cell.calculatedBorderLeftWidth != 0 {
-		if cell.BorderLeftColor == nil {
-			cell.BorderLeftColor = cell.row.table.doc.GetColor("black")
-		}
-		r := node.NewRule()
-		r.Height = cellHeight - cell.calculatedBorderTopWidth - cell.calculatedBorderBottomWidth
-		r.Width = cell.calculatedBorderLeftWidth
-		r.Pre = pdfdraw.New().Save().ColorNonstroking(*cell.BorderLeftColor).String()
-		r.Post = pdfdraw.New().Restore().String()
+		r := borderRule(cell.calculatedBorderLeftWidth, cellHeight-cell.calculatedBorderTopWidth-cell.calculatedBorderBottomWidth, cell.calculatedBorderLeftStyle, borderColor(cell.calculatedBorderLeftColor), false)
 		r.Attributes = node.H{"origin": "left rule"}
 		head = r
 	}
@@ -252,14 +290,7 @@ func (cell *TableCell) build() (*node.VList, error) {
 	}
 
 	if cell.calculatedBorderRightWidth != 0 {
-		if cell.BorderRightColor == nil {
-			cell.BorderRightColor = cell.row.table.doc.GetColor("black")
-		}
-		r := node.NewRule()
-		r.Height = cellHeight - cell.calculatedBorderTopWidth - cell.calculatedBorderBottomWidth
-		r.Width = cell.calculatedBorderRightWidth
-		r.Pre = pdfdraw.New().Save().ColorNonstroking(*cell.BorderRightColor).String()
-		r.Post = pdfdraw.New().Restore().String()
+		r := borderRule(cell.calculatedBorderRightWidth, cellHeight-cell.calculatedBorderTopWidth-cell.calculatedBorderBottomWidth, cell.calculatedBorderRightStyle, borderColor(cell.calculatedBorderRightColor), false)
 		r.Attributes = node.H{"origin": "right rule"}
 		head = node.InsertAfter(head, node.Tail(head), r)
 	}
@@ -268,26 +299,12 @@ func (cell *TableCell) build() (*node.VList, error) {
 	head = hl
 
 	if cell.calculatedBorderTopWidth != 0 {
-		if cell.BorderTopColor == nil {
-			cell.BorderTopColor = cell.row.table.doc.GetColor("black")
-		}
-		r := node.NewRule()
-		r.Width = hl.Width
-		r.Height = cell.calculatedBorderTopWidth
-		r.Pre = pdfdraw.New().Save().ColorNonstroking(*cell.BorderTopColor).String()
-		r.Post = pdfdraw.New().Restore().String()
+		r := borderRule(hl.Width, cell.calculatedBorderTopWidth, cell.calculatedBorderTopStyle, borderColor(cell.calculatedBorderTopColor), true)
 		r.Attributes = node.H{"origin": "top rule"}
 		head = node.InsertBefore(head, head, r)
 	}
 	if cell.calculatedBorderBottomWidth != 0 {
-		if cell.BorderBottomColor == nil {
-			cell.BorderBottomColor = cell.row.table.doc.GetColor("black")
-		}
-		r := node.NewRule()
-		r.Width = hl.Width
-		r.Height = cell.calculatedBorderBottomWidth
-		r.Pre = pdfdraw.New().Save().ColorNonstroking(*cell.BorderBottomColor).String()
-		r.Post = pdfdraw.New().Restore().String()
+		r := borderRule(hl.Width, cell.calculatedBorderBottomWidth, cell.calculatedBorderBottomStyle, borderColor(cell.calculatedBorderBottomColor), true)
 		r.Attributes = node.H{"origin": "bottom rule"}
 		head = node.InsertAfter(head, node.Tail(head), r)
 	}
@@ -303,8 +320,10 @@ func (row *TableRow) getNumberOfColumns() int {
 
 func (row *TableRow) setHeight() ([]span, error) {
 	maxht := bag.ScaledPoint(0)
+	sx, sy := row.table.spacing()
 	for _, cell := range row.Cells {
-		cell.CalculatedWidth = 0
+		// a cell spanning several columns includes the spacing between them
+		cell.CalculatedWidth = sx * bag.ScaledPoint(cell.ExtraColspan)
 		for j := 0; j <= cell.ExtraColspan; j++ {
 			cell.CalculatedWidth += row.table.columnWidths[cell.colStart+j]
 		}
@@ -321,7 +340,7 @@ func (row *TableRow) setHeight() ([]span, error) {
 				maxht = ht
 			}
 		} else {
-			rowspans = append(rowspans, span{start: cell.rowStart, end: cell.rowStart + cell.ExtraColspan, size: ht})
+			rowspans = append(rowspans, span{start: cell.rowStart, end: cell.rowStart + cell.ExtraRowspan, size: ht - sy*bag.ScaledPoint(cell.ExtraRowspan)})
 		}
 	}
 	row.table.rowHeights[row.row] = maxht
@@ -346,7 +365,8 @@ func (row *TableRow) calculateWidths() ([]bag.ScaledPoint, []bag.ScaledPoint, []
 			colwidthsMin[c.colStart] = minwd
 			colwidthsMax[c.colStart] = maxwd
 		} else {
-			colspans = append(colspans, span{start: c.colStart, end: c.colStart + c.ExtraColspan, size: maxwd})
+			sx, _ := row.table.spacing()
+			colspans = append(colspans, span{start: c.colStart, end: c.colStart + c.ExtraColspan, size: maxwd - sx*bag.ScaledPoint(c.ExtraColspan)})
 		}
 	}
 	return colwidthsMin, colwidthsMax, colspans, nil
@@ -355,9 +375,19 @@ func (row *TableRow) calculateWidths() ([]bag.ScaledPoint, []bag.ScaledPoint, []
 func (row *TableRow) build() (*node.HList, error) {
 	var head node.Node
 	var tail node.Node
+	sx, _ := row.table.spacing()
+	addSpacing := func() {
+		if sx != 0 {
+			k := node.NewKern()
+			k.Kern = sx
+			head = node.InsertAfter(head, tail, k)
+			tail = k
+		}
+	}
 	for x := 0; x < row.table.nCol; x++ {
+		addSpacing()
 		cellptr := row.table.cellMatrix[x][row.row]
-		if cellptr.cell.rowStart == row.row {
+		if cellptr.cell != nil && cellptr.cell.rowStart == row.row {
 			vl, err := cellptr.cell.build()
 			if err != nil {
 				return nil, err
@@ -366,7 +396,7 @@ func (row *TableRow) build() (*node.HList, error) {
 			tail = vl
 			x += cellptr.cell.ExtraColspan
 		} else {
-			// dummy cell because of rowspan
+			// dummy cell because of rowspan or a short row
 			g := node.NewGlue()
 			g.Stretch = bag.Factor
 			g.StretchOrder = 1
@@ -378,6 +408,7 @@ func (row *TableRow) build() (*node.HList, error) {
 			tail = vl
 		}
 	}
+	addSpacing()
 	hl := node.Hpack(head)
 	hl.Attributes = node.H{"origin": "table row"}
 	return hl, nil
@@ -404,21 +435,11 @@ func (tr *TableRows) calculateHeights() error {
 		}
 		rowspans = append(rowspans, rs...)
 	}
-	for _, rs := range rowspans {
-		sumHT := bag.ScaledPoint(0)
-		for r := rs.start; r <= rs.end; r++ {
-			sumHT += tbl.rowHeights[r]
-		}
-		if rs.size > sumHT {
-			stretch := (rs.size - sumHT) / bag.ScaledPoint(rs.end-rs.start+1)
-			for r := rs.start; r <= rs.end; r++ {
-				tbl.rowHeights[r] = tbl.rowHeights[r] + stretch
-			}
-		}
-	}
+	distributeSpans(tbl.rowHeights, rowspans)
+	_, sy := tbl.spacing()
 	for _, row := range *tr {
 		for _, cell := range row.Cells {
-			cell.CalculatedHeight = 0
+			cell.CalculatedHeight = sy * bag.ScaledPoint(cell.ExtraRowspan)
 			for rs := 0; rs <= cell.ExtraRowspan; rs++ {
 				cell.CalculatedHeight += row.table.rowHeights[cell.rowStart+rs]
 			}
@@ -427,6 +448,69 @@ func (tr *TableRows) calculateHeights() error {
 	return nil
 }
 
+// distributeSpans enlarges the sizes so that each span fits into the sizes
+// from start to end. The missing size is distributed evenly.
+func distributeSpans(sizes []bag.ScaledPoint, spans []span) {
+	for _, sp := range spans {
+		sum := bag.ScaledPoint(0)
+		for i := sp.start; i <= sp.end; i++ {
+			sum += sizes[i]
+		}
+		if sp.size > sum {
+			stretch := (sp.size - sum) / bag.ScaledPoint(sp.end-sp.start+1)
+			for i := sp.start; i <= sp.end; i++ {
+				sizes[i] += stretch
+			}
+		}
+	}
+}
+
+// distributeWidths returns the widths of columns with the minimum widths
+// colmin and the preferred widths colmax, so that they fill the given width.
+// The columns are shrunk or stretched proportionally, but they do not get
+// smaller than their minimum widths.
+func distributeWidths(colmin, colmax []bag.ScaledPoint, width bag.ScaledPoint) []bag.ScaledPoint {
+	widths := make([]bag.ScaledPoint, len(colmax))
+	sumCols := bag.ScaledPoint(0)
+	for _, max := range colmax {
+		sumCols += max
+	}
+	if width < sumCols {
+		// shrink
+		r := width.ToPT() / sumCols.ToPT()
+		shrinkTbl := make([]float64, len(colmax))
+
+		sumShrinkFactor := 0.0
+		excess := bag.ScaledPoint(0)
+
+		for i, colwd := range colmax {
+			widths[i] = bag.ScaledPointFromFloat(colwd.ToPT() * r)
+			if a := widths[i] - colmin[i]; a < 0 {
+				excess += a
+				widths[i] = colmin[i]
+			} else if a > 0 && colmin[i] > 0 {
+				shrinkTbl[i] = widths[i].ToPT() / colmin[i].ToPT()
+				sumShrinkFactor += shrinkTbl[i]
+			}
+		}
+		for i := range widths {
+			if shrinkTbl[i] != 0 {
+				widths[i] += bag.ScaledPointFromFloat(shrinkTbl[i] / sumShrinkFactor * excess.ToPT())
+			}
+		}
+	} else if width == sumCols {
+		// equal size
+		copy(widths, colmax)
+	} else if width > sumCols {
+		// stretch
+		r := width.ToPT() / sumCols.ToPT()
+		for i, colwd := range colmax {
+			widths[i] = bag.ScaledPointFromFloat(colwd.ToPT() * r)
+		}
+	}
+	return widths
+}
+
 func (cp cellptr) String() string {
 	return cp.cell.String()
 }
@@ -455,7 +539,7 @@ func (m matrix) String() string {
 }
 
 // analyzeTable builds some helper data structures for the table to calculate
-// row span and col span and border widths (in case of border collapse).
+// row span and col span and the borders the cells draw.
 func (tbl *Table) analyzeTable() {
 	// calculate number of rows and columns
 	tbl.nRow = len(tbl.Rows)
@@ -484,10 +568,6 @@ func (tbl *Table) analyzeTable() {
 			}
 			cell.colStart = x + extraCol
 			cell.rowStart = y
-			cell.calculatedBorderLeftWidth = cell.BorderLeftWidth
-			cell.calculatedBorderRightWidth = cell.BorderRightWidth
-			cell.calculatedBorderBottomWidth = cell.BorderBottomWidth
-			cell.calculatedBorderTopWidth = cell.BorderTopWidth
 			for i := 0; i <= cell.ExtraColspan; i++ {
 				for r := 0; r <= cell.ExtraRowspan; r++ {
 					tbl.cellMatrix[x+i+extraCol][y+r] = cellptr{cell: cell, colspan: cell.ExtraColspan - i, rowspan: cell.ExtraRowspan - r}
@@ -506,7 +586,7 @@ func (tbl *Table) analyzeTable() {
 			for r := 0; r < cellp.rowspan+1; r++ {
 				cellp = tbl.cellMatrix[col][row+r]
 				col += cellp.colspan
-				if col < tbl.nCol-1 {
+				if cellp.cell != nil && col < tbl.nCol-1 && tbl.cellMatrix[col+1][row+r].cell != nil {
 					nc := tbl.cellMatrix[col+1][row+r].cell
 					// only append the next cell value if we have not appended it yet
 					found := false
@@ -531,7 +611,8 @@ func (tbl *Table) analyzeTable() {
 			for c := 0; c < cellp.colspan+1; c++ {
 				cellp = tbl.cellMatrix[col+c][row]
 				row += cellp.rowspan
-				if row < tbl.nRow-1 {
+				// a short row has no cells at the end
+				if cellp.cell != nil && row < tbl.nRow-1 && tbl.cellMatrix[col+c][row+1].cell != nil {
 					nr := tbl.cellMatrix[col+c][row+1].cell
 					// only append the next cell value if we have not appended it yet
 					found := false
@@ -548,55 +629,12 @@ func (tbl *Table) analyzeTable() {
 			}
 		}
 	}
-	// border collapse
-	for _, row := range tbl.Rows {
-		for _, cell := range row.Cells {
-			maxBorderLeft := bag.ScaledPoint(0)
-			for _, nc := range cell.nextCell {
-				if nc.BorderLeftWidth > maxBorderLeft {
-					maxBorderLeft = nc.BorderLeftWidth
-				}
-			}
-			borderWidthWant := cell.BorderRightWidth
-			for _, nc := range cell.nextCell {
-				if nc.BorderLeftWidth > cell.BorderRightWidth {
-					borderWidthWant = nc.BorderLeftWidth
-				}
-			}
-			if cell.nextCell != nil {
-				borderWidthWant /= 2
-			}
-			if borderWidthWant <= cell.calculatedBorderRightWidth {
-				cell.calculatedBorderRightWidth = borderWidthWant
-				for _, nc := range cell.nextCell {
-					nc.calculatedBorderLeftWidth = borderWidthWant
-				}
-			} else {
-				cell.calculatedBorderRightWidth = borderWidthWant
-			}
-
-			maxBorderTop := bag.ScaledPoint(0)
-			for _, nr := range cell.nextRow {
-				if nr.BorderTopWidth > maxBorderTop {
-					maxBorderTop = nr.BorderTopWidth
-				}
-			}
-			borderWidthWant = cell.BorderBottomWidth
-			for _, nr := range cell.nextRow {
-				if nr.BorderTopWidth > cell.BorderBottomWidth {
-					borderWidthWant = nr.BorderTopWidth
-				}
-			}
-			if borderWidthWant <= cell.calculatedBorderBottomWidth {
-				for _, nr := range cell.nextRow {
-					nr.calculatedBorderTopWidth = 0
-				}
-			}
-		}
-	}
+	tbl.resolveBorders()
 }
 
-// BuildTable creates one or more vertical lists to be placed into the PDF.
+// BuildTable creates one or more vertical lists to be placed into the PDF. The
+// table is split into several parts if it is higher than tbl.MaxHeight. Each
+// part can be split again with SplitTable.
 func (fe *Document) BuildTable(tbl *Table) ([]*node.VList, error) {
 	tbl.doc = fe
 	var head, tail node.Node
@@ -604,6 +642,10 @@ func (fe *Document) BuildTable(tbl *Table) ([]*node.VList, error) {
 	tbl.columnWidths = make([]bag.ScaledPoint, tbl.nCol)
 	tbl.rowHeights = make([]bag.ScaledPoint, tbl.nRow)
 	colspans := []span{}
+	// the horizontal spacing is left of each column and right of the last
+	// column
+	sx, sy := tbl.spacing()
+	sumSpacing := sx * bag.ScaledPoint(tbl.nCol+1)
 	if tbl.ColSpec == nil || len(tbl.ColSpec) == 0 {
 		colmax := make([]bag.ScaledPoint, tbl.nCol)
 		colmin := make([]bag.ScaledPoint, tbl.nCol)
@@ -626,70 +668,24 @@ func (fe *Document) BuildTable(tbl *Table) ([]*node.VList, error) {
 		}
 
 		// handle colspan
-		for _, cs := range colspans {
-			sumWd := bag.ScaledPoint(0)
-			for i := cs.start; i <= cs.end; i++ {
-				sumWd += colmax[i]
-			}
-			if cs.size > sumWd {
-				stretch := (cs.size - sumWd) / bag.ScaledPoint(cs.end-cs.start+1)
-				for r := cs.start; r <= cs.end; r++ {
-					colmax[r] = colmax[r] + stretch
-				}
-			}
-		}
+		distributeSpans(colmax, colspans)
 
 		sumCols := bag.ScaledPoint(0)
 		for _, max := range colmax {
 			sumCols += max
 		}
 		if !tbl.Stretch {
-			if sumCols < tbl.MaxWidth {
-				tbl.MaxWidth = sumCols
-			}
-		}
-		// adjust the column widths
-		if tbl.MaxWidth < sumCols {
-			// shrink
-			r := tbl.MaxWidth.ToPT() / sumCols.ToPT()
-			shrinkTbl := make([]float64, tbl.nCol)
-
-			sumShrinkFactor := 0.0
-			excess := bag.ScaledPoint(0)
-
-			for i, colwd := range colmax {
-				tbl.columnWidths[i] = bag.ScaledPointFromFloat(colwd.ToPT() * r)
-				if a := tbl.columnWidths[i] - colmin[i]; a < 0 {
-					excess += a
-					tbl.columnWidths[i] = colmin[i]
-				} else if a > 0 {
-					shrinkTbl[i] = tbl.columnWidths[i].ToPT() / colmin[i].ToPT()
-					sumShrinkFactor += shrinkTbl[i]
-				}
-			}
-			for i := 0; i < tbl.nCol; i++ {
-				if shrinkTbl[i] != 0 {
-					tbl.columnWidths[i] += bag.ScaledPointFromFloat(shrinkTbl[i] / sumShrinkFactor * excess.ToPT())
-				}
-			}
-		} else if tbl.MaxWidth == sumCols {
-			// equal size
-			for i, colwd := range colmax {
-				tbl.columnWidths[i] = colwd
-			}
-		} else if tbl.MaxWidth > sumCols {
-			// stretch
-			r := tbl.MaxWidth.ToPT() / sumCols.ToPT()
-			for i, colwd := range colmax {
-				tbl.columnWidths[i] = bag.ScaledPointFromFloat(colwd.ToPT() * r)
+			if sumCols+sumSpacing < tbl.MaxWidth {
+				tbl.MaxWidth = sumCols + sumSpacing
 			}
 		}
+		tbl.columnWidths = distributeWidths(colmin, colmax, tbl.MaxWidth-sumSpacing)
 	} else {
 		for _, colspec := range tbl.ColSpec {
 			head = node.InsertAfter(head, tail, colspec.ColumnWidth)
 			tail = colspec.ColumnWidth
 		}
-		hl := node.HpackTo(head, tbl.MaxWidth)
+		hl := node.HpackTo(head, tbl.MaxWidth-sumSpacing)
 		i := 0
 		for e := hl.List; e != nil; e = e.Next() {
 			if g, ok := e.(*node.Glue); ok {
@@ -698,27 +694,41 @@ func (fe *Document) BuildTable(tbl *Table) ([]*node.VList, error) {
 			i++
 		}
 	}
-	head = nil
-	tail = nil
 	// now that the column widths are known, the row heights can be calculated
 	err := tbl.Rows.calculateHeights()
 	if err != nil {
 		return nil, err
 	}
 
+	rows := make([]*node.HList, len(tbl.Rows))
 	for i, row := range tbl.Rows {
 		hl, err := row.build()
 		if err != nil {
 			return nil, err
 		}
 		// rows with rowspan might have a different height than requested by the
-		// calculated row height, so we need to adjust
-		hl.Height = tbl.rowHeights[i]
+		// calculated row height, so we need to adjust. The vertical spacing
+		// is below each row.
+		hl.Height = tbl.rowHeights[i] + sy
 		hl.Depth = 0
-		head = node.InsertAfter(head, tail, hl)
-		tail = hl
+		rows[i] = hl
+	}
+	tr, err := tbl.tableRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	vl := tr.vlist(true)
+	if tbl.MaxHeight <= 0 {
+		return []*node.VList{vl}, nil
+	}
+	var parts []*node.VList
+	for vl.Height+vl.Depth > tbl.MaxHeight {
+		first, rest := SplitTable(vl, tbl.MaxHeight)
+		if first == nil {
+			break
+		}
+		parts = append(parts, first)
+		vl = rest
 	}
-	vl := node.Vpack(head)
-	vl.Attributes = node.H{"origin": "table"}
-	return []*node.VList{vl}, nil
+	return append(parts, vl), nil
 }
@@ -0,0 +1,37 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+)
+
+func TestDistributeWidths(t *testing.T) {
+	pt := func(vals ...int) []bag.ScaledPoint {
+		ret := make([]bag.ScaledPoint, len(vals))
+		for i, v := range vals {
+			ret[i] = bag.ScaledPoint(v) * bag.Factor
+		}
+		return ret
+	}
+	testdata := []struct {
+		colmin, colmax []bag.ScaledPoint
+		width          bag.ScaledPoint
+		expected       []bag.ScaledPoint
+	}{
+		{pt(10, 10), pt(20, 40), 90 * bag.Factor, pt(30, 60)},
+		{pt(10, 10), pt(20, 40), 60 * bag.Factor, pt(20, 40)},
+		{pt(10, 30), pt(40, 40), 50 * bag.Factor, pt(20, 30)},
+		// the empty second column does not take part in the shrinking
+		{pt(10, 0, 20), pt(40, 20, 20), 40 * bag.Factor, pt(10, 10, 20)},
+	}
+	for i, td := range testdata {
+		got := distributeWidths(td.colmin, td.colmax, td.width)
+		for j := range td.expected {
+			if got[j] != td.expected[j] {
+				t.Errorf("%d: widths = %v, want %v", i, got, td.expected)
+				break
+			}
+		}
+	}
+}
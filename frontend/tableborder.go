@@ -0,0 +1,265 @@
+package frontend
+
+import (
+	"fmt"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/color"
+	"github.com/speedata/boxesandglue/backend/node"
+	"github.com/speedata/boxesandglue/frontend/pdfdraw"
+)
+
+// tableBorder is one side of the border of a table cell.
+type tableBorder struct {
+	width bag.ScaledPoint
+	style BorderStyle
+	color *color.Color
+}
+
+// newTableBorder returns a border of a table cell. A border with a width but
+// without a style is solid, hidden borders and borders without a style have
+// no width.
+func newTableBorder(wd bag.ScaledPoint, sty BorderStyle, col *color.Color) tableBorder {
+	if sty == BorderStyleNone && wd > 0 {
+		sty = BorderStyleSolid
+	}
+	if !sty.visible() {
+		wd = 0
+	}
+	return tableBorder{width: wd, style: sty, color: col}
+}
+
+// cellBorders are the four borders of a table cell.
+type cellBorders struct {
+	top, right, bottom, left tableBorder
+}
+
+// borderStyleRank is the priority of the visible border styles in the border
+// conflict resolution.
+var borderStyleRank = map[BorderStyle]int{
+	BorderStyleDouble: 4,
+	BorderStyleSolid:  3,
+	BorderStyleDashed: 2,
+	BorderStyleDotted: 1,
+}
+
+// collapseBorders returns the border which wins the conflict between the
+// adjacent borders a and b of two cells (CSS 2.1, 17.6.2.1). A hidden border
+// wins over all other borders, otherwise the wider border wins, then the style
+// in the order double, solid, dashed, dotted. On a tie a wins, so a should be
+// the border of the cell to the left or above.
+func collapseBorders(a, b tableBorder) tableBorder {
+	if a.style == BorderStyleHidden {
+		return a
+	}
+	if b.style == BorderStyleHidden {
+		return b
+	}
+	if a.width != b.width {
+		if a.width > b.width {
+			return a
+		}
+		return b
+	}
+	if borderStyleRank[b.style] > borderStyleRank[a.style] {
+		return b
+	}
+	return a
+}
+
+// borders returns the borders of the cell as given by the user.
+func (cell *TableCell) borders() cellBorders {
+	return cellBorders{
+		top:    newTableBorder(cell.BorderTopWidth, cell.BorderTopStyle, cell.BorderTopColor),
+		right:  newTableBorder(cell.BorderRightWidth, cell.BorderRightStyle, cell.BorderRightColor),
+		bottom: newTableBorder(cell.BorderBottomWidth, cell.BorderBottomStyle, cell.BorderBottomColor),
+		left:   newTableBorder(cell.BorderLeftWidth, cell.BorderLeftStyle, cell.BorderLeftColor),
+	}
+}
+
+// setCalculatedBorders sets the borders the cell draws.
+func (cell *TableCell) setCalculatedBorders(b cellBorders) {
+	cell.calculatedBorderTopWidth = b.top.width
+	cell.calculatedBorderTopStyle = b.top.style
+	cell.calculatedBorderTopColor = b.top.color
+	cell.calculatedBorderRightWidth = b.right.width
+	cell.calculatedBorderRightStyle = b.right.style
+	cell.calculatedBorderRightColor = b.right.color
+	cell.calculatedBorderBottomWidth = b.bottom.width
+	cell.calculatedBorderBottomStyle = b.bottom.style
+	cell.calculatedBorderBottomColor = b.bottom.color
+	cell.calculatedBorderLeftWidth = b.left.width
+	cell.calculatedBorderLeftStyle = b.left.style
+	cell.calculatedBorderLeftColor = b.left.color
+}
+
+// resolveBorders sets the borders the cells draw. In the separated borders
+// model these are the borders of the cells. Otherwise the borders between two
+// cells are collapsed into one border (collapseBorders) and each cell draws
+// one half of it. The borders at the edges of the table are drawn completely.
+func (tbl *Table) resolveBorders() {
+	own := map[*TableCell]cellBorders{}
+	resolved := map[*TableCell]*cellBorders{}
+	for _, row := range tbl.Rows {
+		for _, cell := range row.Cells {
+			b := cell.borders()
+			own[cell] = b
+			resolved[cell] = &b
+			cell.neighborBorderTopWidth = 0
+			cell.neighborBorderBottomWidth = 0
+		}
+	}
+	if !tbl.BorderSeparate {
+		// a cell with a row span or a col span can have more than one
+		// neighbor on a side and draws the strongest of the borders.
+		prevCell := map[*TableCell]bool{}
+		prevRow := map[*TableCell]bool{}
+		for _, row := range tbl.Rows {
+			for _, cell := range row.Cells {
+				for _, nc := range cell.nextCell {
+					resolved[cell].right = collapseBorders(resolved[cell].right, own[nc].left)
+					resolved[nc].left = collapseBorders(own[cell].right, resolved[nc].left)
+					prevCell[nc] = true
+				}
+				for _, nr := range cell.nextRow {
+					resolved[cell].bottom = collapseBorders(resolved[cell].bottom, own[nr].top)
+					resolved[nr].top = collapseBorders(own[cell].bottom, resolved[nr].top)
+					prevRow[nr] = true
+				}
+			}
+		}
+		// only the borders between two cells are shared
+		for _, row := range tbl.Rows {
+			for _, cell := range row.Cells {
+				b := resolved[cell]
+				if prevRow[cell] {
+					cell.neighborBorderTopWidth = b.top.width - b.top.width/2
+					b.top.width /= 2
+				}
+				if len(cell.nextCell) > 0 {
+					b.right.width /= 2
+				}
+				if len(cell.nextRow) > 0 {
+					cell.neighborBorderBottomWidth = b.bottom.width - b.bottom.width/2
+					b.bottom.width /= 2
+				}
+				if prevCell[cell] {
+					b.left.width /= 2
+				}
+			}
+		}
+	}
+	for cell, b := range resolved {
+		cell.setCalculatedBorders(*b)
+	}
+}
+
+// edgeBorders returns a horizontal list which completes the shared borders
+// between the table row y and the row above (top is true) or below when the
+// table is split there, so the part of the table has its full border. It
+// returns nil if there is nothing to complete.
+func (tbl *Table) edgeBorders(y int, top bool) *node.HList {
+	black := tbl.doc.GetColor("black")
+	var head, tail node.Node
+	found := false
+	for x := 0; x < tbl.nCol; x++ {
+		cell := tbl.cellMatrix[x][y].cell
+		if cell == nil {
+			// a missing cell in a short row
+			k := node.NewKern()
+			k.Kern = tbl.columnWidths[x]
+			head = node.InsertAfter(head, tail, k)
+			tail = k
+			continue
+		}
+		x += cell.ExtraColspan
+		wd, sty, col := cell.neighborBorderBottomWidth, cell.calculatedBorderBottomStyle, cell.calculatedBorderBottomColor
+		if top {
+			wd, sty, col = cell.neighborBorderTopWidth, cell.calculatedBorderTopStyle, cell.calculatedBorderTopColor
+		}
+		var n node.Node
+		if wd > 0 {
+			if col == nil {
+				col = black
+			}
+			// a vertical list in a horizontal list is aligned at the top
+			n = node.Vpack(borderRule(cell.CalculatedWidth, wd, sty, col, true))
+			found = true
+		} else {
+			k := node.NewKern()
+			k.Kern = cell.CalculatedWidth
+			n = k
+		}
+		head = node.InsertAfter(head, tail, n)
+		tail = n
+	}
+	if !found {
+		return nil
+	}
+	hl := node.Hpack(head)
+	hl.Attributes = node.H{"origin": "split table border"}
+	return hl
+}
+
+// spacing returns the horizontal and the vertical distance between the cells
+// of the table.
+func (tbl *Table) spacing() (bag.ScaledPoint, bag.ScaledPoint) {
+	if !tbl.BorderSeparate {
+		return 0, 0
+	}
+	return tbl.BorderSpacingX, tbl.BorderSpacingY
+}
+
+// borderRule returns a rule of the size wd x ht which draws a border with the
+// style and the color. The top and bottom borders are horizontal and part of
+// a vertical list, the left and right borders are part of a horizontal list.
+func borderRule(wd, ht bag.ScaledPoint, sty BorderStyle, col *color.Color, horizontal bool) *node.Rule {
+	r := node.NewRule()
+	r.Width = wd
+	r.Height = ht
+	if sty == BorderStyleSolid {
+		r.Pre = pdfdraw.New().Save().ColorNonstroking(*col).String()
+		r.Post = pdfdraw.New().Restore().String()
+		return r
+	}
+	r.Hide = true
+	// the lower left corner of the rule, in a vertical list the rule is drawn
+	// below its origin
+	y := bag.ScaledPoint(0)
+	if horizontal {
+		y = -ht
+	}
+	pd := pdfdraw.NewStandalone()
+	switch sty {
+	case BorderStyleDouble:
+		pd.ColorNonstroking(*col)
+		if horizontal {
+			pd.Rect(0, y, wd, ht/3).Rect(0, y+ht-ht/3, wd, ht/3)
+		} else {
+			pd.Rect(0, y, wd/3, ht).Rect(wd-wd/3, y, wd/3, ht)
+		}
+		pd.Fill()
+	case BorderStyleDotted, BorderStyleDashed:
+		lw := wd
+		if horizontal {
+			lw = ht
+		}
+		pd.ColorStroking(*col).LineWidth(lw)
+		// dots have round caps which must not reach beyond the rule
+		inset := bag.ScaledPoint(0)
+		if sty == BorderStyleDotted {
+			pd.Literal(fmt.Sprintf("1 J [0 %s] 0 d", 2*lw))
+			inset = lw / 2
+		} else {
+			pd.Literal(fmt.Sprintf("[%s %s] 0 d", 3*lw, 2*lw))
+		}
+		if horizontal {
+			pd.Moveto(inset, y+ht/2).Lineto(wd-inset, y+ht/2)
+		} else {
+			pd.Moveto(wd/2, y+inset).Lineto(wd/2, y+ht-inset)
+		}
+		pd.Stroke()
+	}
+	r.Pre = pd.String()
+	return r
+}
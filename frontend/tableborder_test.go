@@ -0,0 +1,140 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/color"
+)
+
+func TestCollapseBorders(t *testing.T) {
+	thin := tableBorder{width: bag.Factor, style: BorderStyleSolid}
+	thick := tableBorder{width: 2 * bag.Factor, style: BorderStyleDotted}
+	double := tableBorder{width: bag.Factor, style: BorderStyleDouble}
+	hidden := tableBorder{style: BorderStyleHidden}
+	testdata := []struct {
+		a, b, want tableBorder
+	}{
+		{thin, thick, thick},
+		{thick, thin, thick},
+		{thin, double, double},
+		{thick, hidden, hidden},
+		{hidden, thick, hidden},
+		{thin, thin, thin},
+	}
+	for i, td := range testdata {
+		if got := collapseBorders(td.a, td.b); got != td.want {
+			t.Errorf("%d: collapseBorders() = %+v, want %+v", i, got, td.want)
+		}
+	}
+}
+
+func TestResolveBorders(t *testing.T) {
+	newTable := func(separate bool) *Table {
+		cell := func() *TableCell {
+			return &TableCell{
+				BorderTopWidth:    2 * bag.Factor,
+				BorderBottomWidth: 2 * bag.Factor,
+				BorderLeftWidth:   2 * bag.Factor,
+				BorderRightWidth:  2 * bag.Factor,
+			}
+		}
+		tbl := &Table{BorderSeparate: separate}
+		for i := 0; i < 2; i++ {
+			tbl.Rows = append(tbl.Rows, &TableRow{Cells: []*TableCell{cell(), cell()}})
+		}
+		return tbl
+	}
+
+	tbl := newTable(false)
+	a, b := tbl.Rows[0].Cells[0], tbl.Rows[0].Cells[1]
+	b.BorderLeftWidth = 4 * bag.Factor
+	b.BorderLeftStyle = BorderStyleDashed
+	tbl.Rows[1].Cells[0].BorderTopStyle = BorderStyleHidden
+	tbl.analyzeTable()
+	if a.calculatedBorderRightWidth != 2*bag.Factor || a.calculatedBorderRightStyle != BorderStyleDashed {
+		t.Errorf("right border = %s %d, want 2pt dashed", a.calculatedBorderRightWidth, a.calculatedBorderRightStyle)
+	}
+	if b.calculatedBorderLeftWidth != 2*bag.Factor || b.calculatedBorderLeftStyle != BorderStyleDashed {
+		t.Errorf("left border = %s %d, want 2pt dashed", b.calculatedBorderLeftWidth, b.calculatedBorderLeftStyle)
+	}
+	if a.calculatedBorderBottomWidth != 0 {
+		t.Errorf("bottom border above a hidden border = %s, want 0pt", a.calculatedBorderBottomWidth)
+	}
+	// the borders at the edges of the table
+	if a.calculatedBorderTopWidth != 2*bag.Factor || a.calculatedBorderLeftWidth != 2*bag.Factor {
+		t.Errorf("outer borders = %s %s, want 2pt", a.calculatedBorderTopWidth, a.calculatedBorderLeftWidth)
+	}
+
+	tbl = newTable(true)
+	tbl.analyzeTable()
+	a = tbl.Rows[0].Cells[0]
+	if a.calculatedBorderRightWidth != 2*bag.Factor || a.calculatedBorderRightStyle != BorderStyleSolid {
+		t.Errorf("separated right border = %s %d, want 2pt solid", a.calculatedBorderRightWidth, a.calculatedBorderRightStyle)
+	}
+}
+
+func TestResolveBordersRagged(t *testing.T) {
+	cell := func() *TableCell {
+		return &TableCell{
+			BorderTopWidth:    2 * bag.Factor,
+			BorderBottomWidth: 2 * bag.Factor,
+			BorderLeftWidth:   2 * bag.Factor,
+			BorderRightWidth:  2 * bag.Factor,
+		}
+	}
+	// the first row has one cell, the second row two cells
+	tbl := &Table{}
+	tbl.Rows = append(tbl.Rows, &TableRow{Cells: []*TableCell{cell()}})
+	tbl.Rows = append(tbl.Rows, &TableRow{Cells: []*TableCell{cell(), cell()}})
+	tbl.analyzeTable()
+	a, b := tbl.Rows[1].Cells[0], tbl.Rows[1].Cells[1]
+	if a.calculatedBorderTopWidth != bag.Factor {
+		t.Errorf("shared top border = %s, want 1pt", a.calculatedBorderTopWidth)
+	}
+	// no cell above b
+	if b.calculatedBorderTopWidth != 2*bag.Factor {
+		t.Errorf("top border without a neighbor = %s, want 2pt", b.calculatedBorderTopWidth)
+	}
+	if b.calculatedBorderLeftWidth != bag.Factor {
+		t.Errorf("shared left border = %s, want 1pt", b.calculatedBorderLeftWidth)
+	}
+	if got := tbl.Rows[0].Cells[0].calculatedBorderRightWidth; got != 2*bag.Factor {
+		t.Errorf("right border without a neighbor = %s, want 2pt", got)
+	}
+}
+
+func TestEdgeBorders(t *testing.T) {
+	tbl := &Table{doc: &Document{usedcolors: make(map[string]*color.Color)}}
+	for i := 0; i < 2; i++ {
+		row := &TableRow{}
+		for j := 0; j < 2; j++ {
+			row.Cells = append(row.Cells, &TableCell{
+				BorderTopWidth:    2 * bag.Factor,
+				BorderBottomWidth: 2 * bag.Factor,
+				CalculatedWidth:   10 * bag.Factor,
+			})
+		}
+		tbl.Rows = append(tbl.Rows, row)
+	}
+	tbl.analyzeTable()
+	// a split between the rows completes the borders below the first row and
+	// above the second row
+	for _, top := range []bool{false, true} {
+		y := 0
+		if top {
+			y = 1
+		}
+		hl := tbl.edgeBorders(y, top)
+		if hl == nil {
+			t.Fatalf("edgeBorders(%d, %t) = nil", y, top)
+		}
+		if hl.Width != 20*bag.Factor || hl.Height != bag.Factor {
+			t.Errorf("edgeBorders(%d, %t) = %s x %s, want 20pt x 1pt", y, top, hl.Width, hl.Height)
+		}
+	}
+	// the outer borders are complete
+	if hl := tbl.edgeBorders(0, true); hl != nil {
+		t.Errorf("edgeBorders(0, true) = %v, want nil", hl)
+	}
+}
@@ -0,0 +1,221 @@
+package frontend
+
+import (
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+// tableRows contains the rows of a table which can be split between two rows.
+// It is the "table" attribute of the vertical lists of BuildTable.
+type tableRows struct {
+	head []*node.HList
+	body []*node.HList
+	foot []*node.HList
+	// breakable[i] is true if the table can be split after the body row i,
+	// that is if no cell spans this row and the next one.
+	breakable       []bool
+	continuedTop    *node.VList
+	continuedBottom *node.VList
+	// continued is true for the parts following a break.
+	continued bool
+	// spacing is the vertical border spacing above the first row. The
+	// spacing below each row is part of the row.
+	spacing bag.ScaledPoint
+	// opening[i] and closing[i] complete the collapsed borders above and
+	// below the body row i if a part of the table starts or ends there. They
+	// are nil if there is nothing to complete.
+	opening []*node.HList
+	closing []*node.HList
+}
+
+// edgeHeight returns the height of edges[i] or 0 if there is no such edge.
+func edgeHeight(edges []*node.HList, i int) bag.ScaledPoint {
+	if i < 0 || i >= len(edges) || edges[i] == nil {
+		return 0
+	}
+	return edges[i].Height + edges[i].Depth
+}
+
+// tableRows separates the rows (thead, tbody and tfoot) and formats the
+// continued markers of the table.
+func (tbl *Table) tableRows(rows []*node.HList) (*tableRows, error) {
+	nHead := tbl.HeaderRows
+	if nHead < 0 || nHead > len(rows) {
+		nHead = 0
+	}
+	nFoot := tbl.FooterRows
+	if nFoot < 0 || nHead+nFoot > len(rows) {
+		nFoot = 0
+	}
+	nBody := len(rows) - nHead - nFoot
+	tr := &tableRows{
+		head:      rows[:nHead],
+		body:      rows[nHead : nHead+nBody],
+		foot:      rows[nHead+nBody:],
+		breakable: make([]bool, nBody),
+		opening:   make([]*node.HList, nBody),
+		closing:   make([]*node.HList, nBody),
+	}
+	_, tr.spacing = tbl.spacing()
+	for i := range tr.body {
+		tr.breakable[i] = true
+		for x := 0; x < tbl.nCol; x++ {
+			if tbl.cellMatrix[x][nHead+i].rowspan > 0 {
+				tr.breakable[i] = false
+			}
+		}
+		if !tbl.BorderSeparate {
+			if i > 0 && tr.breakable[i-1] {
+				tr.opening[i] = tbl.edgeBorders(nHead+i, true)
+			}
+			if tr.breakable[i] && i < nBody-1 {
+				tr.closing[i] = tbl.edgeBorders(nHead+i, false)
+			}
+		}
+	}
+	var wd bag.ScaledPoint
+	for _, hl := range rows {
+		wd = bag.Max(wd, hl.Width)
+	}
+	var err error
+	if tbl.ContinuedTop != nil {
+		if tr.continuedTop, err = tbl.doc.CreateVlist(tbl.ContinuedTop, wd); err != nil {
+			return nil, err
+		}
+	}
+	if tbl.ContinuedBottom != nil {
+		if tr.continuedBottom, err = tbl.doc.CreateVlist(tbl.ContinuedBottom, wd); err != nil {
+			return nil, err
+		}
+	}
+	return tr, nil
+}
+
+// vlist returns the table part with the body rows of tr. The header and
+// footer rows are copied. last is false if the part is followed by a break.
+func (tr *tableRows) vlist(last bool) *node.VList {
+	var head, tail node.Node
+	add := func(n node.Node) {
+		head = node.InsertAfter(head, tail, n)
+		tail = n
+	}
+	if tr.continued && tr.continuedTop != nil {
+		add(tr.continuedTop.Copy())
+	}
+	if tr.spacing != 0 {
+		g := node.NewGlue()
+		g.Width = tr.spacing
+		g.Attributes = node.H{"origin": "border spacing"}
+		add(g)
+	}
+	for _, hl := range tr.head {
+		add(hl.Copy())
+	}
+	if tr.continued && len(tr.opening) > 0 && tr.opening[0] != nil {
+		add(tr.opening[0].Copy())
+	}
+	for _, hl := range tr.body {
+		hl.SetPrev(nil)
+		hl.SetNext(nil)
+		add(hl)
+	}
+	if n := len(tr.closing); !last && n > 0 && tr.closing[n-1] != nil {
+		add(tr.closing[n-1].Copy())
+	}
+	for _, hl := range tr.foot {
+		add(hl.Copy())
+	}
+	if !last && tr.continuedBottom != nil {
+		add(tr.continuedBottom.Copy())
+	}
+	vl := node.Vpack(head)
+	vl.Attributes = node.H{"origin": "table", "table": tr}
+	return vl
+}
+
+// fixedHeight returns the height of the header and footer rows, of the
+// spacing above the first row and of the markers of a part which is followed
+// by a break.
+func (tr *tableRows) fixedHeight() bag.ScaledPoint {
+	sum := tr.spacing
+	for _, rows := range [][]*node.HList{tr.head, tr.foot} {
+		for _, hl := range rows {
+			sum += hl.Height + hl.Depth
+		}
+	}
+	if tr.continued {
+		if tr.continuedTop != nil {
+			sum += tr.continuedTop.Height + tr.continuedTop.Depth
+		}
+		sum += edgeHeight(tr.opening, 0)
+	}
+	if tr.continuedBottom != nil {
+		sum += tr.continuedBottom.Height + tr.continuedBottom.Depth
+	}
+	return sum
+}
+
+// minHeight returns the height of the smallest part of the table: the header
+// and footer rows and the body rows up to the first possible break.
+func (tr *tableRows) minHeight() bag.ScaledPoint {
+	sum := tr.fixedHeight()
+	for i, hl := range tr.body {
+		sum += hl.Height + hl.Depth
+		if tr.breakable[i] {
+			sum += edgeHeight(tr.closing, i)
+			break
+		}
+	}
+	return sum
+}
+
+// SplitTable breaks the table vl (a vertical list of BuildTable) between two
+// rows so that the first part is not higher than height. Both parts contain
+// the header and footer rows and at least one body row, a table is never
+// split right after the header. Rows connected by a cell with rowspan stay
+// together. SplitTable returns nil and vl if vl is not a table or if there is
+// no such break.
+func SplitTable(vl *node.VList, height bag.ScaledPoint) (*node.VList, *node.VList) {
+	tr, ok := vl.Attributes["table"].(*tableRows)
+	if !ok {
+		return nil, vl
+	}
+	k := 0
+	sum := tr.fixedHeight()
+	for i := 0; i < len(tr.body)-1; i++ {
+		if sum += tr.body[i].Height + tr.body[i].Depth; sum > height {
+			break
+		}
+		if tr.breakable[i] && sum+edgeHeight(tr.closing, i) <= height {
+			k = i + 1
+		}
+	}
+	if k == 0 {
+		return nil, vl
+	}
+	firstRows := *tr
+	firstRows.body, firstRows.breakable = tr.body[:k], tr.breakable[:k]
+	restRows := *tr
+	restRows.body, restRows.breakable = tr.body[k:], tr.breakable[k:]
+	if len(tr.opening) == len(tr.body) {
+		firstRows.opening, restRows.opening = tr.opening[:k], tr.opening[k:]
+		firstRows.closing, restRows.closing = tr.closing[:k], tr.closing[k:]
+	}
+	restRows.continued = true
+	first := firstRows.vlist(false)
+	rest := restRows.vlist(true)
+	first.Attributes = partAttributes(vl, first)
+	first.Attributes["table"] = &firstRows
+	rest.Attributes = partAttributes(vl, rest)
+	rest.Attributes["table"] = &restRows
+	return first, rest
+}
+
+// withTableRows returns the attributes with the rows of vl if vl is a table of
+// BuildTable, so the table can be split after the attributes are replaced.
+func withTableRows(attributes node.H, vl *node.VList) node.H {
+	if tr, ok := vl.Attributes["table"]; ok {
+		attributes["table"] = tr
+	}
+	return attributes
+}
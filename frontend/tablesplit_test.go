@@ -0,0 +1,83 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/backend/bag"
+	"github.com/speedata/boxesandglue/backend/node"
+)
+
+func TestSplitTable(t *testing.T) {
+	row := func(ht int) *node.HList {
+		hl := node.NewHList()
+		hl.Width = 100 * bag.Factor
+		hl.Height = bag.ScaledPoint(ht) * bag.Factor
+		return hl
+	}
+	// a header row, six body rows (rows 3 and 4 are connected by a rowspan)
+	// and a footer row
+	tr := &tableRows{
+		head:      []*node.HList{row(5)},
+		foot:      []*node.HList{row(5)},
+		breakable: []bool{true, true, false, true, true, true},
+	}
+	for i := 0; i < 6; i++ {
+		tr.body = append(tr.body, row(10))
+	}
+	vl := tr.vlist(true)
+	vl.Attributes["x"] = bag.ScaledPoint(0)
+
+	// the break after row 3 is not allowed
+	first, rest := SplitTable(vl, 45*bag.Factor)
+	if first == nil {
+		t.Fatal("SplitTable() does not split")
+	}
+	if got := len(first.Attributes["table"].(*tableRows).body); got != 2 {
+		t.Errorf("first part has %d body rows, want 2", got)
+	}
+	if got, want := first.Attributes["height"], 30*bag.Factor; got != want {
+		t.Errorf("height of the first part = %v, want %s", got, want)
+	}
+	if got, want := first.Attributes["x"], bag.ScaledPoint(0); got != want {
+		t.Errorf("x of the first part = %v, want %s", got, want)
+	}
+	// header, four body rows and footer
+	if got, want := rest.Height+rest.Depth, 50*bag.Factor; got != want {
+		t.Errorf("height of the rest = %s, want %s", got, want)
+	}
+	if n := node.Tail(rest.List); n == rest.List || n == nil {
+		t.Error("rest has no footer row")
+	}
+	// no room for a body row after the header
+	if first, rest := SplitTable(rest, 15*bag.Factor); first != nil || rest == nil {
+		t.Error("SplitTable() splits after the header")
+	}
+	if got, want := LinesHeight(vl, 2), 20*bag.Factor; got != want {
+		t.Errorf("LinesHeight() = %s, want %s", got, want)
+	}
+
+	// the split edges get the other half of the collapsed borders
+	edge := func() *node.HList {
+		hl := node.NewHList()
+		hl.Width = 100 * bag.Factor
+		hl.Height = bag.Factor
+		return hl
+	}
+	tr.opening = make([]*node.HList, len(tr.body))
+	tr.closing = make([]*node.HList, len(tr.body))
+	for i := range tr.body {
+		tr.opening[i], tr.closing[i] = edge(), edge()
+	}
+	vl = tr.vlist(true)
+	vl.Attributes["x"] = bag.ScaledPoint(0)
+	first, rest = SplitTable(vl, 45*bag.Factor)
+	if first == nil {
+		t.Fatal("SplitTable() with edges does not split")
+	}
+	if got, want := first.Height+first.Depth, 31*bag.Factor; got != want {
+		t.Errorf("height of the first part with edges = %s, want %s", got, want)
+	}
+	if got, want := rest.Height+rest.Depth, 51*bag.Factor; got != want {
+		t.Errorf("height of the rest with edges = %s, want %s", got, want)
+	}
+}
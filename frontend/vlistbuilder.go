@@ -19,6 +19,9 @@ type VlistInfo struct {
 	height       bag.ScaledPoint
 	hv           HTMLValues
 	debug        string
+	// positioned contains the absolutely positioned boxes which are placed
+	// in an ancestor or on the page.
+	positioned []*PositionedBox
 }
 
 // CreateVlist converts the te into a big vlist.
@@ -33,7 +36,13 @@ func (fe *Document) CreateVlist(te *Text, wd bag.ScaledPoint) (*node.VList, erro
 	for _, n := range info.Pagebox {
 		switch t := n.(type) {
 		case *node.StartStop:
-			// ignore for now - should be used for frames
+			// balanced columns without a height limit, other start stop
+			// nodes are ignored for now - should be used for frames
+			if cs, ok := t.Attributes["columns"].(*ColumnSet); ok {
+				vl, _ := cs.Distribute(cs.List.Height + cs.List.Depth)
+				vl.ShiftX = cs.X
+				list = node.InsertAfter(list, node.Tail(list), vl)
+			}
 		case *node.VList:
 			if xattr, ok := t.Attributes["x"].(bag.ScaledPoint); ok {
 				t.ShiftX = xattr
@@ -45,9 +54,55 @@ func (fe *Document) CreateVlist(te *Text, wd bag.ScaledPoint) (*node.VList, erro
 	return node.Vpack(list), nil
 }
 
-// BuildVlistInternal does this and that
+// BuildVlistInternal formats te with the given width at the horizontal
+// position x and returns the page box entries (vertical lists and start stop
+// nodes for columns and positioned boxes) for the page breaker. The
+// positioned boxes without a positioned ancestor are placed relative to the
+// page. The shiftDown is the distance from the top of the frame to the top of
+// the contents of te, which is the origin of the baseline grid
+// (SettingBaselineGrid).
 func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.ScaledPoint, shiftDown bag.ScaledPoint) (*VlistInfo, error) {
+	info, err := fe.buildVlist(te, width, x, shiftDown, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The remaining positioned boxes are placed relative to the page where
+	// they appear in the flow. The position nodes of the boxes placed in a
+	// positioned ancestor are removed. Boxes without a position node (in flex
+	// or grid items for example) are placed relative to the first page.
+	remaining := make(map[*PositionedBox]bool, len(info.positioned))
+	for _, pb := range info.positioned {
+		remaining[pb] = true
+	}
+	var pagebox []node.Node
+	for _, n := range info.Pagebox {
+		if start, ok := n.(*node.StartStop); ok {
+			if pb, ok := start.Attributes["position"].(*PositionedBox); ok {
+				if !remaining[pb] {
+					continue
+				}
+				delete(remaining, pb)
+			}
+		}
+		pagebox = append(pagebox, n)
+	}
+	var positioned []node.Node
+	for _, pb := range info.positioned {
+		if remaining[pb] {
+			start := node.NewStartStop()
+			start.Attributes = node.H{"position": pb}
+			positioned = append(positioned, start)
+		}
+	}
+	info.Pagebox = append(positioned, pagebox...)
+	return info, nil
+}
+
+// buildVlist is BuildVlistInternal with the floating boxes which were placed
+// before te. The lines of te flow around them.
+func (fe *Document) buildVlist(te *Text, width bag.ScaledPoint, x bag.ScaledPoint, shiftDown bag.ScaledPoint, floats floatList) (*VlistInfo, error) {
 	hv := SettingsToValues(te.Settings)
+	grid, _ := te.Settings[SettingBaselineGrid].(bag.ScaledPoint)
 	hsize := width - hv.MarginLeft - hv.MarginRight - hv.BorderLeftWidth - hv.BorderRightWidth - hv.PaddingLeft - hv.PaddingRight
 	x += hv.MarginLeft
 
@@ -76,13 +131,104 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 	var prevMB, height bag.ScaledPoint
 	if bx, ok := te.Settings[SettingBox]; ok && bx.(bool) {
 		// a box, containing one or more item (a div for example)
+		contentX := x + hv.BorderLeftWidth + hv.PaddingLeft
+		if fc, ok := te.Settings[SettingFlex].(FlexContainer); ok {
+			if err := fe.buildFlex(te, fc, ret, hsize, contentX, shiftDown); err != nil {
+				return nil, err
+			}
+			if err := fe.containPositioned(te, ret, hv, contentX, hsize, ret.height); err != nil {
+				return nil, err
+			}
+			ret.x = x
+			ret.hsize = hsize
+			ret.hv = hv
+			return ret, nil
+		}
+		if gc, ok := te.Settings[SettingGrid].(GridContainer); ok {
+			if err := fe.buildGrid(te, gc, ret, hsize, contentX, shiftDown); err != nil {
+				return nil, err
+			}
+			if err := fe.containPositioned(te, ret, hv, contentX, hsize, ret.height); err != nil {
+				return nil, err
+			}
+			ret.x = x
+			ret.hsize = hsize
+			ret.hv = hv
+			return ret, nil
+		}
+		// the items of a multi-column element are collected and placed in
+		// columns, except for the items which span all columns.
+		columns := newColumnBuilder(te, hsize)
+		// the floating boxes of this box are placed after the inherited
+		// ones.
+		inherited := len(floats)
+		var positioned []*PositionedBox
 		for _, itm := range te.Items {
 			switch textItem := itm.(type) {
 			case *Text:
-				info, err := fe.BuildVlistInternal(textItem, hsize, x+hv.BorderLeftWidth+hv.PaddingLeft, shiftDown)
+				if name, ok := textItem.Settings[SettingRunning].(string); ok {
+					start := node.NewStartStop()
+					start.Attributes = node.H{"running": &RunningElement{Name: name, Text: textItem}}
+					ret.Pagebox = append(ret.Pagebox, start)
+					continue
+				}
+				pos, _ := textItem.Settings[SettingPosition].(Position)
+				if pos.outOfFlow() {
+					pb := &PositionedBox{Text: textItem, Position: pos}
+					positioned = append(positioned, pb)
+					// without a positioned ancestor the box is placed on the
+					// page of this position in the flow
+					start := node.NewStartStop()
+					start.Attributes = node.H{"position": pb}
+					ret.Pagebox = append(ret.Pagebox, start)
+					continue
+				}
+				childWidth, childX, childTop := hsize, contentX, height
+				inColumns := columns != nil && !isColumnSpan(textItem)
+				if inColumns {
+					childWidth, childX, childTop = columns.width, 0, columns.height
+				} else {
+					childWidth, childX = fe.pageArea(te, textItem, childWidth, childX)
+				}
+				if !inColumns && columns != nil && len(columns.pagebox) > 0 {
+					start, colHeight := columns.flush(fe, contentX)
+					ret.Pagebox = append(ret.Pagebox, start)
+					height += colHeight
+					childTop = height
+					prevMB = 0
+				}
+				var childFloats floatList
+				if !inColumns {
+					if side, _ := textItem.Settings[SettingFloat].(Float); side != FloatNone {
+						fb, vl, err := fe.buildFloat(textItem, side, hsize, contentX, shiftDown+height, floats)
+						if err != nil {
+							return nil, err
+						}
+						ret.Pagebox = append(ret.Pagebox, vl)
+						floats = append(floats, fb)
+						continue
+					}
+					if c, ok := textItem.Settings[SettingClear].(Clear); ok {
+						if y := floats.clearance(c) - shiftDown; y > height {
+							height = y
+							childTop = height
+							prevMB = 0
+						}
+					}
+					childFloats = floats
+				}
+				if grid > 0 {
+					if _, ok := textItem.Settings[SettingBaselineGrid]; !ok {
+						textItem.Settings[SettingBaselineGrid] = grid
+					}
+				}
+				chv := SettingsToValues(textItem.Settings)
+				offset := shiftDown + childTop + bag.Max(0, chv.MarginTop-prevMB) + chv.PaddingTop + chv.BorderTopWidth
+				info, err := fe.buildVlist(textItem, childWidth, childX, offset, childFloats.below(offset))
 				if err != nil {
 					return nil, err
 				}
+				positioned = append(positioned, info.positioned...)
 
 				// margin collapse
 				if prevMB >= info.marginTop {
@@ -90,10 +236,17 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 				} else {
 					info.marginTop -= prevMB
 				}
-				height += info.height
-				height += info.marginTop + info.marginBottom
-				height += info.hv.PaddingTop + info.hv.PaddingBottom + info.hv.BorderTopWidth + info.hv.BorderBottomWidth
-
+				boxHeight := info.height + info.marginTop + info.marginBottom
+				boxHeight += info.hv.PaddingTop + info.hv.PaddingBottom + info.hv.BorderTopWidth + info.hv.BorderBottomWidth
+				pagebox := &ret.Pagebox
+				if inColumns {
+					columns.height += boxHeight
+					pagebox = &columns.pagebox
+				} else {
+					height += boxHeight
+				}
+				stringSet := stringSetNode(textItem)
+				first := len(*pagebox)
 				start := node.NewStartStop()
 				start.Attributes = node.H{
 					"shiftDown": info.marginTop,
@@ -102,12 +255,16 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 					"hsize":     info.hsize,
 					"x":         info.x,
 				}
-				ret.Pagebox = append(ret.Pagebox, start)
+				pageAttributes(textItem, start.Attributes)
+				*pagebox = append(*pagebox, start)
+				if stringSet != nil {
+					*pagebox = append(*pagebox, stringSet)
+				}
 
 				if info.vl == nil {
-					ret.Pagebox = append(ret.Pagebox, info.Pagebox...)
+					*pagebox = append(*pagebox, info.Pagebox...)
 				} else {
-					ret.Pagebox = append(ret.Pagebox, info.vl)
+					*pagebox = append(*pagebox, info.vl)
 				}
 
 				stop := node.NewStartStop()
@@ -117,10 +274,14 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 					"hv":        info.hv,
 				}
 				stop.StartNode = start
-				ret.Pagebox = append(ret.Pagebox, stop)
+				breakAfterAttributes(textItem, stop.Attributes)
+				*pagebox = append(*pagebox, stop)
+				if pos.Scheme == PositionRelative {
+					shiftRelative((*pagebox)[first:], pos, childWidth)
+				}
 				prevMB = info.marginBottom
 			case string:
-				vl, err := fe.createVList(te, hsize, hv)
+				vl, err := fe.createVList(te, hsize, hv, shiftDown, floats.exclusions(contentX, hsize, shiftDown))
 				if err != nil {
 					return nil, err
 				}
@@ -142,12 +303,13 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 					}
 				}
 
-				vl.Attributes = node.H{
-					"height": vl.Height + vl.Depth,
+				ret.height = blockHeight(vl, grid, shiftDown)
+				vl.Attributes = withTableRows(node.H{
+					"height": ret.height,
 					"x":      x + hv.PaddingLeft + hv.BorderLeftWidth,
 					"hsize":  hsize,
-				}
-				ret.height = vl.Height + vl.Depth
+				}, vl)
+				lineAttributes(te, vl.Attributes)
 				ret.vl = vl
 				ret.hv = hv
 				ret.hsize = hsize
@@ -158,6 +320,19 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 				fmt.Println("~~> unknown type", textItem)
 			}
 		}
+		if columns != nil && len(columns.pagebox) > 0 {
+			start, colHeight := columns.flush(fe, contentX)
+			ret.Pagebox = append(ret.Pagebox, start)
+			height += colHeight
+		}
+		// the box contains its floating boxes
+		for _, f := range floats[inherited:] {
+			height = bag.Max(height, f.bottom-shiftDown)
+		}
+		ret.positioned = positioned
+		if err := fe.containPositioned(te, ret, hv, contentX, hsize, height); err != nil {
+			return nil, err
+		}
 		ret.x = x
 		ret.hsize = hsize
 		ret.height = height
@@ -168,7 +343,22 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 	// not a box
 	//
 	// something like a p tag that contains some stuff to be typeset.
-	vl, err := fe.createVList(te, hsize, hv)
+	if columns := newColumnBuilder(te, hsize); columns != nil {
+		vl, err := fe.createVList(te, columns.width, hv, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		vl.Attributes = node.H{"height": vl.Height + vl.Depth}
+		columns.pagebox = append(columns.pagebox, vl)
+		start, colHeight := columns.flush(fe, x+hv.PaddingLeft+hv.BorderLeftWidth)
+		ret.Pagebox = append(ret.Pagebox, start)
+		ret.height = colHeight
+		ret.hv = hv
+		ret.hsize = hsize
+		ret.x = x
+		return ret, nil
+	}
+	vl, err := fe.createVList(te, hsize, hv, shiftDown, floats.exclusions(x+hv.PaddingLeft+hv.BorderLeftWidth, hsize, shiftDown))
 	if err != nil {
 		return nil, err
 	}
@@ -190,12 +380,13 @@ func (fe *Document) BuildVlistInternal(te *Text, width bag.ScaledPoint, x bag.Sc
 		}
 	}
 
-	vl.Attributes = node.H{
-		"height": vl.Height + vl.Depth,
+	ret.height = blockHeight(vl, grid, shiftDown)
+	vl.Attributes = withTableRows(node.H{
+		"height": ret.height,
 		"x":      x + hv.PaddingLeft + hv.BorderLeftWidth,
 		"hsize":  hsize,
-	}
-	ret.height = vl.Height + vl.Depth
+	}, vl)
+	lineAttributes(te, vl.Attributes)
 	ret.vl = vl
 	ret.hv = hv
 	ret.hsize = hsize
@@ -231,7 +422,7 @@ func fixupWidth(te *Text, hsize bag.ScaledPoint, hv HTMLValues) error {
 					}
 				}
 			}
-		case *Table:
+		case *Table, node.Node:
 			// ignore
 		default:
 			return fmt.Errorf("fixupWidth: unknown item %T", t)
@@ -240,18 +431,37 @@ func fixupWidth(te *Text, hsize bag.ScaledPoint, hv HTMLValues) error {
 	return nil
 }
 
-func (fe *Document) createVList(te *Text, wd bag.ScaledPoint, hv HTMLValues) (*node.VList, error) {
+// blockHeight returns the vertical space of the vlist vl. On a baseline grid
+// (grid > 0) the space is rounded up to the next grid line, the origin of the
+// grid is offset above vl. The paragraphs on the grid end at their last
+// baseline, so the depth of the last line hangs below the grid line.
+func blockHeight(vl *node.VList, grid, offset bag.ScaledPoint) bag.ScaledPoint {
+	if grid <= 0 {
+		return vl.Height + vl.Depth
+	}
+	lines := (offset + vl.Height + grid - 1) / grid
+	return lines*grid - offset
+}
+
+func (fe *Document) createVList(te *Text, wd bag.ScaledPoint, hv HTMLValues, offset bag.ScaledPoint, exclusions ExclusionFunc) (*node.VList, error) {
 	if err := fixupWidth(te, wd, hv); err != nil {
 		return nil, err
 	}
-	vl, _, err := fe.FormatParagraph(te, wd)
+	var opts []TypesettingOption
+	if exclusions != nil {
+		opts = append(opts, Exclusions(exclusions))
+	}
+	if grid, ok := te.Settings[SettingBaselineGrid].(bag.ScaledPoint); ok && grid > 0 {
+		opts = append(opts, BaselineGrid(grid, offset))
+	}
+	vl, _, err := fe.FormatParagraph(te, wd, opts...)
 	// FIXME: vl can be nil if empty (empty li for example)
 	if err != nil {
 		return nil, err
 	}
-	vl.Attributes = node.H{
+	vl.Attributes = withTableRows(node.H{
 		"hv":    hv,
 		"hsize": wd,
-	}
+	}, vl)
 	return vl, nil
 }
@@ -0,0 +1,427 @@
+package htmlstyle
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/speedata/boxesandglue/frontend"
+	"golang.org/x/net/html"
+)
+
+// Counters contains the CSS counters of a document. Each name has a stack of
+// nested counter instances, the last one is the innermost.
+type Counters map[string][]int
+
+// value returns the value of the innermost counter name or 0 if there is no
+// such counter.
+func (c Counters) value(name string) int {
+	if s := c[name]; len(s) > 0 {
+		return s[len(s)-1]
+	}
+	return 0
+}
+
+// counterValue is a counter name with a value of counter-reset,
+// counter-increment or counter-set.
+type counterValue struct {
+	name  string
+	value int
+}
+
+// parseCounters returns the counters of a value such as "chapter section 2".
+// Counters without a number get the value def.
+func parseCounters(v string, def int) []counterValue {
+	var ret []counterValue
+	for _, field := range strings.Fields(v) {
+		if n, err := strconv.Atoi(field); err == nil && len(ret) > 0 {
+			ret[len(ret)-1].value = n
+			continue
+		}
+		if field == "none" {
+			continue
+		}
+		ret = append(ret, counterValue{name: field, value: def})
+	}
+	return ret
+}
+
+// apply evaluates counter-reset, counter-set and counter-increment of the
+// styles of an element. created contains the counters which have been
+// instantiated by the preceding siblings, a reset replaces these counters.
+// New counter instances are added to created. Counters which are set or
+// incremented without a counter-reset are instantiated for the whole
+// document, so they continue in the next HTML chunk.
+func (c Counters) apply(styles map[string]string, created map[string]bool) {
+	for _, cv := range parseCounters(styles["counter-reset"], 0) {
+		if created[cv.name] {
+			c[cv.name][len(c[cv.name])-1] = cv.value
+		} else {
+			c[cv.name] = append(c[cv.name], cv.value)
+			created[cv.name] = true
+		}
+	}
+	for _, cv := range parseCounters(styles["counter-set"], 0) {
+		if len(c[cv.name]) == 0 {
+			c[cv.name] = []int{0}
+		}
+		c[cv.name][len(c[cv.name])-1] = cv.value
+	}
+	for _, cv := range parseCounters(styles["counter-increment"], 1) {
+		if len(c[cv.name]) == 0 {
+			c[cv.name] = []int{0}
+		}
+		c[cv.name][len(c[cv.name])-1] += cv.value
+	}
+}
+
+// release removes the counter instances in created.
+func (c Counters) release(created map[string]bool) {
+	for name := range created {
+		c[name] = c[name][:len(c[name])-1]
+	}
+}
+
+var (
+	romanValues  = []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	romanSymbols = []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+)
+
+// alphabetic returns n in the alphabetic system of the letters (a, b, ..., z,
+// aa, ab, ...).
+func alphabetic(n int, letters []rune) string {
+	var ret []rune
+	for n > 0 {
+		n--
+		ret = append([]rune{letters[n%len(letters)]}, ret...)
+		n /= len(letters)
+	}
+	return string(ret)
+}
+
+// formatCounter returns the value n in the counter style (list-style-type)
+// such as decimal or lower-roman.
+func formatCounter(n int, style string) string {
+	switch style {
+	case "none":
+		return ""
+	case "disc":
+		return "•"
+	case "circle":
+		return "◦"
+	case "square":
+		return "□"
+	case "decimal-leading-zero":
+		if n >= 0 && n < 10 {
+			return "0" + strconv.Itoa(n)
+		}
+	case "lower-roman", "upper-roman":
+		if n > 0 && n < 4000 {
+			var b strings.Builder
+			for i, v := range romanValues {
+				for ; n >= v; n -= v {
+					b.WriteString(romanSymbols[i])
+				}
+			}
+			if style == "upper-roman" {
+				return strings.ToUpper(b.String())
+			}
+			return b.String()
+		}
+	case "lower-alpha", "lower-latin", "upper-alpha", "upper-latin":
+		if n > 0 {
+			str := alphabetic(n, []rune("abcdefghijklmnopqrstuvwxyz"))
+			if strings.HasPrefix(style, "upper") {
+				return strings.ToUpper(str)
+			}
+			return str
+		}
+	case "lower-greek":
+		if n > 0 {
+			return alphabetic(n, []rune("αβγδεζηθικλμνξοπρστυφχψω"))
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+// unquote returns the contents of the string str. The strings of the CSS
+// values are in Go syntax (see csshtml), the escapes are already resolved.
+// Other values are returned unchanged.
+func unquote(str string) string {
+	if len(str) < 2 || (str[0] != '"' && str[0] != '\'') || str[len(str)-1] != str[0] {
+		return str
+	}
+	if s, err := strconv.Unquote(str); err == nil {
+		str = s
+	} else {
+		str = str[1 : len(str)-1]
+	}
+	// line breaks are not supported in generated content
+	return strings.ReplaceAll(str, "\n", " ")
+}
+
+// splitContent splits the value of the content property into its parts:
+// strings, functions like counter(chapter) and keywords. Separators in
+// strings and in the arguments of the functions are not split.
+func splitContent(v string) []string {
+	var parts []string
+	var quote rune
+	depth := 0
+	start := -1
+	for i, r := range v {
+		switch {
+		case quote != 0:
+			if r == quote && v[i-1] != '\\' {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if start < 0 {
+				start = i
+			}
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case unicode.IsSpace(r) && depth == 0:
+			if start >= 0 {
+				parts = append(parts, v[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, v[start:])
+	}
+	return parts
+}
+
+// splitArguments returns the name and the arguments of a CSS function such as
+// counters(section, ".", lower-roman). The string arguments are unquoted.
+func splitArguments(part string) (string, []string) {
+	open := strings.IndexByte(part, '(')
+	if open < 0 || !strings.HasSuffix(part, ")") {
+		return part, nil
+	}
+	var args []string
+	var quote byte
+	inner := part[open+1 : len(part)-1]
+	start := 0
+	for i := 0; i < len(inner); i++ {
+		switch {
+		case quote != 0:
+			if inner[i] == quote {
+				quote = 0
+			}
+		case inner[i] == '"' || inner[i] == '\'':
+			quote = inner[i]
+		case inner[i] == ',':
+			args = append(args, unquote(strings.TrimSpace(inner[start:i])))
+			start = i + 1
+		}
+	}
+	args = append(args, unquote(strings.TrimSpace(inner[start:])))
+	return part[:open], args
+}
+
+// A ContentFunc returns the text of a function in the content property which
+// depends on the context, such as string(chapter) in a page margin box. args
+// are the unquoted arguments of the function.
+type ContentFunc func(args []string) string
+
+// ContentString returns the text of the content property value v. Counters
+// are taken from c and attr() from the attributes of the element. The
+// functions in funcs take precedence over the built-in functions.
+func (c Counters) ContentString(v string, attributes map[string]string, funcs map[string]ContentFunc) string {
+	var b strings.Builder
+	for _, part := range splitContent(v) {
+		if r, _ := utf8.DecodeRuneInString(part); r == '"' || r == '\'' {
+			b.WriteString(unquote(part))
+			continue
+		}
+		name, args := splitArguments(part)
+		if f, ok := funcs[name]; ok {
+			b.WriteString(f(args))
+			continue
+		}
+		switch name {
+		case "attr":
+			if len(args) > 0 {
+				b.WriteString(attributes[args[0]])
+			}
+		case "counter":
+			if len(args) > 0 {
+				style := "decimal"
+				if len(args) > 1 {
+					style = args[1]
+				}
+				b.WriteString(formatCounter(c.value(args[0]), style))
+			}
+		case "counters":
+			if len(args) > 1 {
+				style := "decimal"
+				if len(args) > 2 {
+					style = args[2]
+				}
+				values := c[args[0]]
+				if len(values) == 0 {
+					values = []int{0}
+				}
+				for i, n := range values {
+					if i > 0 {
+						b.WriteString(args[1])
+					}
+					b.WriteString(formatCounter(n, style))
+				}
+			}
+		case "open-quote":
+			b.WriteString("“")
+		case "close-quote":
+			b.WriteString("”")
+		}
+	}
+	return b.String()
+}
+
+// textContent returns the text of item and its descendants with collapsed
+// white space.
+func textContent(item *HTMLItem) string {
+	var b strings.Builder
+	var collect func(*HTMLItem)
+	collect = func(itm *HTMLItem) {
+		if itm.Typ == html.TextNode {
+			b.WriteString(itm.Data)
+			b.WriteString(" ")
+		}
+		for _, child := range itm.Children {
+			collect(child)
+		}
+	}
+	collect(item)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// stringSet returns the named strings which item assigns with the string-set
+// value v such as `chapter counter(chapter) ". " content()`. content() is
+// the text of item without the generated content, content(before) and
+// content(after) are the generated content of the pseudo elements with the
+// styles before and after.
+func (c Counters) stringSet(v string, item *HTMLItem, before, after map[string]string) []frontend.StringSet {
+	text := textContent(item)
+	funcs := map[string]ContentFunc{
+		"content": func(args []string) string {
+			if len(args) == 0 {
+				// content without parentheses
+				return text
+			}
+			var pseudo map[string]string
+			switch args[0] {
+			case "before":
+				pseudo = before
+			case "after":
+				pseudo = after
+			case "first-letter":
+				if r, size := utf8.DecodeRuneInString(text); r != utf8.RuneError {
+					return text[:size]
+				}
+				return ""
+			default:
+				return text
+			}
+			if content := pseudo["content"]; content != "" && content != "none" && content != "normal" {
+				return c.ContentString(content, item.Attributes, nil)
+			}
+			return ""
+		},
+	}
+	var ret []frontend.StringSet
+	parts := splitContent(v)
+	for len(parts) > 0 {
+		i := 0
+		for i < len(parts) && parts[i] != "," {
+			i++
+		}
+		// a name followed by the content list
+		if i > 1 {
+			ret = append(ret, frontend.StringSet{
+				Name:  parts[0],
+				Value: c.ContentString(strings.Join(parts[1:i], " "), item.Attributes, funcs),
+			})
+		}
+		if i == len(parts) {
+			break
+		}
+		parts = parts[i+1:]
+	}
+	return ret
+}
+
+// pseudoElement returns the ::before or ::after pseudo element of item with
+// the styles (without prefix) or nil if the pseudo element has no content.
+func (c Counters) pseudoElement(item *HTMLItem, styles map[string]string) *HTMLItem {
+	content, ok := styles["content"]
+	if !ok || content == "none" || content == "normal" {
+		return nil
+	}
+	dir := ModeHorizontal
+	if styles["display"] == "block" {
+		dir = ModeVertical
+	}
+	return &HTMLItem{
+		Typ:        html.ElementNode,
+		Data:       "span",
+		Dir:        dir,
+		Attributes: map[string]string{},
+		Styles:     styles,
+		Children:   []*HTMLItem{{Typ: html.TextNode, Data: c.ContentString(content, item.Attributes, nil)}},
+	}
+}
+
+// generateContent evaluates the counters of the children of item in document
+// order and inserts the ::before and ::after pseudo elements with their
+// generated content. The counters instantiated by a child are in scope for
+// the following siblings and their descendants.
+func generateContent(item *HTMLItem, c Counters) {
+	created := map[string]bool{}
+	for _, child := range item.Children {
+		if child.Typ != html.ElementNode || child.Styles["display"] == "none" {
+			continue
+		}
+		c.apply(child.Styles, created)
+		before := pseudoElementStyles(child.Styles, "before")
+		after := pseudoElementStyles(child.Styles, "after")
+		// the counters of the pseudo elements are in the scope of child
+		pseudoCreated := map[string]bool{}
+		var pre *HTMLItem
+		if before != nil {
+			c.apply(before, pseudoCreated)
+			pre = c.pseudoElement(child, before)
+		}
+		if v, ok := child.Styles["string-set"]; ok {
+			child.stringSet = c.stringSet(v, child, before, after)
+		}
+		generateContent(child, c)
+		if child.Dir == ModeHorizontal {
+			// the strings of inline elements are assigned with the
+			// surrounding block
+			item.stringSet = append(item.stringSet, child.stringSet...)
+			child.stringSet = nil
+		}
+		if pre != nil {
+			child.Children = append([]*HTMLItem{pre}, child.Children...)
+		}
+		if after != nil {
+			c.apply(after, pseudoCreated)
+			if pe := c.pseudoElement(child, after); pe != nil {
+				child.Children = append(child.Children, pe)
+			}
+		}
+		c.release(pseudoCreated)
+	}
+	c.release(created)
+}
@@ -0,0 +1,110 @@
+package htmlstyle
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/frontend"
+	"golang.org/x/net/html"
+)
+
+func TestFormatCounter(t *testing.T) {
+	testdata := []struct {
+		n     int
+		style string
+		want  string
+	}{
+		{3, "decimal", "3"},
+		{3, "decimal-leading-zero", "03"},
+		{1994, "upper-roman", "MCMXCIV"},
+		{4, "lower-roman", "iv"},
+		{28, "lower-alpha", "ab"},
+		{2, "lower-greek", "β"},
+		{2, "unknown", "2"},
+	}
+	for _, td := range testdata {
+		if got := formatCounter(td.n, td.style); got != td.want {
+			t.Errorf("formatCounter(%d, %s) = %q, want %q", td.n, td.style, got, td.want)
+		}
+	}
+}
+
+func TestContentString(t *testing.T) {
+	c := Counters{"chapter": {2}, "section": {1, 3}, "page": {4}, "pages": {12}}
+	attributes := map[string]string{"href": "https://example.com"}
+	testdata := []struct {
+		content string
+		want    string
+	}{
+		{`"Chapter " counter( chapter ) ": "`, "Chapter 2: "},
+		{`counters( section , "." )`, "1.3"},
+		{`counter( section , upper-roman ) " "`, "III "},
+		{`"(" attr( href ) ")"`, "(https://example.com)"},
+		{`open-quote "x" close-quote`, "“x”"},
+		{`"Page " counter( page , lower-roman ) " of " counter( pages )`, "Page iv of 12"},
+	}
+	for _, td := range testdata {
+		if got := c.ContentString(td.content, attributes, nil); got != td.want {
+			t.Errorf("ContentString(%s) = %q, want %q", td.content, got, td.want)
+		}
+	}
+}
+
+func TestGenerateContent(t *testing.T) {
+	heading := func(title string) *HTMLItem {
+		return &HTMLItem{
+			Typ:  html.ElementNode,
+			Data: "h2",
+			Styles: map[string]string{
+				"counter-increment": "section",
+				"before::content":   `counters( section , "." ) " "`,
+			},
+			Children: []*HTMLItem{{Typ: html.TextNode, Data: title}},
+		}
+	}
+	// two sections in the second chapter
+	chapter := &HTMLItem{
+		Typ:      html.ElementNode,
+		Data:     "div",
+		Styles:   map[string]string{"counter-reset": "section"},
+		Children: []*HTMLItem{heading("Download"), heading("Installation")},
+	}
+	root := &HTMLItem{Children: []*HTMLItem{chapter}}
+	c := Counters{"section": {2}}
+	generateContent(root, c)
+	for i, want := range []string{"2.1 ", "2.2 "} {
+		h := chapter.Children[i]
+		if len(h.Children) != 2 {
+			t.Fatalf("heading %d has %d children, want 2", i, len(h.Children))
+		}
+		if got := h.Children[0].Children[0].Data; got != want {
+			t.Errorf("heading %d ::before = %q, want %q", i, got, want)
+		}
+	}
+	if got := c["section"]; len(got) != 1 || got[0] != 2 {
+		t.Errorf("counters after the chapter = %v, want [2]", got)
+	}
+}
+
+func TestStringSet(t *testing.T) {
+	c := Counters{"chapter": {3}}
+	item := &HTMLItem{
+		Typ:      html.ElementNode,
+		Data:     "h1",
+		Children: []*HTMLItem{{Typ: html.TextNode, Data: " Running\n headers "}},
+	}
+	before := map[string]string{"content": `"Chapter " counter( chapter )`}
+	got := c.stringSet(`chapter content( ) , title content( before ) ": " content( first-letter )`, item, before, nil)
+	want := []frontend.StringSet{{Name: "chapter", Value: "Running headers"}, {Name: "title", Value: "Chapter 3: R"}}
+	if len(got) != len(want) {
+		t.Fatalf("stringSet() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stringSet()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+	// content without parentheses is the text of the element
+	if got := c.stringSet("chapter content", item, nil, nil); len(got) != 1 || got[0].Value != "Running headers" {
+		t.Errorf("stringSet(chapter content) = %v, want Running headers", got)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/speedata/boxesandglue/backend/bag"
 	"github.com/speedata/boxesandglue/backend/color"
@@ -33,6 +35,223 @@ func ParseVerticalAlign(align string, styles *FormattingStyles) frontend.Vertica
 	}
 }
 
+// parseInlineVerticalAlign parses the vertical-align property of images and
+// inline blocks. It returns a frontend.VerticalAlignment, the raise as a
+// bag.ScaledPoint or nil for the default alignment (baseline).
+func parseInlineVerticalAlign(align string, styles *FormattingStyles) any {
+	switch align {
+	case "", "inherit", "initial":
+		return nil
+	case "baseline":
+		return frontend.VAlignBaseline
+	case "top":
+		return frontend.VAlignTop
+	case "middle":
+		return frontend.VAlignMiddle
+	case "bottom":
+		return frontend.VAlignBottom
+	case "text-top":
+		return frontend.VAlignTextTop
+	case "text-bottom":
+		return frontend.VAlignTextBottom
+	case "sub":
+		return -1 * styles.Fontsize * 1000 / 5000
+	case "super":
+		return styles.Fontsize * 1000 / 5000
+	}
+	if strings.HasSuffix(align, "%") {
+		// percentages refer to the line height
+		lineheight := styles.lineheight
+		if lineheight == 0 {
+			lineheight = styles.Fontsize * 120 / 100
+		}
+		return ParseRelativeSize(align, lineheight, styles.DefaultFontSize)
+	}
+	return ParseRelativeSize(align, styles.Fontsize, styles.DefaultFontSize)
+}
+
+// parseHyphenateLimitChars parses the value of hyphenate-limit-chars (one to
+// three integers or auto).
+func parseHyphenateLimitChars(v string) frontend.HyphenateLimitChars {
+	var values []int
+	for _, f := range strings.Fields(v) {
+		// auto and invalid values are 0 (the language default)
+		i, _ := strconv.Atoi(f)
+		values = append(values, i)
+	}
+	var lc frontend.HyphenateLimitChars
+	switch len(values) {
+	case 1:
+		lc.Word = values[0]
+	case 2:
+		lc.Word, lc.Before, lc.After = values[0], values[1], values[1]
+	case 3:
+		lc.Word, lc.Before, lc.After = values[0], values[1], values[2]
+	}
+	return lc
+}
+
+// parseInitialLetter parses the value of initial-letter (normal or the size
+// in lines and the optional number of lines the letter sinks).
+func parseInitialLetter(v string) frontend.InitialLetter {
+	var il frontend.InitialLetter
+	fields := strings.Fields(v)
+	if len(fields) == 0 || fields[0] == "normal" {
+		return il
+	}
+	if size, err := strconv.ParseFloat(fields[0], 64); err == nil {
+		il.Size = size
+	}
+	if len(fields) > 1 {
+		// invalid values are 0 (the integer part of the size)
+		il.Sink, _ = strconv.Atoi(fields[1])
+	}
+	return il
+}
+
+// parseFlexAlign returns the value of align-items or align-self.
+func parseFlexAlign(v string) frontend.FlexAlign {
+	switch v {
+	case "stretch", "normal":
+		return frontend.FlexAlignStretch
+	case "flex-start", "start", "self-start", "baseline":
+		return frontend.FlexAlignStart
+	case "flex-end", "end", "self-end":
+		return frontend.FlexAlignEnd
+	case "center":
+		return frontend.FlexAlignCenter
+	}
+	return frontend.FlexAlignAuto
+}
+
+// parseBreak returns the value of break-before, break-after, break-inside or
+// the legacy page-break-* properties. Recto and verso pages are right and
+// left pages.
+func parseBreak(v string) frontend.Break {
+	switch v {
+	case "avoid", "avoid-page":
+		return frontend.BreakAvoid
+	case "page", "always":
+		return frontend.BreakPage
+	case "left", "verso":
+		return frontend.BreakLeft
+	case "right", "recto":
+		return frontend.BreakRight
+	}
+	return frontend.BreakAuto
+}
+
+// initFlexItem sets the initial values of the flex item properties when the
+// first one is found.
+func (is *FormattingStyles) initFlexItem() {
+	if !is.hasFlexItem {
+		is.flexItem = frontend.FlexItem{Shrink: 1}
+		is.hasFlexItem = true
+	}
+}
+
+// parseGridLine sets the start or the end of gl from the value of
+// grid-column-start, grid-row-end, ... Named lines are not supported (auto).
+func parseGridLine(v string, gl *frontend.GridLine, start bool) {
+	if fields := strings.Fields(v); len(fields) == 2 && fields[0] == "span" {
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			gl.Span = n
+		}
+		return
+	}
+	n, _ := strconv.Atoi(v)
+	if start {
+		gl.Start = n
+	} else {
+		gl.End = n
+	}
+}
+
+// parseGridTracks returns the tracks of grid-template-columns or
+// grid-template-rows such as "100pt 1fr minmax(2cm, 1fr) repeat(3, auto)".
+// Line names are ignored.
+func parseGridTracks(v string, cur, root bag.ScaledPoint) []frontend.GridTrack {
+	var tracks []frontend.GridTrack
+	var fields []string
+	var depth, start int
+	for i, r := range v + " " {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth--
+		case r == ' ' && depth == 0:
+			if start < i {
+				fields = append(fields, v[start:i])
+			}
+			start = i + 1
+		}
+	}
+	for _, field := range fields {
+		if strings.HasPrefix(field, "[") || field == "none" {
+			continue
+		}
+		open := strings.Index(field, "(")
+		if open < 0 || !strings.HasSuffix(field, ")") {
+			size := parseTrackSize(field, cur, root)
+			if _, ok := size.(frontend.GridFraction); ok {
+				tracks = append(tracks, frontend.GridTrack{Min: "auto", Max: size})
+			} else {
+				tracks = append(tracks, frontend.GridTrack{Min: size, Max: size})
+			}
+			continue
+		}
+		args := strings.SplitN(field[open+1:len(field)-1], ",", 2)
+		if len(args) != 2 {
+			continue
+		}
+		switch field[:open] {
+		case "minmax":
+			tracks = append(tracks, frontend.GridTrack{
+				Min: parseTrackSize(strings.TrimSpace(args[0]), cur, root),
+				Max: parseTrackSize(strings.TrimSpace(args[1]), cur, root),
+			})
+		case "repeat":
+			n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+			if err != nil {
+				// auto-fill and auto-fit are not supported
+				continue
+			}
+			repeated := parseGridTracks(strings.TrimSpace(args[1]), cur, root)
+			for i := 0; i < n; i++ {
+				tracks = append(tracks, repeated...)
+			}
+		}
+	}
+	return tracks
+}
+
+// parseTrackSize returns a track size for frontend.GridTrack.
+func parseTrackSize(v string, cur, root bag.ScaledPoint) any {
+	switch {
+	case v == "auto" || v == "min-content" || v == "max-content":
+		return v
+	case strings.HasSuffix(v, "fr"):
+		f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "fr"), 64)
+		return frontend.GridFraction(f)
+	case strings.HasSuffix(v, "%"):
+		return v
+	}
+	return ParseRelativeSize(v, cur, root)
+}
+
+// parseOffset returns the value of a box offset (top, right, bottom, left)
+// for frontend.Position: nil for auto, a percentage string or a length.
+func parseOffset(v string, cur, root bag.ScaledPoint) any {
+	if v == "auto" {
+		return nil
+	}
+	if strings.HasSuffix(v, "%") {
+		return v
+	}
+	return ParseRelativeSize(v, cur, root)
+}
+
 // ParseHorizontalAlign parses the input ("left","center") and returns the
 // HorizontalAlignment value.
 func ParseHorizontalAlign(align string, styles *FormattingStyles) frontend.HorizontalAlignment {
@@ -127,13 +346,109 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 		ih.Fontsize = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
 	}
 	for k, v := range attributes {
+		if strings.Contains(k, "::") {
+			// styles of pseudo elements are applied separately
+			continue
+		}
 		switch k {
 		case "font-size":
 			// already set
 		case "hyphens":
-			// ignore for now
+			switch v {
+			case "auto":
+				ih.hyphens = frontend.HyphensAuto
+			case "manual":
+				ih.hyphens = frontend.HyphensManual
+			case "none":
+				ih.hyphens = frontend.HyphensNone
+			}
+		case "hyphenate-limit-chars":
+			ih.hyphenateLimitChars = parseHyphenateLimitChars(v)
+		case "hyphenate-limit-lines":
+			if v == "no-limit" {
+				ih.hyphenateLimitLines = 0
+			} else if l, err := strconv.Atoi(v); err == nil {
+				ih.hyphenateLimitLines = l
+			}
+		case "-bag-hyphenate-character":
+			if v == "auto" {
+				ih.hyphenateCharacter = ""
+			} else {
+				ih.hyphenateCharacter = strings.Trim(v, `"'`)
+			}
 		case "display":
 			ih.Hide = (v == "none")
+			ih.inlineBlock = (v == "inline-block")
+			ih.flex = (v == "flex" || v == "inline-flex")
+			ih.grid = (v == "grid" || v == "inline-grid")
+		case "align-items":
+			ih.alignItems = parseFlexAlign(v)
+		case "align-self":
+			ih.initFlexItem()
+			ih.flexItem.AlignSelf = parseFlexAlign(v)
+		case "flex-basis":
+			ih.initFlexItem()
+			ih.flexItem.Basis = parseOffset(v, curFontSize, ih.DefaultFontSize)
+		case "flex-direction":
+			switch v {
+			case "row-reverse":
+				ih.flexDirection = frontend.FlexDirectionRowReverse
+			case "column":
+				ih.flexDirection = frontend.FlexDirectionColumn
+			case "column-reverse":
+				ih.flexDirection = frontend.FlexDirectionColumnReverse
+			default:
+				ih.flexDirection = frontend.FlexDirectionRow
+			}
+		case "flex-grow":
+			ih.initFlexItem()
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				ih.flexItem.Grow = f
+			}
+		case "flex-shrink":
+			ih.initFlexItem()
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				ih.flexItem.Shrink = f
+			}
+		case "flex-wrap":
+			ih.flexWrap = (v == "wrap" || v == "wrap-reverse")
+		case "justify-content":
+			switch v {
+			case "flex-end", "end", "right":
+				ih.justifyContent = frontend.FlexJustifyEnd
+			case "center":
+				ih.justifyContent = frontend.FlexJustifyCenter
+			case "space-between":
+				ih.justifyContent = frontend.FlexJustifySpaceBetween
+			case "space-around":
+				ih.justifyContent = frontend.FlexJustifySpaceAround
+			case "space-evenly":
+				ih.justifyContent = frontend.FlexJustifySpaceEvenly
+			default:
+				ih.justifyContent = frontend.FlexJustifyStart
+			}
+		case "grid-column-end":
+			ih.hasGridItem = true
+			parseGridLine(v, &ih.gridItem.Column, false)
+		case "grid-column-start":
+			ih.hasGridItem = true
+			parseGridLine(v, &ih.gridItem.Column, true)
+		case "grid-row-end":
+			ih.hasGridItem = true
+			parseGridLine(v, &ih.gridItem.Row, false)
+		case "grid-row-start":
+			ih.hasGridItem = true
+			parseGridLine(v, &ih.gridItem.Row, true)
+		case "grid-template-columns":
+			ih.gridColumns = parseGridTracks(v, curFontSize, ih.DefaultFontSize)
+		case "grid-template-rows":
+			ih.gridRows = parseGridTracks(v, curFontSize, ih.DefaultFontSize)
+		case "row-gap", "grid-row-gap":
+			if v == "normal" {
+				ih.rowGap = 0
+			} else {
+				ih.rowGap = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
+			}
 		case "background-color":
 			ih.BackgroundColor = df.GetColor(v)
 		case "border-right-width", "border-left-width", "border-top-width", "border-bottom-width":
@@ -161,15 +476,7 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 				ih.BorderBottomRightRadius = size
 			}
 		case "border-right-style", "border-left-style", "border-top-style", "border-bottom-style":
-			var sty frontend.BorderStyle
-			switch v {
-			case "none":
-				// default
-			case "solid":
-				sty = frontend.BorderStyleSolid
-			default:
-				// logger.Error(fmt.Sprintf("not implemented: border style %q", v))
-			}
+			sty := frontend.ParseBorderStyle(v)
 			switch k {
 			case "border-right-style":
 				ih.BorderRightStyle = sty
@@ -189,12 +496,93 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 			ih.BorderTopColor = df.GetColor(v)
 		case "border-bottom-color":
 			ih.BorderBottomColor = df.GetColor(v)
+		case "border-collapse":
+			ih.borderCollapse = v == "collapse"
 		case "border-spacing":
-			// ignore
+			// one value for both directions or the horizontal and the
+			// vertical spacing
+			if fields := strings.Fields(v); len(fields) > 0 {
+				ih.borderSpacingX = ParseRelativeSize(fields[0], curFontSize, ih.DefaultFontSize)
+				ih.borderSpacingY = ih.borderSpacingX
+				if len(fields) > 1 {
+					ih.borderSpacingY = ParseRelativeSize(fields[1], curFontSize, ih.DefaultFontSize)
+				}
+			}
+		case "box-decoration-break":
+			switch v {
+			case "clone":
+				ih.boxDecorationBreak = frontend.BoxDecorationBreakClone
+			default:
+				ih.boxDecorationBreak = frontend.BoxDecorationBreakSlice
+			}
+		case "break-after", "page-break-after":
+			ih.breakAfter = parseBreak(v)
+		case "break-before", "page-break-before":
+			ih.breakBefore = parseBreak(v)
+		case "break-inside", "page-break-inside":
+			if parseBreak(v) == frontend.BreakAvoid {
+				ih.breakInside = frontend.BreakAvoid
+			} else {
+				ih.breakInside = frontend.BreakAuto
+			}
+		case "clear":
+			switch v {
+			case "left":
+				ih.clear = frontend.ClearLeft
+			case "right":
+				ih.clear = frontend.ClearRight
+			case "both":
+				ih.clear = frontend.ClearBoth
+			default:
+				ih.clear = frontend.ClearNone
+			}
 		case "color":
 			ih.color = df.GetColor(v)
-		case "content":
-			// ignore
+		case "column-count":
+			if v == "auto" {
+				ih.columnCount = 0
+			} else if c, err := strconv.Atoi(v); err == nil {
+				ih.columnCount = c
+			}
+		case "column-gap", "grid-column-gap":
+			if v == "normal" {
+				ih.columnGap = nil
+			} else {
+				gap := ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
+				ih.columnGap = &gap
+			}
+		case "column-rule-color":
+			if v == "currentcolor" {
+				// the text color, see ApplySettings
+				ih.columnRule.Color = nil
+			} else {
+				ih.columnRule.Color = df.GetColor(v)
+			}
+		case "column-rule-style":
+			switch v {
+			case "none", "hidden":
+				ih.columnRule.Style = frontend.BorderStyleNone
+			case "solid":
+				ih.columnRule.Style = frontend.BorderStyleSolid
+			default:
+				bag.Logger.Warn("column-rule-style not implemented, using solid", "style", v)
+				ih.columnRule.Style = frontend.BorderStyleSolid
+			}
+		case "column-rule-width":
+			ih.columnRule.Width = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
+		case "column-span":
+			ih.columnSpan = (v == "all")
+		case "content", "counter-increment", "counter-reset", "counter-set", "string-set":
+			// generated content, see generateContent
+		case "float":
+			switch v {
+			case "left":
+				ih.float = frontend.FloatLeft
+			case "right":
+				ih.float = frontend.FloatRight
+			default:
+				ih.float = frontend.FloatNone
+			}
 		case "font-style":
 			switch v {
 			case "italic":
@@ -216,9 +604,30 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 				ih.fontfamily = df.FindFontFamily("serif")
 			}
 		case "hanging-punctuation":
-			switch v {
-			case "allow-end":
-				ih.hangingPunctuation = frontend.HangingPunctuationAllowEnd
+			ih.hangingPunctuation = 0
+			for _, val := range strings.Fields(v) {
+				switch val {
+				case "allow-end":
+					ih.hangingPunctuation |= frontend.HangingPunctuationAllowEnd
+				case "first":
+					ih.hangingPunctuation |= frontend.HangingPunctuationFirst
+				case "force-end":
+					ih.hangingPunctuation |= frontend.HangingPunctuationForceEnd
+				case "last":
+					ih.hangingPunctuation |= frontend.HangingPunctuationLast
+				case "none":
+					// nothing
+				default:
+					bag.Logger.Warn("unknown hanging-punctuation value", "value", val)
+				}
+			}
+		case "initial-letter":
+			ih.initialLetter = parseInitialLetter(v)
+		case "letter-spacing":
+			if v == "normal" {
+				ih.letterSpacing = 0
+			} else {
+				ih.letterSpacing = ParseRelativeSize(v, ih.Fontsize, ih.DefaultFontSize)
 			}
 		case "line-height":
 			ih.lineheight = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
@@ -240,6 +649,40 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 			ih.PaddingRight = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
 		case "padding-top":
 			ih.PaddingTop = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
+		case "orphans":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				ih.orphans = n
+			}
+		case "page":
+			if v != "auto" {
+				ih.page = v
+			}
+		case "position":
+			switch v {
+			case "relative":
+				ih.position.Scheme = frontend.PositionRelative
+			case "absolute":
+				ih.position.Scheme = frontend.PositionAbsolute
+			case "fixed":
+				ih.position.Scheme = frontend.PositionFixed
+			default:
+				ih.position.Scheme = frontend.PositionStatic
+				if name, ok := runningName(v); ok {
+					ih.running = name
+				}
+			}
+		case "widows":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				ih.widows = n
+			}
+		case "top":
+			ih.position.Top = parseOffset(v, curFontSize, ih.DefaultFontSize)
+		case "right":
+			ih.position.Right = parseOffset(v, curFontSize, ih.DefaultFontSize)
+		case "bottom":
+			ih.position.Bottom = parseOffset(v, curFontSize, ih.DefaultFontSize)
+		case "left":
+			ih.position.Left = parseOffset(v, curFontSize, ih.DefaultFontSize)
 		case "tab-size":
 			if ts, err := strconv.Atoi(v); err == nil {
 				ih.tabsizeSpaces = ts
@@ -248,28 +691,90 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 			}
 		case "text-align":
 			ih.Halign = ParseHorizontalAlign(v, ih)
-		case "text-decoration-style":
-			// not yet implemented
+		case "text-decoration-color":
+			ih.textDecorationColor = df.GetColor(v)
 		case "text-decoration-line":
+			// The lines of the ancestors are propagated and cannot be
+			// removed, the lines of the element are added.
+			for _, part := range strings.Fields(v) {
+				switch part {
+				case "underline":
+					ih.TextDecorationLine |= frontend.TextDecorationUnderline
+				case "overline":
+					ih.TextDecorationLine |= frontend.TextDecorationOverline
+				case "line-through":
+					ih.TextDecorationLine |= frontend.TextDecorationLineThrough
+				}
+			}
+		case "text-decoration-skip-ink":
+			ih.textDecorationSkipInk = (v != "none")
+		case "text-decoration-style":
 			switch v {
-			case "underline":
-				ih.TextDecorationLine = frontend.TextDecorationUnderline
+			case "solid":
+				ih.textDecorationStyle = frontend.TextDecorationStyleSolid
+			case "double":
+				ih.textDecorationStyle = frontend.TextDecorationStyleDouble
+			case "dotted":
+				ih.textDecorationStyle = frontend.TextDecorationStyleDotted
+			case "dashed":
+				ih.textDecorationStyle = frontend.TextDecorationStyleDashed
+			case "wavy":
+				ih.textDecorationStyle = frontend.TextDecorationStyleWavy
+			}
+		case "text-decoration-thickness":
+			switch v {
+			case "auto", "from-font":
+				ih.textDecorationThickness = 0
+			default:
+				ih.textDecorationThickness = ParseRelativeSize(v, ih.Fontsize, ih.DefaultFontSize)
+			}
+		case "text-underline-offset":
+			if v == "auto" {
+				ih.textUnderlineOffset = nil
+			} else {
+				offset := ParseRelativeSize(v, ih.Fontsize, ih.DefaultFontSize)
+				ih.textUnderlineOffset = &offset
 			}
 		case "text-indent":
 			ih.indent = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
 			ih.indentRows = 1
+		case "text-transform":
+			switch v {
+			case "none":
+				ih.textTransform = frontend.TextTransformNone
+			case "uppercase":
+				ih.textTransform = frontend.TextTransformUppercase
+			case "lowercase":
+				ih.textTransform = frontend.TextTransformLowercase
+			case "capitalize":
+				ih.textTransform = frontend.TextTransformCapitalize
+			}
 		case "user-select":
 			// ignore
 		case "vertical-align":
-			if v == "sub" {
-				ih.yoffset = -1 * ih.Fontsize * 1000 / 5000
-			} else if v == "super" {
-				ih.yoffset = ih.Fontsize * 1000 / 5000
+			ih.inlineVAlign = parseInlineVerticalAlign(v, ih)
+			// text is only raised or lowered
+			if raise, ok := ih.inlineVAlign.(bag.ScaledPoint); ok {
+				ih.yoffset = raise
 			}
 		case "width":
 			ih.width = v
+		case "word-spacing":
+			if v == "normal" {
+				ih.wordSpacing = 0
+			} else {
+				ih.wordSpacing = ParseRelativeSize(v, ih.Fontsize, ih.DefaultFontSize)
+			}
 		case "white-space":
 			ih.preserveWhitespace = (v == "pre")
+		case "-bag-baseline-grid":
+			if v == "none" {
+				ih.baselineGrid = 0
+			} else {
+				ih.baselineGrid = ParseRelativeSize(v, curFontSize, ih.DefaultFontSize)
+			}
+		case "-bag-margin-protrusion":
+			ih.marginProtrusion = (v == "auto")
 		case "-bag-font-expansion":
 			if strings.HasSuffix(v, "%") {
 				p := strings.TrimSuffix(v, "%")
@@ -290,6 +795,7 @@ func StylesToStyles(ih *FormattingStyles, attributes map[string]string, df *fron
 // FormattingStyles are HTML formatting styles.
 type FormattingStyles struct {
 	BackgroundColor         *color.Color
+	baselineGrid            bag.ScaledPoint
 	BorderLeftWidth         bag.ScaledPoint
 	BorderRightWidth        bag.ScaledPoint
 	BorderBottomWidth       bag.ScaledPoint
@@ -306,10 +812,38 @@ type FormattingStyles struct {
 	BorderRightStyle        frontend.BorderStyle
 	BorderBottomStyle       frontend.BorderStyle
 	BorderTopStyle          frontend.BorderStyle
+	borderCollapse          bool
+	borderSpacingX          bag.ScaledPoint
+	borderSpacingY          bag.ScaledPoint
+	boxDecorationBreak      frontend.BoxDecorationBreak
+	breakAfter              frontend.Break
+	breakBefore             frontend.Break
+	breakInside             frontend.Break
+	clear                   frontend.Clear
 	DefaultFontSize         bag.ScaledPoint
 	DefaultFontFamily       *frontend.FontFamily
 	color                   *color.Color
+	columnCount             int
+	columnGap               *bag.ScaledPoint
+	columnRule              frontend.ColumnRule
+	columnSpan              bool
+	flex                    bool
+	flexDirection           frontend.FlexDirection
+	flexItem                frontend.FlexItem
+	flexWrap                bool
+	float                   frontend.Float
+	grid                    bool
+	gridColumns             []frontend.GridTrack
+	gridItem                frontend.GridItem
+	gridRows                []frontend.GridTrack
+	hasGridItem             bool
+	hasFlexItem             bool
+	alignItems              frontend.FlexAlign
+	justifyContent          frontend.FlexJustify
+	rowGap                  bag.ScaledPoint
 	Hide                    bool
+	inlineBlock             bool
+	inlineVAlign            any
 	fontfamily              *frontend.FontFamily
 	fontfeatures            []string
 	Fontsize                bag.ScaledPoint
@@ -318,27 +852,46 @@ type FormattingStyles struct {
 	fontexpansion           *float64
 	Halign                  frontend.HorizontalAlignment
 	hangingPunctuation      frontend.HangingPunctuation
+	hyphenateCharacter      string
+	hyphenateLimitChars     frontend.HyphenateLimitChars
+	hyphenateLimitLines     int
+	hyphens                 frontend.Hyphens
 	indent                  bag.ScaledPoint
 	indentRows              int
+	initialLetter           frontend.InitialLetter
 	language                string
+	letterSpacing           bag.ScaledPoint
 	lineheight              bag.ScaledPoint
 	ListStyleType           string
 	marginBottom            bag.ScaledPoint
 	marginLeft              bag.ScaledPoint
+	marginProtrusion        bool
 	marginRight             bag.ScaledPoint
 	marginTop               bag.ScaledPoint
 	paddingInlineStart      bag.ScaledPoint
 	OlCounter               int
+	orphans                 int
 	PaddingBottom           bag.ScaledPoint
 	PaddingLeft             bag.ScaledPoint
 	PaddingRight            bag.ScaledPoint
 	PaddingTop              bag.ScaledPoint
+	page                    string
+	position                frontend.Position
+	running                 string
 	TextDecorationLine      frontend.TextDecorationLine
+	textDecorationColor     *color.Color
+	textDecorationSkipInk   bool
+	textDecorationStyle     frontend.TextDecorationStyle
+	textDecorationThickness bag.ScaledPoint
+	textTransform           frontend.TextTransform
+	textUnderlineOffset     *bag.ScaledPoint
 	preserveWhitespace      bool
 	tabsize                 bag.ScaledPoint
 	tabsizeSpaces           int
 	Valign                  frontend.VerticalAlignment
+	widows                  int
 	width                   string
+	wordSpacing             bag.ScaledPoint
 	yoffset                 bag.ScaledPoint
 }
 
@@ -350,25 +903,48 @@ func (is *FormattingStyles) Clone() *FormattingStyles {
 		newFontFeatures[i] = f
 	}
 	newis := &FormattingStyles{
-		color:              is.color,
-		DefaultFontSize:    is.DefaultFontSize,
-		DefaultFontFamily:  is.DefaultFontFamily,
-		fontexpansion:      is.fontexpansion,
-		fontfamily:         is.fontfamily,
-		fontfeatures:       newFontFeatures,
-		Fontsize:           is.Fontsize,
-		fontstyle:          is.fontstyle,
-		Fontweight:         is.Fontweight,
-		hangingPunctuation: is.hangingPunctuation,
-		language:           is.language,
-		lineheight:         is.lineheight,
-		ListStyleType:      is.ListStyleType,
-		OlCounter:          is.OlCounter,
-		preserveWhitespace: is.preserveWhitespace,
-		tabsize:            is.tabsize,
-		tabsizeSpaces:      is.tabsizeSpaces,
-		Valign:             is.Valign,
-		Halign:             is.Halign,
+		baselineGrid:        is.baselineGrid,
+		borderCollapse:      is.borderCollapse,
+		borderSpacingX:      is.borderSpacingX,
+		borderSpacingY:      is.borderSpacingY,
+		color:               is.color,
+		DefaultFontSize:     is.DefaultFontSize,
+		DefaultFontFamily:   is.DefaultFontFamily,
+		fontexpansion:       is.fontexpansion,
+		fontfamily:          is.fontfamily,
+		fontfeatures:        newFontFeatures,
+		Fontsize:            is.Fontsize,
+		fontstyle:           is.fontstyle,
+		Fontweight:          is.Fontweight,
+		hangingPunctuation:  is.hangingPunctuation,
+		hyphenateCharacter:  is.hyphenateCharacter,
+		hyphenateLimitChars: is.hyphenateLimitChars,
+		hyphenateLimitLines: is.hyphenateLimitLines,
+		hyphens:             is.hyphens,
+		language:            is.language,
+		letterSpacing:       is.letterSpacing,
+		lineheight:          is.lineheight,
+		marginProtrusion:    is.marginProtrusion,
+		ListStyleType:       is.ListStyleType,
+		OlCounter:           is.OlCounter,
+		orphans:             is.orphans,
+		page:                is.page,
+		preserveWhitespace:  is.preserveWhitespace,
+		tabsize:             is.tabsize,
+		tabsizeSpaces:       is.tabsizeSpaces,
+		textTransform:       is.textTransform,
+		Valign:              is.Valign,
+		Halign:              is.Halign,
+		widows:              is.widows,
+		wordSpacing:         is.wordSpacing,
+		// text decorations are not inherited but propagated to the
+		// descendants.
+		TextDecorationLine:      is.TextDecorationLine,
+		textDecorationColor:     is.textDecorationColor,
+		textDecorationSkipInk:   is.textDecorationSkipInk,
+		textDecorationStyle:     is.textDecorationStyle,
+		textDecorationThickness: is.textDecorationThickness,
+		textUnderlineOffset:     is.textUnderlineOffset,
 	}
 	return newis
 }
@@ -376,10 +952,22 @@ func (is *FormattingStyles) Clone() *FormattingStyles {
 // ApplySettings converts the inheritable settings to boxes and glue text
 // settings.
 func ApplySettings(settings frontend.TypesettingSettings, ih *FormattingStyles) {
+	if ih.breakAfter != frontend.BreakAuto {
+		settings[frontend.SettingBreakAfter] = ih.breakAfter
+	}
+	if ih.breakBefore != frontend.BreakAuto {
+		settings[frontend.SettingBreakBefore] = ih.breakBefore
+	}
+	if ih.breakInside != frontend.BreakAuto {
+		settings[frontend.SettingBreakInside] = ih.breakInside
+	}
 	if ih.Fontweight > 0 {
 		settings[frontend.SettingFontWeight] = ih.Fontweight
 	}
 	settings[frontend.SettingBackgroundColor] = ih.BackgroundColor
+	if ih.baselineGrid > 0 {
+		settings[frontend.SettingBaselineGrid] = ih.baselineGrid
+	}
 	settings[frontend.SettingBorderTopWidth] = ih.BorderTopWidth
 	settings[frontend.SettingBorderLeftWidth] = ih.BorderLeftWidth
 	settings[frontend.SettingBorderRightWidth] = ih.BorderRightWidth
@@ -396,7 +984,61 @@ func ApplySettings(settings frontend.TypesettingSettings, ih *FormattingStyles)
 	settings[frontend.SettingBorderTopRightRadius] = ih.BorderTopRightRadius
 	settings[frontend.SettingBorderBottomLeftRadius] = ih.BorderBottomLeftRadius
 	settings[frontend.SettingBorderBottomRightRadius] = ih.BorderBottomRightRadius
+	settings[frontend.SettingBoxDecorationBreak] = ih.boxDecorationBreak
+	if ih.clear != frontend.ClearNone {
+		settings[frontend.SettingClear] = ih.clear
+	}
 	settings[frontend.SettingColor] = ih.color
+	if ih.columnCount > 1 {
+		settings[frontend.SettingColumnCount] = ih.columnCount
+		// normal is 1em
+		gap := ih.Fontsize
+		if ih.columnGap != nil {
+			gap = *ih.columnGap
+		}
+		settings[frontend.SettingColumnGap] = gap
+		rule := ih.columnRule
+		if rule.Color == nil {
+			rule.Color = ih.color
+		}
+		settings[frontend.SettingColumnRule] = rule
+	}
+	if ih.columnSpan {
+		settings[frontend.SettingColumnSpan] = true
+	}
+	if ih.flex {
+		fc := frontend.FlexContainer{
+			Direction:  ih.flexDirection,
+			Wrap:       ih.flexWrap,
+			Justify:    ih.justifyContent,
+			AlignItems: ih.alignItems,
+			RowGap:     ih.rowGap,
+		}
+		if ih.columnGap != nil {
+			fc.ColumnGap = *ih.columnGap
+		}
+		settings[frontend.SettingFlex] = fc
+	}
+	if ih.hasFlexItem {
+		settings[frontend.SettingFlexItem] = ih.flexItem
+	}
+	if ih.grid {
+		gc := frontend.GridContainer{
+			Columns: ih.gridColumns,
+			Rows:    ih.gridRows,
+			RowGap:  ih.rowGap,
+		}
+		if ih.columnGap != nil {
+			gc.ColumnGap = *ih.columnGap
+		}
+		settings[frontend.SettingGrid] = gc
+	}
+	if ih.hasGridItem {
+		settings[frontend.SettingGridItem] = ih.gridItem
+	}
+	if ih.float != frontend.FloatNone {
+		settings[frontend.SettingFloat] = ih.float
+	}
 	if ih.fontexpansion != nil {
 		settings[frontend.SettingFontExpansion] = *ih.fontexpansion
 	} else {
@@ -405,17 +1047,41 @@ func ApplySettings(settings frontend.TypesettingSettings, ih *FormattingStyles)
 	settings[frontend.SettingFontFamily] = ih.fontfamily
 	settings[frontend.SettingHAlign] = ih.Halign
 	settings[frontend.SettingHangingPunctuation] = ih.hangingPunctuation
+	settings[frontend.SettingHyphens] = ih.hyphens
+	if ih.hyphenateCharacter != "" {
+		settings[frontend.SettingHyphenateCharacter] = ih.hyphenateCharacter
+	}
+	settings[frontend.SettingHyphenateLimitChars] = ih.hyphenateLimitChars
+	settings[frontend.SettingHyphenateLimitLines] = ih.hyphenateLimitLines
 	settings[frontend.SettingIndentLeft] = ih.indent
 	settings[frontend.SettingIndentLeftRows] = ih.indentRows
+	if ih.initialLetter.Size > 0 {
+		settings[frontend.SettingInitialLetter] = ih.initialLetter
+	}
 	settings[frontend.SettingLeading] = ih.lineheight
+	if ih.letterSpacing != 0 {
+		settings[frontend.SettingLetterSpacing] = ih.letterSpacing
+	}
 	settings[frontend.SettingMarginBottom] = ih.marginBottom
 	settings[frontend.SettingMarginRight] = ih.marginRight
 	settings[frontend.SettingMarginLeft] = ih.marginLeft
+	if ih.marginProtrusion {
+		settings[frontend.SettingMarginProtrusion] = true
+	}
 	settings[frontend.SettingMarginTop] = ih.marginTop
 	settings[frontend.SettingOpenTypeFeature] = ih.fontfeatures
+	if ih.orphans > 0 {
+		settings[frontend.SettingOrphans] = ih.orphans
+	}
 	settings[frontend.SettingPaddingRight] = ih.PaddingRight
 	settings[frontend.SettingPaddingLeft] = ih.PaddingLeft
 	settings[frontend.SettingPaddingTop] = ih.PaddingTop
+	if ih.position.Scheme != frontend.PositionStatic {
+		settings[frontend.SettingPosition] = ih.position
+	}
+	if ih.running != "" {
+		settings[frontend.SettingRunning] = ih.running
+	}
 	settings[frontend.SettingPaddingBottom] = ih.PaddingBottom
 	settings[frontend.SettingPreserveWhitespace] = ih.preserveWhitespace
 	settings[frontend.SettingSize] = ih.Fontsize
@@ -424,10 +1090,34 @@ func ApplySettings(settings frontend.TypesettingSettings, ih *FormattingStyles)
 	settings[frontend.SettingTabSize] = ih.tabsize
 	settings[frontend.SettingTabSizeSpaces] = ih.tabsizeSpaces
 	settings[frontend.SettingTextDecorationLine] = ih.TextDecorationLine
+	settings[frontend.SettingTextTransform] = ih.textTransform
+	if ih.TextDecorationLine != frontend.TextDecorationLineNone {
+		if ih.textDecorationColor != nil {
+			settings[frontend.SettingTextDecorationColor] = ih.textDecorationColor
+		}
+		settings[frontend.SettingTextDecorationSkipInk] = ih.textDecorationSkipInk
+		settings[frontend.SettingTextDecorationStyle] = ih.textDecorationStyle
+		settings[frontend.SettingTextDecorationThickness] = ih.textDecorationThickness
+		if ih.textUnderlineOffset != nil {
+			settings[frontend.SettingTextUnderlineOffset] = *ih.textUnderlineOffset
+		}
+	}
 
+	if ih.widows > 0 {
+		settings[frontend.SettingWidows] = ih.widows
+	}
 	if ih.width != "" {
 		settings[frontend.SettingWidth] = ih.width
 	}
+	if ih.wordSpacing != 0 {
+		settings[frontend.SettingWordSpacing] = ih.wordSpacing
+	}
+	if ih.inlineBlock {
+		settings[frontend.SettingInlineBlock] = true
+	}
+	if ih.inlineVAlign != nil {
+		settings[frontend.SettingInlineVAlign] = ih.inlineVAlign
+	}
 
 }
 
@@ -479,11 +1169,16 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 	// item is guaranteed to be in vertical direction
 	newte := frontend.NewText()
 	styles := ss.PushStyles()
+	firstLetter := pseudoElementStyles(item.Styles, "first-letter")
 	if err := StylesToStyles(styles, item.Styles, df, ss.CurrentStyle().Fontsize); err != nil {
 		return nil, err
 	}
 	ApplySettings(newte.Settings, styles)
 	newte.Settings[frontend.SettingDebug] = item.Data
+	newte.Settings[frontend.SettingPage] = styles.page
+	if len(item.stringSet) > 0 {
+		newte.Settings[frontend.SettingStringSet] = item.stringSet
+	}
 	switch item.Data {
 	case "html":
 		if fs, ok := item.Styles["font-size"]; ok {
@@ -540,6 +1235,9 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 	}
 
 	var te *frontend.Text
+	// floating and absolutely positioned elements in the text are placed in
+	// front of the paragraph
+	var outOfFlow []any
 	cur := ModeVertical
 
 	// display = "none"
@@ -548,13 +1246,31 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 		return newte, nil
 	}
 
+	// the children of a flex or a grid container are blocks
+	blockChildren := styles.flex || styles.grid
+
 	for _, itm := range item.Children {
+		if itm.Dir == ModeHorizontal && blockChildren {
+			if err := outputContainerItem(newte, itm, ss, df); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		if itm.Dir == ModeHorizontal {
 			// Going from vertical to horizontal.
 			if cur == ModeVertical && itm.Data == " " {
 				// there is only a whitespace element.
 				continue
 			}
+			if isOutOfFlow(itm) {
+				fl, err := outputAsBlock(itm, ss, df)
+				if err != nil {
+					return nil, err
+				}
+				outOfFlow = append(outOfFlow, fl)
+				newte.Settings[frontend.SettingBox] = true
+				continue
+			}
 			// now in horizontal mode, there can be more children in horizontal
 			// mode, so append all of them to a single frontend.Text element
 			if itm.Typ == html.TextNode && cur == ModeVertical {
@@ -565,15 +1281,34 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 				styles = ss.PushStyles()
 			}
 			ApplySettings(te.Settings, styles)
+			if firstLetter != nil && itm.Typ == html.TextNode {
+				if letter, rest := splitFirstLetter(itm.Data); letter != "" {
+					fl := &HTMLItem{
+						Typ:      html.ElementNode,
+						Data:     "span",
+						Dir:      ModeHorizontal,
+						Styles:   firstLetter,
+						Children: []*HTMLItem{{Typ: html.TextNode, Data: letter}},
+					}
+					if err := collectHorizontalNodes(te, fl, ss, ss.CurrentStyle().Fontsize, ss.CurrentStyle().DefaultFontSize, df); err != nil {
+						return nil, err
+					}
+					itm.Data = rest
+				}
+			}
+			firstLetter = nil
 			if err := collectHorizontalNodes(te, itm, ss, ss.CurrentStyle().Fontsize, ss.CurrentStyle().DefaultFontSize, df); err != nil {
 				return nil, err
 			}
 			cur = ModeHorizontal
 		} else {
 			// still vertical
+			firstLetter = nil
 			if itm.Data == "li" {
 				styles.OlCounter++
 			}
+			newte.Items = append(newte.Items, outOfFlow...)
+			outOfFlow = nil
 			if te != nil {
 				newte.Items = append(newte.Items, te)
 				newte.Settings[frontend.SettingBox] = true
@@ -588,7 +1323,7 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 			}
 		}
 	}
-	if item.Dir == ModeVertical && cur == ModeVertical {
+	if item.Dir == ModeVertical && cur == ModeVertical || blockChildren {
 		newte.Settings[frontend.SettingBox] = true
 	}
 	switch item.Data {
@@ -598,6 +1333,7 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 		ulte.Settings[frontend.SettingDebug] = item.Data
 		ulte.Settings[frontend.SettingBox] = true
 	}
+	newte.Items = append(newte.Items, outOfFlow...)
 	if te != nil {
 		newte.Items = append(newte.Items, te)
 		ss.PopStyles()
@@ -607,6 +1343,131 @@ func Output(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Te
 	return newte, nil
 }
 
+// outputContainerItem appends the child item of a flex or a grid container in
+// horizontal mode to te. Text is wrapped in an anonymous item.
+func outputContainerItem(te *frontend.Text, item *HTMLItem, ss StylesStack, df *frontend.Document) error {
+	if item.Typ == html.TextNode {
+		if strings.TrimSpace(item.Data) == "" {
+			return nil
+		}
+		anon := frontend.NewText()
+		styles := ss.PushStyles()
+		ApplySettings(anon.Settings, styles)
+		item.Data = strings.TrimSpace(item.Data)
+		err := collectHorizontalNodes(anon, item, ss, styles.Fontsize, styles.DefaultFontSize, df)
+		ss.PopStyles()
+		if err != nil {
+			return err
+		}
+		te.Items = append(te.Items, anon)
+		return nil
+	}
+	if item.Typ != html.ElementNode {
+		return nil
+	}
+	fi, err := outputAsBlock(item, ss, df)
+	if err != nil {
+		return err
+	}
+	te.Items = append(te.Items, fi)
+	return nil
+}
+
+// isOutOfFlow returns true if the element item is a floating, an absolutely
+// positioned or a running element.
+func isOutOfFlow(item *HTMLItem) bool {
+	if item.Typ != html.ElementNode {
+		return false
+	}
+	switch item.Styles["float"] {
+	case "left", "right":
+		return true
+	}
+	switch item.Styles["position"] {
+	case "absolute", "fixed":
+		return true
+	}
+	_, running := runningName(item.Styles["position"])
+	return running
+}
+
+// runningName returns the name of a running element from the position value
+// running(name).
+func runningName(v string) (string, bool) {
+	if !strings.HasPrefix(v, "running(") || !strings.HasSuffix(v, ")") {
+		return "", false
+	}
+	name := strings.TrimSpace(v[len("running(") : len(v)-1])
+	return name, name != ""
+}
+
+// outputAsBlock returns the element item in horizontal mode as a box. This is
+// used for floating and absolutely positioned elements and for flex and grid
+// items.
+func outputAsBlock(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Text, error) {
+	fl, err := Output(item, ss, df)
+	if err != nil {
+		return nil, err
+	}
+	if item.Data == "img" {
+		img := frontend.NewText()
+		styles := ss.PushStyles()
+		ApplySettings(img.Settings, styles)
+		err := collectHorizontalNodes(img, item, ss, styles.Fontsize, styles.DefaultFontSize, df)
+		ss.PopStyles()
+		if err != nil {
+			return nil, err
+		}
+		fl.Items = append(fl.Items, img)
+	}
+	fl.Settings[frontend.SettingBox] = true
+	return fl, nil
+}
+
+// pseudoElementStyles returns a copy of the styles of the pseudo element pe
+// (such as first-letter) without the prefix. styles is not changed, the
+// prefixed keys are ignored by StylesToStyles. It returns nil if there are no
+// styles for the pseudo element.
+func pseudoElementStyles(styles map[string]string, pe string) map[string]string {
+	var ret map[string]string
+	prefix := pe + "::"
+	for k, v := range styles {
+		if key, ok := strings.CutPrefix(k, prefix); ok {
+			if ret == nil {
+				ret = make(map[string]string)
+			}
+			ret[key] = v
+		}
+	}
+	return ret
+}
+
+// splitFirstLetter returns the first letter of str including the punctuation
+// before and after it (the contents of ::first-letter) and the rest of str.
+func splitFirstLetter(str string) (string, string) {
+	i := 0
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsPunct(r) {
+			break
+		}
+		i += size
+	}
+	r, size := utf8.DecodeRuneInString(str[i:])
+	if i == len(str) || unicode.IsSpace(r) {
+		return "", str
+	}
+	i += size
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsPunct(r) || unicode.In(r, unicode.Pd, unicode.Ps) {
+			break
+		}
+		i += size
+	}
+	return str[:i], str[i:]
+}
+
 func collectHorizontalNodes(te *frontend.Text, item *HTMLItem, ss StylesStack, currentFontsize bag.ScaledPoint, defaultFontsize bag.ScaledPoint, df *frontend.Document) error {
 	switch item.Typ {
 	case html.TextNode:
@@ -654,25 +1515,50 @@ func collectHorizontalNodes(te *frontend.Text, item *HTMLItem, ss StylesStack, c
 			ii := df.Doc.CreateImage(imgfile, 1, "/MediaBox")
 			imgNode.Img = ii
 			imgNode.Attributes["attr"] = item.Attributes
-			te.Items = append(te.Items, imgNode)
+			if va := parseInlineVerticalAlign(item.Styles["vertical-align"], cs); va != nil {
+				imgText := frontend.NewText()
+				imgText.Settings[frontend.SettingInlineVAlign] = va
+				imgText.Items = append(imgText.Items, imgNode)
+				te.Items = append(te.Items, imgText)
+			} else {
+				te.Items = append(te.Items, imgNode)
+			}
 		}
 
-		for _, itm := range item.Children {
-			cld := frontend.NewText()
-			sty := ss.PushStyles()
-			if err := StylesToStyles(sty, item.Styles, df, currentFontsize); err != nil {
+		if item.Styles["display"] == "inline-block" {
+			// an inline block is formatted like a block element
+			ib, err := Output(item, ss, df)
+			if err != nil {
 				return err
 			}
-			ApplySettings(cld.Settings, sty)
 			for k, v := range childSettings {
-				cld.Settings[k] = v
+				ib.Settings[k] = v
 			}
+			te.Items = append(te.Items, ib)
+			return nil
+		}
+
+		if len(item.Children) == 0 {
+			return nil
+		}
+		// All children are collected in one text element, so the box
+		// (background, border, padding) is drawn around all of them.
+		cld := frontend.NewText()
+		sty := ss.PushStyles()
+		if err := StylesToStyles(sty, item.Styles, df, currentFontsize); err != nil {
+			return err
+		}
+		ApplySettings(cld.Settings, sty)
+		for k, v := range childSettings {
+			cld.Settings[k] = v
+		}
+		for _, itm := range item.Children {
 			if err := collectHorizontalNodes(cld, itm, ss, currentFontsize, defaultFontsize, df); err != nil {
 				return err
 			}
-			te.Items = append(te.Items, cld)
-			ss.PopStyles()
 		}
+		te.Items = append(te.Items, cld)
+		ss.PopStyles()
 	}
 	return nil
 }
@@ -0,0 +1,70 @@
+package htmlstyle
+
+import (
+	"testing"
+
+	"github.com/speedata/boxesandglue/frontend"
+)
+
+func TestSplitFirstLetter(t *testing.T) {
+	testdata := []struct {
+		str, first, rest string
+	}{
+		{"Hello", "H", "ello"},
+		{"“Quote” he said", "“Q", "uote” he said"},
+		{"A. Smith", "A.", " Smith"},
+		{"Über", "Ü", "ber"},
+		{"x-ray", "x", "-ray"},
+		{" leading space", "", " leading space"},
+		{"...", "", "..."},
+		{"", "", ""},
+	}
+	for _, td := range testdata {
+		first, rest := splitFirstLetter(td.str)
+		if first != td.first || rest != td.rest {
+			t.Errorf("splitFirstLetter(%q) = %q, %q, want %q, %q", td.str, first, rest, td.first, td.rest)
+		}
+	}
+}
+
+func TestPseudoElementStyles(t *testing.T) {
+	styles := map[string]string{
+		"color":                   "red",
+		"first-letter::font-size": "3em",
+		"before::content":         `"x"`,
+	}
+	got := pseudoElementStyles(styles, "first-letter")
+	if len(got) != 1 || got["font-size"] != "3em" {
+		t.Errorf("pseudoElementStyles(first-letter) = %v, want map[font-size:3em]", got)
+	}
+	// the styles of the element are kept, so they can be read again
+	if len(styles) != 3 {
+		t.Errorf("styles = %v, want three entries", styles)
+	}
+	if got := pseudoElementStyles(styles, "after"); got != nil {
+		t.Errorf("pseudoElementStyles(after) = %v, want nil", got)
+	}
+}
+
+func TestTextDecorationLinePropagation(t *testing.T) {
+	var ss StylesStack
+	parent := ss.PushStyles()
+	if err := StylesToStyles(parent, map[string]string{"text-decoration-line": "underline"}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	child := ss.PushStyles()
+	if err := StylesToStyles(child, map[string]string{"text-decoration-line": "line-through"}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := frontend.TextDecorationUnderline | frontend.TextDecorationLineThrough; child.TextDecorationLine != want {
+		t.Errorf("child lines = %d, want %d", child.TextDecorationLine, want)
+	}
+	// none does not remove the lines of the ancestors
+	grandchild := ss.PushStyles()
+	if err := StylesToStyles(grandchild, map[string]string{"text-decoration-line": "none"}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if grandchild.TextDecorationLine != child.TextDecorationLine {
+		t.Errorf("grandchild lines = %d, want %d", grandchild.TextDecorationLine, child.TextDecorationLine)
+	}
+}
@@ -44,6 +44,9 @@ type HTMLItem struct {
 	Attributes map[string]string
 	Styles     map[string]string
 	Children   []*HTMLItem
+	// stringSet contains the named strings assigned by the element and its
+	// inline descendants (see generateContent).
+	stringSet []frontend.StringSet
 }
 
 func (itm *HTMLItem) String() string {
@@ -132,9 +135,16 @@ func GetHTMLItemFromHTMLNode(thisNode *html.Node, direction Mode, firstItem *HTM
 					}
 				}
 			}
+			childDir := newDir
+			if itm.Styles["display"] == "inline-block" {
+				// placed in a line, but the contents are formatted like
+				// a block
+				itm.Dir = ModeHorizontal
+				childDir = ModeVertical
+			}
 			if thisNode.FirstChild != nil {
 				preserveWhitespace = append(preserveWhitespace, ws)
-				GetHTMLItemFromHTMLNode(thisNode.FirstChild, newDir, itm)
+				GetHTMLItemFromHTMLNode(thisNode.FirstChild, childDir, itm)
 				preserveWhitespace = preserveWhitespace[:len(preserveWhitespace)-1]
 			}
 		case html.DocumentNode:
@@ -150,7 +160,16 @@ func GetHTMLItemFromHTMLNode(thisNode *html.Node, direction Mode, firstItem *HTM
 
 // HTMLNodeToText converts an HTML node to a *frontend.Text element.
 func HTMLNodeToText(n *html.Node, ss StylesStack, df *frontend.Document) (*frontend.Text, error) {
+	return HTMLNodeToTextWithCounters(n, ss, df, Counters{})
+}
+
+// HTMLNodeToTextWithCounters converts an HTML node to a *frontend.Text element.
+// The CSS counters start with the values in counters and the counters
+// instantiated at the top level are kept in counters, so they can be used for
+// the next HTML node.
+func HTMLNodeToTextWithCounters(n *html.Node, ss StylesStack, df *frontend.Document, counters Counters) (*frontend.Text, error) {
 	h := &HTMLItem{Dir: ModeVertical}
 	GetHTMLItemFromHTMLNode(n, ModeVertical, h)
+	generateContent(h, counters)
 	return Output(h, ss, df)
 }
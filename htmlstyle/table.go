@@ -73,15 +73,23 @@ func processTr(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend
 			}
 			if borderTopStyle == "none" {
 				tc.BorderTopWidth = 0
+			} else if borderTopStyle != "" {
+				tc.BorderTopStyle = frontend.ParseBorderStyle(borderTopStyle)
 			}
 			if borderBottomStyle == "none" {
 				tc.BorderBottomWidth = 0
+			} else if borderBottomStyle != "" {
+				tc.BorderBottomStyle = frontend.ParseBorderStyle(borderBottomStyle)
 			}
 			if borderLeftStyle == "none" {
 				tc.BorderLeftWidth = 0
+			} else if borderLeftStyle != "" {
+				tc.BorderLeftStyle = frontend.ParseBorderStyle(borderLeftStyle)
 			}
 			if borderRightStyle == "none" {
 				tc.BorderRightWidth = 0
+			} else if borderRightStyle != "" {
+				tc.BorderRightStyle = frontend.ParseBorderStyle(borderRightStyle)
 			}
 
 			for k, v := range itm.Attributes {
@@ -143,7 +151,13 @@ func processTbody(item *HTMLItem, ss StylesStack, df *frontend.Document) (fronte
 func processTable(item *HTMLItem, ss StylesStack, df *frontend.Document) (*frontend.Table, error) {
 	tbl := &frontend.Table{}
 	tbl.Stretch = false
-	var rows frontend.TableRows
+	styles := ss.CurrentStyle()
+	tbl.BorderSeparate = !styles.borderCollapse
+	if tbl.BorderSeparate {
+		tbl.BorderSpacingX = styles.borderSpacingX
+		tbl.BorderSpacingY = styles.borderSpacingY
+	}
+	var rows, head, body, foot frontend.TableRows
 	var err error
 	for _, itm := range item.Children {
 		if itm.Data == "colgroup" {
@@ -194,7 +208,7 @@ func processTable(item *HTMLItem, ss StylesStack, df *frontend.Document) (*front
 			}
 
 		}
-		if itm.Data == "thead" || itm.Data == "tbody" {
+		if itm.Data == "thead" || itm.Data == "tbody" || itm.Data == "tfoot" {
 			styles := ss.PushStyles()
 			for k, v := range itm.Styles {
 				switch k {
@@ -235,9 +249,21 @@ func processTable(item *HTMLItem, ss StylesStack, df *frontend.Document) (*front
 			if rows, err = processTbody(itm, ss, df); err != nil {
 				return nil, err
 			}
-			tbl.Rows = append(tbl.Rows, rows...)
+			switch itm.Data {
+			case "thead":
+				head = append(head, rows...)
+			case "tfoot":
+				foot = append(foot, rows...)
+			default:
+				body = append(body, rows...)
+			}
 			ss.PopStyles()
 		}
 	}
+	// the header rows are repeated at the top and the footer rows at the
+	// bottom of each part of a split table
+	tbl.Rows = append(append(head, body...), foot...)
+	tbl.HeaderRows = len(head)
+	tbl.FooterRows = len(foot)
 	return tbl, nil
 }